@@ -0,0 +1,31 @@
+package ms
+
+//IsotopeSpacing is the mass difference between 13C and 12C, which dominates
+//the spacing of the isotope peaks of organic ions
+const IsotopeSpacing = 1.0033548
+
+//InferCharge returns the charge state of the ion at mz in the sorted (MS1)
+//spectrum, judged by its isotope peaks at mz + k*IsotopeSpacing/z. The charge
+//that explains the most of the next three isotopes wins, ties go to the higher
+//summed intensity. It returns 0 if mz or its first isotope is not found.
+func InferCharge(s Spectrum, mz float64, tol Tolerance, maxCharge int) int {
+	if len(s.Around(mz, tol)) == 0 {
+		return 0
+	}
+	best, bestFound, bestI := 0, 0, float32(0)
+	for z := 1; z <= maxCharge; z++ {
+		found, sum := 0, float32(0)
+		for k := 1; k <= 3; k++ {
+			peaks := s.Around(mz+float64(k)*IsotopeSpacing/float64(z), tol)
+			if len(peaks) == 0 {
+				break
+			}
+			found++
+			sum += peaks.MaxPeak().I
+		}
+		if found > bestFound || found == bestFound && found > 0 && sum > bestI {
+			best, bestFound, bestI = z, found, sum
+		}
+	}
+	return best
+}
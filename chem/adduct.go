@@ -0,0 +1,126 @@
+package chem
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+//Adduct describes how an ion is formed from a molecule M, in the
+//common notation [kM+X-Y]z+ such as [M+H]+, [M+Na]+, [M-H]- or [2M+NH4]+
+type Adduct struct {
+	//Name is the notation the adduct was parsed from
+	Name string
+	//Multimer is the number of molecules in the ion
+	Multimer int
+	//Delta is the composition that is gained (or lost, with negative counts)
+	Delta Formula
+	//Charge is the signed charge of the ion
+	Charge int
+}
+
+//ErrNoCharge is returned when an adduct has no charge
+var ErrNoCharge = errors.New("adduct has no charge")
+
+var adductPattern = regexp.MustCompile(`^\[(\d*)M((?:[+-][^+\-\]]+)*)\](\d*)([+-]+)(\d*)$`)
+var adductTerm = regexp.MustCompile(`([+-])(\d*)([^+-]+)`)
+
+//ParseAdduct reads adduct notation, e.g. "[M+H]+", "[M+2H]2+", "[M-H2O+H]+" or "[M]+"
+func ParseAdduct(s string) (a Adduct, err error) {
+	m := adductPattern.FindStringSubmatch(s)
+	if m == nil {
+		err = fmt.Errorf("invalid adduct %q", s)
+		return
+	}
+	a.Name = s
+	a.Multimer = 1
+	if m[1] != "" {
+		a.Multimer, _ = strconv.Atoi(m[1])
+	}
+
+	a.Delta = make(Formula)
+	for _, t := range adductTerm.FindAllStringSubmatch(m[2], -1) {
+		n := 1
+		if t[2] != "" {
+			n, _ = strconv.Atoi(t[2])
+		}
+		if t[1] == "-" {
+			n = -n
+		}
+		var f Formula
+		if f, err = ParseFormula(t[3]); err != nil {
+			return
+		}
+		a.Delta.addScaled(f, n)
+	}
+
+	//the magnitude is written either before ("2+") or after ("+2") the sign,
+	//or by repeating the sign ("++")
+	z := len(m[4])
+	switch {
+	case m[3] != "" && m[5] != "":
+		err = fmt.Errorf("invalid charge in adduct %q", s)
+		return
+	case m[3] != "":
+		z, _ = strconv.Atoi(m[3])
+	case m[5] != "":
+		z, _ = strconv.Atoi(m[5])
+	}
+	if z == 0 {
+		err = fmt.Errorf("%w: %q", ErrNoCharge, s)
+		return
+	}
+	if m[4][0] == '-' {
+		z = -z
+	}
+	a.Charge = z
+	return
+}
+
+//MustParseAdduct is like ParseAdduct but panics on malformed input
+func MustParseAdduct(s string) Adduct {
+	a, err := ParseAdduct(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+//Common adducts
+var (
+	ProtonatedAdduct   = MustParseAdduct("[M+H]+")
+	SodiatedAdduct     = MustParseAdduct("[M+Na]+")
+	AmmoniatedAdduct   = MustParseAdduct("[M+NH4]+")
+	PotassiatedAdduct  = MustParseAdduct("[M+K]+")
+	DeprotonatedAdduct = MustParseAdduct("[M-H]-")
+)
+
+//Apply returns the elemental composition of the ion formed from f
+func (a Adduct) Apply(f Formula) Formula {
+	ion := f.Mul(a.Multimer)
+	ion.addScaled(a.Delta, 1)
+	return ion
+}
+
+//Mz returns the monoisotopic m/z of the ion formed from f
+func (a Adduct) Mz(f Formula) float64 {
+	return a.Apply(f).Mz(a.Charge)
+}
+
+//MassToMz returns the m/z of the ion formed from a molecule with the supplied neutral mass
+func (a Adduct) MassToMz(m float64) float64 {
+	return (float64(a.Multimer)*m + a.Delta.MonoisotopicMass() - float64(a.Charge)*ElectronMass) /
+		math.Abs(float64(a.Charge))
+}
+
+//MzToMass returns the neutral mass of the molecule that forms an ion at the supplied m/z
+func (a Adduct) MzToMass(mz float64) float64 {
+	return (mz*math.Abs(float64(a.Charge)) + float64(a.Charge)*ElectronMass - a.Delta.MonoisotopicMass()) /
+		float64(a.Multimer)
+}
+
+func (a Adduct) String() string {
+	return a.Name
+}
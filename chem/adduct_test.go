@@ -0,0 +1,71 @@
+package chem
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseAdduct(t *testing.T) {
+	tests := []struct {
+		s        string
+		multimer int
+		delta    string
+		charge   int
+	}{
+		{"[M+H]+", 1, "H", 1},
+		{"[M+2H]2+", 1, "H2", 2},
+		{"[M+2H]+2", 1, "H2", 2},
+		{"[M+2H]++", 1, "H2", 2},
+		{"[M-H2O+H]+", 1, "H-1O-1", 1},
+		{"[2M+NH4]+", 2, "H4N", 1},
+		{"[M-H]-", 1, "H-1", -1},
+		{"[M]+", 1, "", 1},
+	}
+	for _, tt := range tests {
+		a, err := ParseAdduct(tt.s)
+		if err != nil {
+			t.Errorf("ParseAdduct(%q): %v", tt.s, err)
+			continue
+		}
+		if a.Multimer != tt.multimer || a.Delta.String() != tt.delta || a.Charge != tt.charge {
+			t.Errorf("ParseAdduct(%q) = %d %q %d, want %d %q %d", tt.s,
+				a.Multimer, a.Delta.String(), a.Charge, tt.multimer, tt.delta, tt.charge)
+		}
+	}
+	for _, s := range []string{"M+H", "[M+H]", "[M+H]2+2", "[M+Xx]+"} {
+		if _, err := ParseAdduct(s); err == nil {
+			t.Errorf("ParseAdduct(%q): no error", s)
+		}
+	}
+	if _, err := ParseAdduct("[M+H]0+"); !errors.Is(err, ErrNoCharge) {
+		t.Errorf("ParseAdduct of charge 0: error %v, want ErrNoCharge", err)
+	}
+}
+
+func TestAdductMz(t *testing.T) {
+	glucose := MustParseFormula("C6H12O6")
+	tests := []struct {
+		a  Adduct
+		mz float64
+	}{
+		{ProtonatedAdduct, 181.070664569012},
+		{SodiatedAdduct, 203.05260880319054},
+		{DeprotonatedAdduct, 179.05611163538802},
+		{MustParseAdduct("[M+2H]2+"), (180.0633881022 + 2*ProtonMass) / 2},
+		{MustParseAdduct("[2M+H]+"), 2*180.0633881022 + ProtonMass},
+	}
+	//the hydrogen and proton masses differ by the electron within 2e-8 Da
+	const tol = 1e-7
+	for _, tt := range tests {
+		if got := tt.a.Mz(glucose); math.Abs(got-tt.mz) > tol {
+			t.Errorf("%v: Mz = %v, want %v", tt.a, got, tt.mz)
+		}
+		if got := tt.a.MassToMz(glucose.MonoisotopicMass()); math.Abs(got-tt.mz) > tol {
+			t.Errorf("%v: MassToMz = %v, want %v", tt.a, got, tt.mz)
+		}
+		if got := tt.a.MzToMass(tt.mz); math.Abs(got-180.0633881022) > tol {
+			t.Errorf("%v: MzToMass = %v, want the mass of glucose", tt.a, got)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+//Package chem contains the chemistry needed for mass spectrometry:
+//an element table, molecular formulas, adducts and isotope distributions.
+package chem
+
+import "sort"
+
+//Isotope is a single nuclide of an element
+type Isotope struct {
+	//MassNumber is the number of nucleons, e.g. 13 for carbon-13
+	MassNumber int
+	//Mass is the exact mass in Da
+	Mass float64
+	//Abundance is the natural abundance as a fraction between 0 and 1
+	Abundance float64
+}
+
+//Element holds the isotopes of an element, sorted by mass number
+type Element struct {
+	Symbol   string
+	Isotopes []Isotope
+}
+
+//ElectronMass is the rest mass of an electron in Da
+const ElectronMass = 0.00054857990946
+
+//ProtonMass is the rest mass of a proton in Da
+const ProtonMass = 1.007276466812
+
+//NeutronMass is the rest mass of a neutron in Da
+const NeutronMass = 1.00866491588
+
+//Monoisotopic returns the most abundant isotope of the element
+func (e Element) Monoisotopic() (iso Isotope) {
+	for _, i := range e.Isotopes {
+		if i.Abundance > iso.Abundance {
+			iso = i
+		}
+	}
+	return
+}
+
+//MonoisotopicMass returns the mass of the most abundant isotope
+func (e Element) MonoisotopicMass() float64 {
+	return e.Monoisotopic().Mass
+}
+
+//AverageMass returns the abundance weighted mass of the element
+func (e Element) AverageMass() (m float64) {
+	for _, i := range e.Isotopes {
+		m += i.Mass * i.Abundance
+	}
+	return
+}
+
+//Isotope returns the isotope with the supplied mass number
+func (e Element) Isotope(massNumber int) (Isotope, bool) {
+	for _, i := range e.Isotopes {
+		if i.MassNumber == massNumber {
+			return i, true
+		}
+	}
+	return Isotope{}, false
+}
+
+//Elements is the periodic table, indexed by element symbol.
+//Masses and abundances are the IUPAC/NIST values.
+var Elements = map[string]Element{
+	"H": {"H", []Isotope{
+		{1, 1.00782503207, 0.999885},
+		{2, 2.0141017778, 0.000115}}},
+	"Li": {"Li", []Isotope{
+		{6, 6.015122795, 0.0759},
+		{7, 7.01600455, 0.9241}}},
+	"B": {"B", []Isotope{
+		{10, 10.0129370, 0.199},
+		{11, 11.0093054, 0.801}}},
+	"C": {"C", []Isotope{
+		{12, 12.0, 0.9893},
+		{13, 13.0033548378, 0.0107}}},
+	"N": {"N", []Isotope{
+		{14, 14.0030740048, 0.99636},
+		{15, 15.0001088982, 0.00364}}},
+	"O": {"O", []Isotope{
+		{16, 15.99491461956, 0.99757},
+		{17, 16.99913170, 0.00038},
+		{18, 17.9991610, 0.00205}}},
+	"F": {"F", []Isotope{
+		{19, 18.99840322, 1}}},
+	"Na": {"Na", []Isotope{
+		{23, 22.9897692809, 1}}},
+	"Mg": {"Mg", []Isotope{
+		{24, 23.985041700, 0.7899},
+		{25, 24.98583692, 0.1000},
+		{26, 25.982592929, 0.1101}}},
+	"Si": {"Si", []Isotope{
+		{28, 27.9769265325, 0.92223},
+		{29, 28.976494700, 0.04685},
+		{30, 29.97377017, 0.03092}}},
+	"P": {"P", []Isotope{
+		{31, 30.97376163, 1}}},
+	"S": {"S", []Isotope{
+		{32, 31.97207100, 0.9499},
+		{33, 32.97145876, 0.0075},
+		{34, 33.96786690, 0.0425},
+		{36, 35.96708076, 0.0001}}},
+	"Cl": {"Cl", []Isotope{
+		{35, 34.96885268, 0.7576},
+		{37, 36.96590259, 0.2424}}},
+	"K": {"K", []Isotope{
+		{39, 38.96370668, 0.932581},
+		{40, 39.96399848, 0.000117},
+		{41, 40.96182576, 0.067302}}},
+	"Ca": {"Ca", []Isotope{
+		{40, 39.96259098, 0.96941},
+		{42, 41.95861801, 0.00647},
+		{43, 42.9587666, 0.00135},
+		{44, 43.9554818, 0.02086},
+		{46, 45.9536926, 0.00004},
+		{48, 47.952534, 0.00187}}},
+	"Fe": {"Fe", []Isotope{
+		{54, 53.9396105, 0.05845},
+		{56, 55.9349375, 0.91754},
+		{57, 56.9353940, 0.02119},
+		{58, 57.9332756, 0.00282}}},
+	"Cu": {"Cu", []Isotope{
+		{63, 62.9295975, 0.6915},
+		{65, 64.9277895, 0.3085}}},
+	"Zn": {"Zn", []Isotope{
+		{64, 63.9291422, 0.4917},
+		{66, 65.9260334, 0.2773},
+		{67, 66.9271273, 0.0404},
+		{68, 67.9248442, 0.1845},
+		{70, 69.9253193, 0.0061}}},
+	"Se": {"Se", []Isotope{
+		{74, 73.9224764, 0.0089},
+		{76, 75.9192136, 0.0937},
+		{77, 76.9199140, 0.0763},
+		{78, 77.9173091, 0.2377},
+		{80, 79.9165213, 0.4961},
+		{82, 81.9166994, 0.0873}}},
+	"Br": {"Br", []Isotope{
+		{79, 78.9183371, 0.5069},
+		{81, 80.9162906, 0.4931}}},
+	"I": {"I", []Isotope{
+		{127, 126.904473, 1}}},
+}
+
+//Symbols returns the known element symbols in alphabetical order
+func Symbols() []string {
+	s := make([]string, 0, len(Elements))
+	for k := range Elements {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return s
+}
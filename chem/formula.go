@@ -0,0 +1,293 @@
+package chem
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//Formula is an elemental composition, mapping element symbols to atom counts.
+//Isotopically labelled atoms are keyed by mass number and symbol, e.g. "13C".
+//Negative counts are allowed, they describe losses in modifications and adducts.
+type Formula map[string]int
+
+//ParseFormula reads formulas such as "C6H12O6", "Ca(OH)2", "[13C]6H12O6"
+//and "H-2O-1". Labelled isotopes are written between square brackets.
+func ParseFormula(s string) (Formula, error) {
+	p := formulaParser{s: s}
+	f, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at position %d in formula %q", p.s[p.pos], p.pos, s)
+	}
+	return f, nil
+}
+
+//MustParseFormula is like ParseFormula but panics on malformed input.
+//It is meant for formulas that are constants in code.
+func MustParseFormula(s string) Formula {
+	f, err := ParseFormula(s)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+type formulaParser struct {
+	s   string
+	pos int
+}
+
+//parse reads terms until the end of the string or a closing parenthesis
+//at the supplied nesting depth
+func (p *formulaParser) parse(depth int) (Formula, error) {
+	f := make(Formula)
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == ' ':
+			p.pos++
+		case c == '(':
+			p.pos++
+			group, err := p.parse(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+				return nil, fmt.Errorf("unbalanced parenthesis in formula %q", p.s)
+			}
+			p.pos++
+			f.addScaled(group, p.count())
+		case c == ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("unbalanced parenthesis in formula %q", p.s)
+			}
+			return f, nil
+		case c == '[':
+			end := strings.IndexByte(p.s[p.pos:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated isotope label in formula %q", p.s)
+			}
+			label := p.s[p.pos+1 : p.pos+end]
+			if _, err := isotopeOf(label); err != nil {
+				return nil, err
+			}
+			p.pos += end + 1
+			f[label] += p.count()
+		case unicode.IsUpper(rune(c)):
+			start := p.pos
+			p.pos++
+			for p.pos < len(p.s) && unicode.IsLower(rune(p.s[p.pos])) {
+				p.pos++
+			}
+			symbol := p.s[start:p.pos]
+			if _, ok := Elements[symbol]; !ok {
+				return nil, fmt.Errorf("unknown element %q in formula %q", symbol, p.s)
+			}
+			f[symbol] += p.count()
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d in formula %q", c, p.pos, p.s)
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("unbalanced parenthesis in formula %q", p.s)
+	}
+	return f, nil
+}
+
+//count reads an optional, possibly negative, multiplier. It defaults to 1.
+func (p *formulaParser) count() int {
+	start := p.pos
+	if p.pos < len(p.s) && p.s[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	switch p.s[start:p.pos] {
+	case "":
+		return 1
+	case "-":
+		return -1
+	}
+	n, _ := strconv.Atoi(p.s[start:p.pos])
+	return n
+}
+
+//isotopeOf resolves a label such as "13C" to its isotope
+func isotopeOf(label string) (Isotope, error) {
+	i := strings.IndexFunc(label, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i <= 0 {
+		return Isotope{}, fmt.Errorf("invalid isotope label %q", label)
+	}
+	e, ok := Elements[label[i:]]
+	if !ok {
+		return Isotope{}, fmt.Errorf("unknown element in isotope label %q", label)
+	}
+	a, _ := strconv.Atoi(label[:i])
+	iso, ok := e.Isotope(a)
+	if !ok {
+		return Isotope{}, fmt.Errorf("unknown isotope %q", label)
+	}
+	return iso, nil
+}
+
+//Copy returns an independent copy of the formula
+func (f Formula) Copy() Formula {
+	g := make(Formula, len(f))
+	for k, n := range f {
+		g[k] = n
+	}
+	return g
+}
+
+//Add returns the sum of both formulas
+func (f Formula) Add(g Formula) Formula {
+	h := f.Copy()
+	h.addScaled(g, 1)
+	return h
+}
+
+//Sub returns f minus g
+func (f Formula) Sub(g Formula) Formula {
+	h := f.Copy()
+	h.addScaled(g, -1)
+	return h
+}
+
+//Mul returns the formula with every count multiplied by n
+func (f Formula) Mul(n int) Formula {
+	h := make(Formula, len(f))
+	h.addScaled(f, n)
+	return h
+}
+
+//addScaled adds n times g to f in place, removing symbols that cancel out
+func (f Formula) addScaled(g Formula, n int) {
+	for k, c := range g {
+		f[k] += n * c
+		if f[k] == 0 {
+			delete(f, k)
+		}
+	}
+}
+
+//mass sums the counts with the mass that the supplied function gives an element.
+//Labelled isotopes always contribute their exact mass.
+func (f Formula) mass(elementMass func(Element) float64) (m float64) {
+	for k, n := range f {
+		if e, ok := Elements[k]; ok {
+			m += float64(n) * elementMass(e)
+		} else if iso, err := isotopeOf(k); err == nil {
+			m += float64(n) * iso.Mass
+		} else {
+			return math.NaN()
+		}
+	}
+	return
+}
+
+//MonoisotopicMass returns the exact mass of the formula built from
+//the most abundant isotope of each element. Unknown symbols give NaN.
+func (f Formula) MonoisotopicMass() float64 {
+	return f.mass(Element.MonoisotopicMass)
+}
+
+//AverageMass returns the molecular weight of the formula. Unknown symbols give NaN.
+func (f Formula) AverageMass() float64 {
+	return f.mass(Element.AverageMass)
+}
+
+//Mz returns the m/z of the formula when it is the full composition of an ion
+//with the supplied charge, i.e. only the electron mass is corrected for.
+//A charge of 0 returns the monoisotopic mass.
+func (f Formula) Mz(charge int) float64 {
+	if charge == 0 {
+		return f.MonoisotopicMass()
+	}
+	return (f.MonoisotopicMass() - float64(charge)*ElectronMass) / math.Abs(float64(charge))
+}
+
+//Validate returns an error for symbols that are not in the element table
+func (f Formula) Validate() error {
+	for k := range f {
+		if _, ok := Elements[k]; ok {
+			continue
+		}
+		if _, err := isotopeOf(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//String returns the formula in Hill notation, labelled isotopes
+//follow their element between square brackets
+func (f Formula) String() string {
+	keys := make([]string, 0, len(f))
+	for k, n := range f {
+		if n != 0 {
+			keys = append(keys, k)
+		}
+	}
+	var hasCarbon bool
+	for _, k := range keys {
+		hasCarbon = hasCarbon || strings.TrimLeftFunc(k, unicode.IsDigit) == "C"
+	}
+	rank := func(k string) (string, int) {
+		symbol := strings.TrimLeftFunc(k, unicode.IsDigit)
+		a, _ := strconv.Atoi(k[:len(k)-len(symbol)])
+		if hasCarbon {
+			switch symbol {
+			case "C":
+				symbol = "\x00"
+			case "H":
+				symbol = "\x01"
+			}
+		}
+		return symbol, a
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		si, ai := rank(keys[i])
+		sj, aj := rank(keys[j])
+		if si != sj {
+			return si < sj
+		}
+		return ai < aj
+	})
+
+	var b strings.Builder
+	for _, k := range keys {
+		if unicode.IsDigit(rune(k[0])) {
+			b.WriteString("[" + k + "]")
+		} else {
+			b.WriteString(k)
+		}
+		if n := f[k]; n != 1 {
+			b.WriteString(strconv.Itoa(n))
+		}
+	}
+	return b.String()
+}
+
+//Mz returns the m/z of a molecule with the supplied neutral mass,
+//(de)protonated to the supplied charge
+func Mz(neutralMass float64, charge int) float64 {
+	if charge == 0 {
+		return neutralMass
+	}
+	return (neutralMass + float64(charge)*ProtonMass) / math.Abs(float64(charge))
+}
+
+//NeutralMass is the inverse of Mz
+func NeutralMass(mz float64, charge int) float64 {
+	if charge == 0 {
+		return mz
+	}
+	return mz*math.Abs(float64(charge)) - float64(charge)*ProtonMass
+}
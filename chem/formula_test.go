@@ -0,0 +1,93 @@
+package chem
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseFormula(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Formula
+	}{
+		{"C6H12O6", Formula{"C": 6, "H": 12, "O": 6}},
+		{"Ca(OH)2", Formula{"Ca": 1, "O": 2, "H": 2}},
+		{"(CH3)2(CO)", Formula{"C": 3, "H": 6, "O": 1}},
+		{"[13C]6H12O6", Formula{"13C": 6, "H": 12, "O": 6}},
+		{"C5[13C]H12O6", Formula{"C": 5, "13C": 1, "H": 12, "O": 6}},
+		{"H-2O-1", Formula{"H": -2, "O": -1}},
+		{"HNO-1", Formula{"H": 1, "N": 1, "O": -1}},
+		{"C2H6 O", Formula{"C": 2, "H": 6, "O": 1}},
+		{"", Formula{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormula(tt.s)
+		if err != nil {
+			t.Errorf("ParseFormula(%q): %v", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFormula(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+	for _, s := range []string{"C6H12O6)", "(CH2", "Xx2", "c6", "[13C", "[99C]", "[C]", "[13Xx]", "H2O+"} {
+		if f, err := ParseFormula(s); err == nil {
+			t.Errorf("ParseFormula(%q) = %v, want an error", s, f)
+		}
+	}
+}
+
+func TestFormulaString(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"O6H12C6", "C6H12O6"},
+		{"[13C]6H12O6", "[13C]6H12O6"},
+		{"H12[13C]C5O6", "C5[13C]H12O6"},
+		{"NaCl", "ClNa"},
+		{"Ca(OH)2", "CaH2O2"},
+		{"H2O", "H2O"},
+		{"SCH4", "CH4S"},
+		{"H-2O-1", "H-2O-1"},
+	}
+	for _, tt := range tests {
+		if got := MustParseFormula(tt.s).String(); got != tt.want {
+			t.Errorf("%q: String() = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestFormulaMass(t *testing.T) {
+	tests := []struct {
+		s             string
+		mono, average float64
+	}{
+		{"C6H12O6", 180.0633881022, 180.156},
+		{"H2O", 18.0105646837, 18.015},
+		{"[13C]6H12O6", 180.0633881022 + 6*1.0033548378, 186.112},
+		{"H-2O-1", -18.0105646837, -18.015},
+	}
+	for _, tt := range tests {
+		f := MustParseFormula(tt.s)
+		if got := f.MonoisotopicMass(); math.Abs(got-tt.mono) > 1e-9 {
+			t.Errorf("%q: MonoisotopicMass() = %v, want %v", tt.s, got, tt.mono)
+		}
+		if got := f.AverageMass(); math.Abs(got-tt.average) > 1e-3 {
+			t.Errorf("%q: AverageMass() = %v, want %v", tt.s, got, tt.average)
+		}
+	}
+	if m := (Formula{"Xx": 1}).MonoisotopicMass(); !math.IsNaN(m) {
+		t.Errorf("unknown element: mass %v, want NaN", m)
+	}
+	//an ion composition only loses the electrons
+	if got, want := MustParseFormula("C6H13O6").Mz(1), 181.070664569012; math.Abs(got-want) > 1e-7 {
+		t.Errorf("Mz(1) = %v, want %v", got, want)
+	}
+	if got, want := Mz(180.0633881022, 2), (180.0633881022+2*ProtonMass)/2; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Mz = %v, want %v", got, want)
+	}
+	if got := NeutralMass(Mz(180.0633881022, -2), -2); math.Abs(got-180.0633881022) > 1e-9 {
+		t.Errorf("NeutralMass(Mz) = %v", got)
+	}
+}
@@ -0,0 +1,272 @@
+package chem
+
+import (
+	"math"
+	"sort"
+
+	"github.com/danhitchcock/ms"
+)
+
+//IsotopePeak is one peak of an isotope distribution
+type IsotopePeak struct {
+	Mass float64
+	//Abundance is the probability of the peak, all peaks of a complete distribution sum to 1
+	Abundance float64
+}
+
+//IsotopeDistribution is a list of isotope peaks sorted by mass
+type IsotopeDistribution []IsotopePeak
+
+//fineMergeTolerance is the mass difference (in Da) under which
+//fine isotope peaks are considered the same composition
+const fineMergeTolerance = 1e-6
+
+//FineIsotopes returns the fine structure isotope distribution of the formula,
+//in which isotopologues with the same nominal mass but different composition
+//(e.g. 13C versus 15N) are separate peaks. Peaks with an abundance below
+//minAbundance are dropped. Elements with negative counts only shift the
+//masses by their monoisotopic mass.
+func (f Formula) FineIsotopes(minAbundance float64) IsotopeDistribution {
+	//prune intermediate results harder than the final one, so the
+	//pruning errors do not add up to visible peaks
+	prune := minAbundance * 1e-3
+
+	d := IsotopeDistribution{{0, 1}}
+	var shift float64
+	for _, k := range sortedKeys(f) {
+		n := f[k]
+		atom := atomDistribution(k)
+		if n < 0 {
+			shift += float64(n) * atom.mostAbundant().Mass
+			continue
+		}
+		d = d.convolve(atom.power(n, prune), prune)
+	}
+
+	out := d[:0]
+	for _, p := range d {
+		if p.Abundance >= minAbundance {
+			p.Mass += shift
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+//AggregatedIsotopes returns at most n peaks of the isotope distribution with
+//isotopologues of the same nominal mass combined into one peak at their
+//abundance weighted average mass. Leading peaks below 1e-6 of the most
+//abundant one are skipped, so for small organic molecules the first peak is
+//the monoisotopic one, while for proteins it is the start of the envelope.
+func (f Formula) AggregatedIsotopes(n int) IsotopeDistribution {
+	d := nominalDistribution{p: []float64{1}, m: []float64{0}}
+	var shift float64
+	for _, k := range sortedKeys(f) {
+		c := f[k]
+		atom := atomDistribution(k)
+		if c < 0 {
+			shift += float64(c) * atom.mostAbundant().Mass
+			continue
+		}
+		d = d.convolve(atom.nominal().power(c))
+	}
+
+	var max float64
+	for _, p := range d.p {
+		max = math.Max(max, p)
+	}
+	var out IsotopeDistribution
+	for i := range d.p {
+		if len(out) == n {
+			break
+		}
+		if len(out) == 0 && d.p[i] < max*1e-6 {
+			continue
+		}
+		if d.p[i] > 0 {
+			out = append(out, IsotopePeak{d.m[i]/d.p[i] + shift, d.p[i]})
+		}
+	}
+	return out
+}
+
+//Normalized returns the distribution scaled so that the most abundant peak is 1
+func (d IsotopeDistribution) Normalized() IsotopeDistribution {
+	max := d.mostAbundant().Abundance
+	out := make(IsotopeDistribution, len(d))
+	for i, p := range d {
+		out[i] = IsotopePeak{p.Mass, p.Abundance / max}
+	}
+	return out
+}
+
+//Spectrum returns the distribution as peaks of an ion with the supplied
+//charge, the intensities are relative to the most abundant peak.
+//The masses are taken as the full ion composition, so only the
+//electron mass is corrected for (see Formula.Mz).
+func (d IsotopeDistribution) Spectrum(charge int) ms.Spectrum {
+	s := make(ms.Spectrum, len(d))
+	for i, p := range d.Normalized() {
+		mz := p.Mass
+		if charge != 0 {
+			mz = (p.Mass - float64(charge)*ElectronMass) / math.Abs(float64(charge))
+		}
+		s[i] = ms.Peak{Mz: mz, I: float32(p.Abundance)}
+	}
+	sort.Sort(s)
+	return s
+}
+
+func (d IsotopeDistribution) mostAbundant() (max IsotopePeak) {
+	for _, p := range d {
+		if p.Abundance > max.Abundance {
+			max = p
+		}
+	}
+	return
+}
+
+//atomDistribution returns the isotope peaks of a single atom.
+//Labelled isotopes are assumed to be fully enriched.
+func atomDistribution(k string) IsotopeDistribution {
+	if e, ok := Elements[k]; ok {
+		d := make(IsotopeDistribution, len(e.Isotopes))
+		for i, iso := range e.Isotopes {
+			d[i] = IsotopePeak{iso.Mass, iso.Abundance}
+		}
+		return d
+	}
+	if iso, err := isotopeOf(k); err == nil {
+		return IsotopeDistribution{{iso.Mass, 1}}
+	}
+	return IsotopeDistribution{{math.NaN(), 1}}
+}
+
+//power returns the distribution of n independent copies, by repeated squaring
+func (d IsotopeDistribution) power(n int, prune float64) IsotopeDistribution {
+	result := IsotopeDistribution{{0, 1}}
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result = result.convolve(d, prune)
+		}
+		if n > 1 {
+			d = d.convolve(d, prune)
+		}
+	}
+	return result
+}
+
+//convolve combines two distributions, dropping peaks below prune
+//and merging peaks of identical composition
+func (d IsotopeDistribution) convolve(e IsotopeDistribution, prune float64) IsotopeDistribution {
+	c := make(IsotopeDistribution, 0, len(d)*len(e))
+	for _, p := range d {
+		for _, q := range e {
+			if a := p.Abundance * q.Abundance; a >= prune {
+				c = append(c, IsotopePeak{p.Mass + q.Mass, a})
+			}
+		}
+	}
+	sort.Slice(c, func(i, j int) bool { return c[i].Mass < c[j].Mass })
+
+	out := c[:0]
+	for _, p := range c {
+		if last := len(out) - 1; last >= 0 && p.Mass-out[last].Mass < fineMergeTolerance {
+			a := out[last].Abundance + p.Abundance
+			out[last].Mass = (out[last].Mass*out[last].Abundance + p.Mass*p.Abundance) / a
+			out[last].Abundance = a
+		} else {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+//nominalDistribution holds per nominal mass bin the abundance p
+//and the abundance weighted mass m, bin 0 is at the nominal mass offset
+type nominalDistribution struct {
+	offset int
+	p      []float64
+	m      []float64
+}
+
+//nominalNegligible is the relative abundance under which bins at the
+//edges of a nominal distribution are trimmed
+const nominalNegligible = 1e-12
+
+func (d IsotopeDistribution) nominal() (nd nominalDistribution) {
+	lo := math.MaxInt32
+	hi := math.MinInt32
+	for _, p := range d {
+		a := int(math.Round(p.Mass))
+		if a < lo {
+			lo = a
+		}
+		if a > hi {
+			hi = a
+		}
+	}
+	nd.offset = lo
+	nd.p = make([]float64, hi-lo+1)
+	nd.m = make([]float64, hi-lo+1)
+	for _, p := range d {
+		i := int(math.Round(p.Mass)) - lo
+		nd.p[i] += p.Abundance
+		nd.m[i] += p.Abundance * p.Mass
+	}
+	return
+}
+
+func (d nominalDistribution) power(n int) nominalDistribution {
+	result := nominalDistribution{p: []float64{1}, m: []float64{0}}
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result = result.convolve(d)
+		}
+		if n > 1 {
+			d = d.convolve(d)
+		}
+	}
+	return result
+}
+
+func (d nominalDistribution) convolve(e nominalDistribution) nominalDistribution {
+	c := nominalDistribution{
+		offset: d.offset + e.offset,
+		p:      make([]float64, len(d.p)+len(e.p)-1),
+		m:      make([]float64, len(d.p)+len(e.p)-1),
+	}
+	for i := range d.p {
+		for j := range e.p {
+			c.p[i+j] += d.p[i] * e.p[j]
+			//the mean mass of a combined bin is the sum of the mean masses
+			c.m[i+j] += d.m[i]*e.p[j] + d.p[i]*e.m[j]
+		}
+	}
+
+	//trim negligible bins at both ends to keep large formulas tractable
+	var max float64
+	for _, p := range c.p {
+		max = math.Max(max, p)
+	}
+	lo, hi := 0, len(c.p)
+	for lo < hi-1 && c.p[lo] < max*nominalNegligible {
+		lo++
+	}
+	for hi > lo+1 && c.p[hi-1] < max*nominalNegligible {
+		hi--
+	}
+	c.offset += lo
+	c.p = c.p[lo:hi]
+	c.m = c.m[lo:hi]
+	return c
+}
+
+func sortedKeys(f Formula) []string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
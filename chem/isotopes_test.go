@@ -0,0 +1,56 @@
+package chem
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAggregatedIsotopes(t *testing.T) {
+	//glucose, from the convolution of the isotopes of every atom
+	want := IsotopeDistribution{
+		{180.06338810220007, 0.9226329790503718},
+		{181.06683050405368, 0.06325579304153776},
+		{182.06800674006172, 0.013220176964309978},
+		{183.07117386947553, 0.0008052668154446277},
+	}
+	got := MustParseFormula("C6H12O6").AggregatedIsotopes(4)
+	if len(got) != len(want) {
+		t.Fatalf("%d peaks, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(got[i].Mass-want[i].Mass) > 1e-9 || math.Abs(got[i].Abundance-want[i].Abundance) > 1e-12 {
+			t.Errorf("peak %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	//a loss shifts the masses by its monoisotopic mass
+	water := MustParseFormula("C6H12O6H-2O-1").AggregatedIsotopes(1)
+	if m := 180.0633881022 - 18.0105646837; math.Abs(water[0].Mass-m) > 1e-9 {
+		t.Errorf("shifted mass %v, want %v", water[0].Mass, m)
+	}
+}
+
+func TestFineIsotopes(t *testing.T) {
+	d := MustParseFormula("C6H12O6").FineIsotopes(0)
+	var sum float64
+	for _, p := range d {
+		sum += p.Abundance
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("abundances sum to %v, want 1", sum)
+	}
+	//the aggregated M+1 peak is the sum of the fine peaks of that nominal mass
+	var m1 float64
+	for _, p := range d {
+		if math.Round(p.Mass) == 181 {
+			m1 += p.Abundance
+		}
+	}
+	if math.Abs(m1-0.06325579304153776) > 1e-9 {
+		t.Errorf("fine M+1 abundance %v, want 0.0632558", m1)
+	}
+	s := d.Spectrum(1)
+	if s[0].I != 1 || math.Abs(s[0].Mz-(180.0633881022-ElectronMass)) > 1e-9 {
+		t.Errorf("first peak of the spectrum is %v", s[0])
+	}
+}
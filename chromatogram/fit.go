@@ -0,0 +1,348 @@
+package chromatogram
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/danhitchcock/ms"
+)
+
+//Model is a peak shape
+type Model int
+
+//The peak models. The exponentially modified Gaussian (EMG) is a Gaussian
+//convolved with an exponential decay, it describes tailing peaks.
+const (
+	Gaussian Model = iota
+	EMG
+)
+
+var modelNames = [...]string{"gaussian", "emg"}
+
+func (m Model) String() string {
+	if m < 0 || int(m) >= len(modelNames) {
+		return "unknown"
+	}
+	return modelNames[m]
+}
+
+//ParseModel returns the model of a name, "gaussian" or "emg"
+func ParseModel(s string) (Model, error) {
+	for m, name := range modelNames {
+		if s == name {
+			return Model(m), nil
+		}
+	}
+	return 0, fmt.Errorf("chromatogram: unknown model %q", s)
+}
+
+//Fit is a least squares fit of a peak model to the intensities above the baseline of a peak
+type Fit struct {
+	Model Model
+	//Area is the area of the model, Center the mean and Sigma the standard
+	//deviation of the Gaussian, Tau the time constant of the decay of an EMG
+	Area   float64
+	Center float64
+	Sigma  float64
+	Tau    float64
+	//AreaErr, CenterErr, SigmaErr and TauErr are the standard errors of the parameters
+	AreaErr   float64
+	CenterErr float64
+	SigmaErr  float64
+	TauErr    float64
+	//RSquared is the coefficient of determination, RMSE the root mean
+	//square of the residuals
+	RSquared float64
+	RMSE     float64
+	//Iterations is the number of iterations, Converged is false if the fit
+	//stopped at the maximal number or no damping decreased the residuals
+	Iterations int
+	Converged  bool
+}
+
+//ErrFit is returned for peaks that cannot be fitted
+var ErrFit = errors.New("chromatogram: cannot fit peak")
+
+//maxIterations bounds the Levenberg-Marquardt iterations
+const maxIterations = 200
+
+//FitPeak fits the model to the intensities above the baseline of a peak of
+//Detect, with the Levenberg-Marquardt algorithm. The parameter errors are
+//estimated from the covariance matrix at the optimum.
+func FitPeak(points []ms.ChromatogramPoint, p Peak, model Model) (Fit, error) {
+	g := segment{p.Start, p.Apex, p.End}
+	all := intensities(points)
+	var t, y []float64
+	for i := p.Start; i <= p.End; i++ {
+		y = append(y, all[i]-baseline(points, all, g, i))
+		t = append(t, points[i].Time)
+	}
+
+	//the initial Gaussian has the width and area of the peak
+	sigma := p.FWHM / (2 * math.Sqrt(2*math.Ln2))
+	if sigma <= 0 {
+		sigma = (p.EndTime - p.StartTime) / 6
+	}
+	area := p.CorrectedArea
+	if area <= 0 {
+		area = p.Height * sigma * math.Sqrt(2*math.Pi)
+	}
+	theta := []float64{area, p.Time, sigma}
+	f := gaussian
+	if model == EMG {
+		//a tailing peak has its apex before the center of mass
+		tau := sigma / 2
+		if p.Asymmetry > 1 {
+			tau = sigma * (p.Asymmetry - 1)
+		}
+		theta = []float64{area, p.Time - tau/2, sigma, tau}
+		f = emg
+	}
+	if len(t) <= len(theta) || sigma <= 0 {
+		return Fit{Model: model}, ErrFit
+	}
+
+	fit, cov, err := levenbergMarquardt(f, t, y, theta)
+	fit.Model = model
+	if err != nil {
+		return fit, err
+	}
+	errs := make([]float64, len(theta))
+	for i := range errs {
+		errs[i] = math.Sqrt(math.Max(cov[i][i], 0))
+	}
+	fit.Area, fit.Center, fit.Sigma = theta[0], theta[1], theta[2]
+	fit.AreaErr, fit.CenterErr, fit.SigmaErr = errs[0], errs[1], errs[2]
+	if model == EMG {
+		fit.Tau, fit.TauErr = theta[3], errs[3]
+	}
+	return fit, nil
+}
+
+//Eval returns the intensity of the model at time t
+func (f Fit) Eval(t float64) float64 {
+	if f.Model == EMG {
+		return emg(t, []float64{f.Area, f.Center, f.Sigma, f.Tau})
+	}
+	return gaussian(t, []float64{f.Area, f.Center, f.Sigma})
+}
+
+//intensities returns the intensities of the points
+func intensities(points []ms.ChromatogramPoint) []float64 {
+	y := make([]float64, len(points))
+	for i, p := range points {
+		y[i] = p.I
+	}
+	return y
+}
+
+//gaussian is the Gaussian of area θ0, mean θ1 and standard deviation θ2
+func gaussian(t float64, theta []float64) float64 {
+	z := (t - theta[1]) / theta[2]
+	return theta[0] / (theta[2] * math.Sqrt(2*math.Pi)) * math.Exp(-z*z/2)
+}
+
+//emg is the EMG of area θ0, Gaussian mean θ1 and standard deviation θ2, and decay time θ3.
+//Before the tail it is written as a Gaussian times the scaled complementary
+//error function, which does not overflow for small decay times, in the tail
+//as the decay times the complementary error function, which does not
+//overflow far from the center.
+func emg(t float64, theta []float64) float64 {
+	area, mu, sigma, tau := theta[0], theta[1], theta[2], theta[3]
+	d := t - mu
+	z := (sigma/tau - d/sigma) / math.Sqrt2
+	if z < 0 {
+		return area / (2 * tau) * math.Exp(sigma*sigma/(2*tau*tau)-d/tau) * math.Erfc(z)
+	}
+	return area / (2 * tau) * math.Exp(-d*d/(2*sigma*sigma)) * erfcx(z)
+}
+
+//erfcx returns exp(x²)·erfc(x) for x ≥ 0
+func erfcx(x float64) float64 {
+	if x < 25 {
+		return math.Exp(x*x) * math.Erfc(x)
+	}
+	//the asymptotic expansion, where erfc underflows
+	x2 := x * x
+	return (1 - 1/(2*x2) + 3/(4*x2*x2)) / (x * math.Sqrt(math.Pi))
+}
+
+//levenbergMarquardt minimizes the squared residuals of f, starting at theta
+//which is updated. The parameters after the first must stay positive, except
+//the second, the center. It returns the fit statistics and covariance matrix.
+func levenbergMarquardt(f func(float64, []float64) float64, t, y, theta []float64) (fit Fit, cov [][]float64, err error) {
+	n, m := len(t), len(theta)
+	ssr := func(theta []float64) (s float64) {
+		for i := range t {
+			r := y[i] - f(t[i], theta)
+			s += r * r
+		}
+		return
+	}
+	valid := func(theta []float64) bool {
+		for k, v := range theta {
+			if k != 1 && !(v > 0) || math.IsNaN(v) || math.IsInf(v, 0) {
+				return false
+			}
+		}
+		return true
+	}
+	//jacobian returns JᵀJ and Jᵀr, from central differences
+	jacobian := func(theta []float64) (a [][]float64, b []float64) {
+		j := make([][]float64, n)
+		for i := range j {
+			j[i] = make([]float64, m)
+		}
+		tmp := append([]float64(nil), theta...)
+		for k := range theta {
+			h := 1e-6 * math.Max(math.Abs(theta[k]), 1e-6)
+			for i := range t {
+				tmp[k] = theta[k] + h
+				up := f(t[i], tmp)
+				tmp[k] = theta[k] - h
+				j[i][k] = (up - f(t[i], tmp)) / (2 * h)
+			}
+			tmp[k] = theta[k]
+		}
+		a = make([][]float64, m)
+		b = make([]float64, m)
+		for k := range a {
+			a[k] = make([]float64, m)
+			for l := range a[k] {
+				for i := range t {
+					a[k][l] += j[i][k] * j[i][l]
+				}
+			}
+			for i := range t {
+				b[k] += j[i][k] * (y[i] - f(t[i], theta))
+			}
+		}
+		return
+	}
+
+	s := ssr(theta)
+	lambda := 1e-3
+	next := make([]float64, m)
+	stalled := false
+	for fit.Iterations < maxIterations && !fit.Converged && !stalled {
+		fit.Iterations++
+		a, b := jacobian(theta)
+		//the damping grows until a step decreases the residuals, or the
+		//step becomes negligible
+		for {
+			damped := make([][]float64, m)
+			for k := range a {
+				damped[k] = append([]float64(nil), a[k]...)
+				damped[k][k] += lambda * a[k][k]
+			}
+			step, ok := solve(damped, append([]float64(nil), b...))
+			if !ok {
+				return fit, nil, ErrFit
+			}
+			small := true
+			for k := range theta {
+				next[k] = theta[k] + step[k]
+				small = small && math.Abs(step[k]) <= 1e-10*math.Max(math.Abs(theta[k]), 1e-10)
+			}
+			if small {
+				fit.Converged = true
+				break
+			}
+			if ns := ssr(next); valid(next) && ns <= s {
+				fit.Converged = s-ns <= 1e-12*s
+				copy(theta, next)
+				s = ns
+				lambda = math.Max(lambda/10, 1e-12)
+				break
+			}
+			if lambda *= 10; lambda > 1e12 {
+				stalled = true
+				break
+			}
+		}
+	}
+
+	a, _ := jacobian(theta)
+	inv, ok := invert(a)
+	if !ok || n <= m {
+		return fit, nil, ErrFit
+	}
+	variance := s / float64(n-m)
+	for k := range inv {
+		for l := range inv[k] {
+			inv[k][l] *= variance
+		}
+	}
+	var mean, sst float64
+	for _, v := range y {
+		mean += v / float64(n)
+	}
+	for _, v := range y {
+		sst += (v - mean) * (v - mean)
+	}
+	if sst > 0 {
+		fit.RSquared = 1 - s/sst
+	}
+	fit.RMSE = math.Sqrt(s / float64(n))
+	return fit, inv, nil
+}
+
+//solve solves a·x = b by Gaussian elimination with partial pivoting, a and b are changed
+func solve(a [][]float64, b []float64) ([]float64, bool) {
+	n := len(b)
+	for c := 0; c < n; c++ {
+		p := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(a[r][c]) > math.Abs(a[p][c]) {
+				p = r
+			}
+		}
+		if a[p][c] == 0 || math.IsNaN(a[p][c]) {
+			return nil, false
+		}
+		a[c], a[p] = a[p], a[c]
+		b[c], b[p] = b[p], b[c]
+		for r := c + 1; r < n; r++ {
+			f := a[r][c] / a[c][c]
+			for k := c; k < n; k++ {
+				a[r][k] -= f * a[c][k]
+			}
+			b[r] -= f * b[c]
+		}
+	}
+	x := make([]float64, n)
+	for r := n - 1; r >= 0; r-- {
+		x[r] = b[r]
+		for k := r + 1; k < n; k++ {
+			x[r] -= a[r][k] * x[k]
+		}
+		x[r] /= a[r][r]
+	}
+	return x, true
+}
+
+//invert returns the inverse of a, solving for the columns of the identity
+func invert(a [][]float64) ([][]float64, bool) {
+	n := len(a)
+	inv := make([][]float64, n)
+	for i := range inv {
+		inv[i] = make([]float64, n)
+	}
+	for c := 0; c < n; c++ {
+		m := make([][]float64, n)
+		for i := range a {
+			m[i] = append([]float64(nil), a[i]...)
+		}
+		e := make([]float64, n)
+		e[c] = 1
+		x, ok := solve(m, e)
+		if !ok {
+			return nil, false
+		}
+		for r := range x {
+			inv[r][c] = x[r]
+		}
+	}
+	return inv, true
+}
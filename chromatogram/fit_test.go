@@ -0,0 +1,78 @@
+package chromatogram
+
+import (
+	"math"
+	"testing"
+
+	"github.com/danhitchcock/ms"
+)
+
+//trace returns the model sampled every step from start to end
+func trace(f Fit, start, end, step float64) []ms.ChromatogramPoint {
+	var points []ms.ChromatogramPoint
+	for i := 0; start+float64(i)*step <= end; i++ {
+		t := start + float64(i)*step
+		points = append(points, ms.ChromatogramPoint{Time: t, I: f.Eval(t), ScanNumber: i + 1})
+	}
+	return points
+}
+
+func TestEMGTail(t *testing.T) {
+	//far in the tail the Gaussian underflows, the EMG is the decay
+	f := Fit{Model: EMG, Area: 100, Center: 1.5, Sigma: 0.01, Tau: 0.2}
+	if got, want := f.Eval(2.0), 100/0.2*math.Exp(0.01*0.01/(2*0.2*0.2)-0.5/0.2); math.Abs(got-want) > 1e-9*want {
+		t.Errorf("Eval(2.0) = %v, want %v", got, want)
+	}
+	//the two forms agree where they meet, at z = 0
+	meet := f.Center + f.Sigma*f.Sigma/f.Tau
+	if a, b := f.Eval(meet-1e-12), f.Eval(meet+1e-12); math.Abs(a-b) > 1e-9*a {
+		t.Errorf("Eval is %v before and %v after the switch", a, b)
+	}
+	for _, tt := range []float64{-10, 0, 1.5, 10, 1000} {
+		if v := f.Eval(tt); math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			t.Errorf("Eval(%v) = %v", tt, v)
+		}
+	}
+}
+
+func TestFitPeak(t *testing.T) {
+	tests := []struct {
+		name       string
+		want       Fit
+		start, end float64
+		step       float64
+	}{
+		{"gaussian", Fit{Model: Gaussian, Area: 1000, Center: 5, Sigma: 0.1}, 4, 6, 0.01},
+		{"emg", Fit{Model: EMG, Area: 1000, Center: 5, Sigma: 0.1, Tau: 0.3}, 4, 9, 0.01},
+		{"emg with tau 20 sigma", Fit{Model: EMG, Area: 100, Center: 1.5, Sigma: 0.01, Tau: 0.2}, 1.3, 5, 0.002},
+	}
+	for _, tt := range tests {
+		points := trace(tt.want, tt.start, tt.end, tt.step)
+		opts := DefaultOptions
+		opts.Threshold = 0
+		p, ok := Highest(Detect(points, opts))
+		if !ok {
+			t.Fatalf("%s: no peak", tt.name)
+		}
+		got, err := FitPeak(points, p, tt.want.Model)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !got.Converged || got.RSquared < 0.9999 {
+			t.Errorf("%s: converged %v with R² %v", tt.name, got.Converged, got.RSquared)
+		}
+		for _, c := range []struct {
+			param     string
+			got, want float64
+		}{
+			{"area", got.Area, tt.want.Area},
+			{"center", got.Center, tt.want.Center},
+			{"sigma", got.Sigma, tt.want.Sigma},
+			{"tau", got.Tau, tt.want.Tau},
+		} {
+			if math.Abs(c.got-c.want) > 1e-3*math.Max(c.want, tt.want.Sigma) {
+				t.Errorf("%s: %s is %v, want %v", tt.name, c.param, c.got, c.want)
+			}
+		}
+	}
+}
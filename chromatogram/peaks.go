@@ -0,0 +1,230 @@
+package chromatogram
+
+import "github.com/danhitchcock/ms"
+
+//Peak is a chromatographic peak, its boundaries are points of the trace
+type Peak struct {
+	//Start, Apex and End are the indices of the first, highest and last point
+	Start int
+	Apex  int
+	End   int
+	//StartTime, Time and EndTime are the retention times of those points
+	StartTime float64
+	Time      float64
+	EndTime   float64
+	//Height is the intensity at the apex, Mz is the m/z of the apex point
+	Height float64
+	Mz     float64
+	//Area is the trapezoid integral of the intensities between the boundaries,
+	//CorrectedArea the part above the baseline, the line between the boundary points
+	Area          float64
+	CorrectedArea float64
+	//FWHM is the full width at half the height above the baseline,
+	//interpolated between the points
+	FWHM float64
+	//Asymmetry is the trailing divided by the leading half width at 10% of the
+	//height, TailingFactor the width at 5% divided by twice its leading half
+	//width (USP). Both are 1 for symmetric peaks and above 1 for tailing peaks.
+	Asymmetry     float64
+	TailingFactor float64
+	//SNR is the height above the baseline divided by the noise of the trace,
+	//it is 0 for traces without noise
+	SNR float64
+	//Points is the number of points across the peak
+	Points int
+}
+
+//Options are the options of Detect
+type Options struct {
+	//Smoothing is the width of the smoothing filter in points, see Smooth
+	Smoothing int
+	//MinHeight is the minimal height above the baseline
+	MinHeight float64
+	//MinSNR is the minimal signal to noise ratio, it is ignored for traces without noise
+	MinSNR float64
+	//MinProminence is the minimal rise of the (smoothed) apex above the higher
+	//boundary as a fraction of the apex. Lower peaks are shoulders or noise, they
+	//are merged into the adjacent peak on the side of that boundary, or dropped.
+	MinProminence float64
+	//Threshold ends peaks where the smoothed trace drops below this fraction of the apex
+	Threshold float64
+	//MinPoints is the minimal number of points across a peak
+	MinPoints int
+}
+
+//DefaultOptions suits XICs of a few points per second
+var DefaultOptions = Options{Smoothing: 5, MinSNR: 5, MinProminence: 0.2, Threshold: 0.01, MinPoints: 3}
+
+//Detect returns the peaks of the trace, in the order of retention time. The
+//points must be sorted by time, such as those of a chromatogram of
+//ms.ExtractXICs with Zeros. A peak spans from the apex of the smoothed trace
+//down to the nearest minimum or threshold on both sides.
+func Detect(points []ms.ChromatogramPoint, opts Options) []Peak {
+	y := intensities(points)
+	s := Smooth(y, opts.Smoothing)
+
+	//every maximum of the smoothed trace (the first point of a plateau) starts a peak
+	var segs []segment
+	for i := range s {
+		if s[i] <= 0 || i > 0 && s[i] <= s[i-1] || i < len(s)-1 && s[i] < s[i+1] {
+			continue
+		}
+		cut := opts.Threshold * s[i]
+		g := segment{i, i, i}
+		for g.start > 0 && s[g.start-1] <= s[g.start] && s[g.start] > cut {
+			g.start--
+		}
+		for g.end < len(s)-1 && s[g.end+1] <= s[g.end] && s[g.end] > cut {
+			g.end++
+		}
+		segs = append(segs, g)
+	}
+
+	//every round merges or drops the least prominent peak, until all are prominent
+	for {
+		worst, min := -1, opts.MinProminence
+		for k, g := range segs {
+			if p := g.prominence(s); p < min {
+				worst, min = k, p
+			}
+		}
+		if worst < 0 {
+			break
+		}
+		g := segs[worst]
+		left := worst > 0 && segs[worst-1].end >= g.start
+		right := worst < len(segs)-1 && segs[worst+1].start <= g.end
+		if left && right {
+			left = s[g.start] >= s[g.end]
+		}
+		switch {
+		case left:
+			segs[worst-1] = segs[worst-1].merge(g, s)
+		case right:
+			segs[worst+1] = g.merge(segs[worst+1], s)
+		}
+		segs = append(segs[:worst], segs[worst+1:]...)
+	}
+
+	noise := Noise(y)
+	var peaks []Peak
+	for _, g := range segs {
+		p := measure(points, y, g, noise)
+		corrected := p.Height - baseline(points, y, g, p.Apex)
+		if corrected < opts.MinHeight || p.Points < opts.MinPoints || noise > 0 && p.SNR < opts.MinSNR {
+			continue
+		}
+		peaks = append(peaks, p)
+	}
+	return peaks
+}
+
+//Highest returns the peak with the greatest height, false if there are none
+func Highest(peaks []Peak) (Peak, bool) {
+	var max Peak
+	for _, p := range peaks {
+		if p.Height > max.Height {
+			max = p
+		}
+	}
+	return max, len(peaks) > 0
+}
+
+//segment is a peak before measuring, given by indices
+type segment struct {
+	start, apex, end int
+}
+
+//prominence returns the rise of the apex above the higher boundary relative to the apex
+func (g segment) prominence(s []float64) float64 {
+	base := s[g.start]
+	if s[g.end] > base {
+		base = s[g.end]
+	}
+	return (s[g.apex] - base) / s[g.apex]
+}
+
+//merge returns the union of adjacent segments g and h, g being the first
+func (g segment) merge(h segment, s []float64) segment {
+	if s[h.apex] > s[g.apex] {
+		g.apex = h.apex
+	}
+	g.end = h.end
+	return g
+}
+
+//baseline returns the intensity of the baseline of the segment at point i
+func baseline(points []ms.ChromatogramPoint, y []float64, g segment, i int) float64 {
+	dt := points[g.end].Time - points[g.start].Time
+	if dt == 0 {
+		return y[g.start]
+	}
+	return y[g.start] + (y[g.end]-y[g.start])*(points[i].Time-points[g.start].Time)/dt
+}
+
+//measure returns the peak of the segment, its apex is the highest point
+func measure(points []ms.ChromatogramPoint, y []float64, g segment, noise float64) Peak {
+	for i := g.start; i <= g.end; i++ {
+		if y[i] > y[g.apex] {
+			g.apex = i
+		}
+	}
+	p := Peak{
+		Start:     g.start,
+		Apex:      g.apex,
+		End:       g.end,
+		StartTime: points[g.start].Time,
+		Time:      points[g.apex].Time,
+		EndTime:   points[g.end].Time,
+		Height:    y[g.apex],
+		Mz:        points[g.apex].Mz,
+		Points:    g.end - g.start + 1,
+	}
+	for i := g.start; i < g.end; i++ {
+		p.Area += (y[i] + y[i+1]) / 2 * (points[i+1].Time - points[i].Time)
+	}
+	p.CorrectedArea = p.Area - (y[g.start]+y[g.end])/2*(p.EndTime-p.StartTime)
+
+	//the widths are measured on the intensities above the baseline,
+	//which are 0 at the boundaries, so every level is crossed within them
+	above := func(i int) float64 { return y[i] - baseline(points, y, g, i) }
+	height := above(g.apex)
+	if height <= 0 {
+		return p
+	}
+	if noise > 0 {
+		p.SNR = height / noise
+	}
+	halfWidths := func(f float64) (lead float64, trail float64) {
+		level := f * height
+		cross := func(i, j int) float64 {
+			//the level lies between point i (below it) and j (above it)
+			ai, aj := above(i), above(j)
+			return points[i].Time + (level-ai)/(aj-ai)*(points[j].Time-points[i].Time)
+		}
+		i := g.apex
+		for i > g.start && above(i) > level {
+			i--
+		}
+		if i < g.apex {
+			lead = p.Time - cross(i, i+1)
+		}
+		i = g.apex
+		for i < g.end && above(i) > level {
+			i++
+		}
+		if i > g.apex {
+			trail = cross(i, i-1) - p.Time
+		}
+		return
+	}
+	a, b := halfWidths(0.5)
+	p.FWHM = a + b
+	if a, b = halfWidths(0.1); a > 0 {
+		p.Asymmetry = b / a
+	}
+	if a, b = halfWidths(0.05); a > 0 {
+		p.TailingFactor = (a + b) / (2 * a)
+	}
+	return p
+}
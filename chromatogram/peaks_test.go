@@ -0,0 +1,120 @@
+package chromatogram
+
+import (
+	"math"
+	"testing"
+
+	"github.com/danhitchcock/ms"
+)
+
+//sumTrace returns the sum of the models sampled every step from start to end
+func sumTrace(start, end, step float64, fits ...Fit) []ms.ChromatogramPoint {
+	points := trace(fits[0], start, end, step)
+	for _, f := range fits[1:] {
+		for i := range points {
+			points[i].I += f.Eval(points[i].Time)
+		}
+	}
+	return points
+}
+
+func TestDetect(t *testing.T) {
+	gaussian := func(area, center, sigma float64) Fit {
+		return Fit{Model: Gaussian, Area: area, Center: center, Sigma: sigma}
+	}
+	//peak is the apex time and area of a detected peak
+	type peak struct{ time, area float64 }
+	tests := []struct {
+		name          string
+		points        []ms.ChromatogramPoint
+		minProminence float64
+		want          []peak
+	}{
+		{"two resolved peaks", sumTrace(1, 5, 0.01, gaussian(1000, 2, 0.1), gaussian(500, 4, 0.1)), 0.2,
+			[]peak{{2, 1000}, {4, 500}}},
+		{"shoulder", sumTrace(2, 4.5, 0.01, gaussian(1000, 3, 0.2), gaussian(30, 3.45, 0.05)), 0,
+			[]peak{{3, 977}, {3.42, 52}}},
+		{"shoulder merged", sumTrace(2, 4.5, 0.01, gaussian(1000, 3, 0.2), gaussian(30, 3.45, 0.05)), 0.2,
+			[]peak{{3, 1030}}},
+		//the trace ends before the peak returns to the baseline
+		{"cut on the tail", sumTrace(2, 3.2, 0.01, gaussian(1000, 3, 0.2)), 0.2,
+			[]peak{{3, 840}}},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions
+		opts.MinProminence = tt.minProminence
+		peaks := Detect(tt.points, opts)
+		if len(peaks) != len(tt.want) {
+			t.Errorf("%s: %d peaks, want %d", tt.name, len(peaks), len(tt.want))
+			continue
+		}
+		for i, p := range peaks {
+			if w := tt.want[i]; math.Abs(p.Time-w.time) > 0.015 || math.Abs(p.Area-w.area) > 0.01*w.area {
+				t.Errorf("%s: peak %d at %v of area %v, want %v of %v", tt.name, i, p.Time, p.Area, w.time, w.area)
+			}
+			if i > 0 && p.Start < peaks[i-1].End {
+				t.Errorf("%s: peak %d starts at %d before the end %d of the previous one", tt.name, i, p.Start, peaks[i-1].End)
+			}
+		}
+	}
+
+	//a peak ends at the last point of a trace that does not return to the baseline
+	points := sumTrace(2, 3.2, 0.01, gaussian(1000, 3, 0.2))
+	if p, ok := Highest(Detect(points, DefaultOptions)); !ok || p.End != len(points)-1 {
+		t.Errorf("peak cut on the tail ends at %d of %d points", p.End, len(points))
+	}
+	//rising traces and plateaus end the search of the boundaries
+	var rising, plateau, flat []ms.ChromatogramPoint
+	for i := 0; i < 50; i++ {
+		pt := ms.ChromatogramPoint{Time: float64(i) / 10, ScanNumber: i + 1}
+		pt.I = float64(i)
+		rising = append(rising, pt)
+		pt.I = math.Min(float64(i), 20)
+		plateau = append(plateau, pt)
+		pt.I = 20
+		flat = append(flat, pt)
+	}
+	for _, points := range [][]ms.ChromatogramPoint{rising, plateau, flat} {
+		for _, p := range Detect(points, DefaultOptions) {
+			if p.Start < 0 || p.Start > p.Apex || p.Apex > p.End || p.End >= len(points) {
+				t.Errorf("peak %d-%d-%d in a trace of %d points", p.Start, p.Apex, p.End, len(points))
+			}
+		}
+	}
+	if peaks := Detect(nil, DefaultOptions); len(peaks) != 0 {
+		t.Errorf("%d peaks in an empty trace", len(peaks))
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		name               string
+		fit                Fit
+		asymmetry, tailing float64
+	}{
+		{"gaussian", Fit{Model: Gaussian, Area: 1000, Center: 5, Sigma: 0.1}, 1, 1},
+		{"emg", Fit{Model: EMG, Area: 1000, Center: 5, Sigma: 0.1, Tau: 0.1}, 1.36, 1.227},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions
+		opts.Threshold = 0
+		p, ok := Highest(Detect(trace(tt.fit, 4, 7, 0.002), opts))
+		if !ok {
+			t.Fatalf("%s: no peak", tt.name)
+		}
+		if tt.fit.Model == Gaussian {
+			if want := 2 * math.Sqrt(2*math.Ln2) * tt.fit.Sigma; math.Abs(p.FWHM-want) > 1e-3*want {
+				t.Errorf("%s: FWHM is %v, want %v", tt.name, p.FWHM, want)
+			}
+			if want := tt.fit.Area / (tt.fit.Sigma * math.Sqrt(2*math.Pi)); math.Abs(p.Height-want) > 1e-3*want {
+				t.Errorf("%s: height is %v, want %v", tt.name, p.Height, want)
+			}
+		}
+		if math.Abs(p.Area-tt.fit.Area) > 1e-3*tt.fit.Area || math.Abs(p.CorrectedArea-p.Area) > 1e-3*p.Area {
+			t.Errorf("%s: area is %v, corrected %v, want %v", tt.name, p.Area, p.CorrectedArea, tt.fit.Area)
+		}
+		if math.Abs(p.Asymmetry-tt.asymmetry) > 0.05*tt.asymmetry || math.Abs(p.TailingFactor-tt.tailing) > 0.05*tt.tailing {
+			t.Errorf("%s: asymmetry %v and tailing factor %v, want %v and %v", tt.name, p.Asymmetry, p.TailingFactor, tt.asymmetry, tt.tailing)
+		}
+	}
+}
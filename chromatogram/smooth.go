@@ -0,0 +1,72 @@
+//Package chromatogram detects and integrates the peaks of chromatographic
+//traces, such as the XICs of ms.ExtractXICs.
+package chromatogram
+
+import (
+	"math"
+	"sort"
+)
+
+//Smooth returns the intensities smoothed with a quadratic Savitzky-Golay
+//filter of width points, which keeps the height and width of peaks better
+//than a moving average. The window shrinks towards the ends of the trace.
+//Even widths are rounded up, and as a quadratic fits 3 points exactly,
+//widths below 5 return a copy.
+func Smooth(y []float64, width int) []float64 {
+	s := make([]float64, len(y))
+	copy(s, y)
+	m := width / 2
+	if m < 1 {
+		return s
+	}
+	for i := range y {
+		h := m
+		if i < h {
+			h = i
+		}
+		if len(y)-1-i < h {
+			h = len(y) - 1 - i
+		}
+		if h == 0 {
+			continue
+		}
+		//the coefficients of the quadratic fit at the centre of 2h+1 points
+		fh := float64(h)
+		norm := (2*fh + 1) * (4*fh*fh + 4*fh - 3) / 3
+		var sum float64
+		for k := -h; k <= h; k++ {
+			sum += ((3*fh*fh + 3*fh - 1) - 5*float64(k*k)) * y[i+k]
+		}
+		s[i] = sum / norm
+	}
+	return s
+}
+
+//Noise estimates the standard deviation of the noise of the intensities,
+//from the median absolute deviation of the differences of consecutive points,
+//which is hardly affected by peaks. It is 0 for traces without noise.
+func Noise(y []float64) float64 {
+	if len(y) < 3 {
+		return 0
+	}
+	d := make([]float64, len(y)-1)
+	for i := range d {
+		d[i] = y[i+1] - y[i]
+	}
+	med := median(d)
+	for i := range d {
+		d[i] = math.Abs(d[i] - med)
+	}
+	//a difference has twice the variance of a point
+	return 1.4826 * median(d) / math.Sqrt2
+}
+
+//median sorts x and returns its median
+func median(x []float64) float64 {
+	sort.Float64s(x)
+	n := len(x)
+	if n%2 == 1 {
+		return x[n/2]
+	}
+	return (x[n/2-1] + x[n/2]) / 2
+}
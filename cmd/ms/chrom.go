@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/danhitchcock/ms"
+)
+
+//trace is the JSON object of a chromatogram
+type trace struct {
+	File      string    `json:"file,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Mz        float64   `json:"mz,omitempty"`
+	Charge    int       `json:"charge,omitempty"`
+	Tol       string    `json:"tol,omitempty"`
+	Scan      []int     `json:"scan,omitempty"`
+	RT        []float64 `json:"rt"`
+	Intensity []float64 `json:"intensity"`
+}
+
+func (t *trace) add(rt float64, intensity float64) {
+	t.RT = append(t.RT, rt)
+	t.Intensity = append(t.Intensity, intensity)
+}
+
+//write writes the trace as lines of retention time and intensity, or as JSON
+func (t *trace) write(o *options, path string) error {
+	if o.format == "json" {
+		if len(o.files) > 1 {
+			t.File = path
+		}
+		return o.writeJSON(t)
+	}
+	for i, rt := range t.RT {
+		fmt.Fprintln(o.out, rt, t.Intensity[i])
+	}
+	return nil
+}
+
+func xic(args []string) error {
+	o := newOptions("xic", "-mz m/z[,m/z...] | -targets file  file...", "Xic prints the extracted ion chromatograms (XICs) of ions: for every scan the\n"+
+		"intensity of the most intense peak within the tolerance of each ion, or with\n"+
+		"-sum that of all those peaks. The ions are the -mz values, or the targets of a\n"+
+		"CSV or TSV file with a header line naming the columns, of which mz or formula\n"+
+		"is required:\n"+
+		"    name, mz, formula, adduct (e.g. [M+Na]+), charge, rt, rtmin, rtmax, tol\n"+
+		"The retention times are in minutes, with -rtwindow the expected rt gives the\n"+
+		"window of targets without rtmin and rtmax.\n"+
+		"All ions are extracted in a single pass over the scans. The table has a column\n"+
+		"per ion, which is empty outside the retention time window of the ion and 0 if\n"+
+		"no peak was found, json gives an object per ion.")
+	o.formatFlag("text", "csv", "tsv", "json")
+	o.tolFlag(ms.PPM(10))
+	o.scanFlags(1)
+	var mzs floats
+	o.fs.Var(&mzs, "mz", "m/z of the ions, repeat the flag or separate them by commas")
+	targetFile := o.fs.String("targets", "", "CSV or TSV `file` of the ions")
+	sum := o.fs.Bool("sum", false, "sum the intensities of the peaks within the tolerance instead of taking the maximum")
+	window := o.fs.Float64("rtwindow", 0, "retention time window in minutes around the expected rt of targets")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	targets, err := loadTargets(mzs, *targetFile, *window)
+	if err != nil {
+		return err
+	}
+	filter, err := o.filter()
+	if err != nil {
+		return err
+	}
+
+	opts := ms.XICOptions{Tol: o.tol, Filter: filter, Sum: *sum, Zeros: true, Workers: runtime.GOMAXPROCS(0)}
+	var table *xicTable
+	if o.format != "json" {
+		table = newXICTable(o, targets)
+	}
+	return o.each(func(path string, file ms.Reader) error {
+		xics := ms.ExtractXICs(file, xicTargets(targets), opts)
+		if table != nil {
+			return table.write(path, xics)
+		}
+		for i, t := range targets {
+			tol := t.Tol
+			if tol == (ms.Tolerance{}) {
+				tol = o.tol
+			}
+			tr := trace{Name: t.ID, Mz: t.Mz, Charge: t.Charge, Tol: tol.String(),
+				Scan: []int{}, RT: []float64{}, Intensity: []float64{}}
+			for _, p := range xics[i].Points {
+				tr.Scan = append(tr.Scan, p.ScanNumber)
+				tr.add(p.Time, p.I)
+			}
+			if err := tr.write(o, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//xicTable writes XICs as a table with a column per target, as aligned text,
+//CSV or TSV. CSV and TSV have a file column if there is more than one file.
+type xicTable struct {
+	o      *options
+	header []string
+	tw     *tabwriter.Writer
+	csv    *csv.Writer
+	file   bool
+}
+
+func newXICTable(o *options, targets []target) *xicTable {
+	t := &xicTable{o: o, header: []string{"scan", "rt"}}
+	for _, tg := range targets {
+		t.header = append(t.header, tg.ID)
+	}
+	if o.format == "text" {
+		return t
+	}
+	t.csv = csv.NewWriter(o.out)
+	if o.format == "tsv" {
+		t.csv.Comma = '\t'
+	}
+	if t.file = len(o.files) > 1; t.file {
+		t.csv.Write(append([]string{"file"}, t.header...))
+	} else {
+		t.csv.Write(t.header)
+	}
+	return t
+}
+
+//write writes the rows of the XICs of a file, a row per scan
+func (t *xicTable) write(path string, xics []ms.Chromatogram) error {
+	if t.csv == nil {
+		t.tw = tabwriter.NewWriter(t.o.out, 0, 8, 1, ' ', 0)
+		fmt.Fprintln(t.tw, strings.Join(t.header, "\t"))
+	}
+	type row struct {
+		time   float64
+		values []string
+	}
+	rows := make(map[int]*row)
+	var numbers []int
+	for i, xic := range xics {
+		for _, p := range xic.Points {
+			r := rows[p.ScanNumber]
+			if r == nil {
+				r = &row{p.Time, make([]string, len(xics))}
+				rows[p.ScanNumber] = r
+				numbers = append(numbers, p.ScanNumber)
+			}
+			r.values[i] = strconv.FormatFloat(p.I, 'g', -1, 64)
+		}
+	}
+	sort.Ints(numbers)
+	for _, n := range numbers {
+		cells := append([]string{strconv.Itoa(n), strconv.FormatFloat(rows[n].time, 'f', 4, 64)}, rows[n].values...)
+		if t.tw != nil {
+			fmt.Fprintln(t.tw, strings.Join(cells, "\t"))
+			continue
+		}
+		if t.file {
+			cells = append([]string{path}, cells...)
+		}
+		t.csv.Write(cells)
+	}
+	if t.tw != nil {
+		return t.tw.Flush()
+	}
+	t.csv.Flush()
+	return t.csv.Error()
+}
+
+func tic(args []string) error {
+	o := newOptions("tic", "file...", "Tic prints the total ion current chromatogram: the retention time and total\n"+
+		"ion current of every scan, by default of the MS1 scans.")
+	o.formatFlag("text", "json")
+	o.scanFlags(1)
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	filter, err := o.filter()
+	if err != nil {
+		return err
+	}
+	return o.each(func(path string, file ms.Reader) error {
+		t := trace{RT: []float64{}, Intensity: []float64{}}
+		for i := 1; i <= file.NScans(); i++ {
+			if scan := file.Scan(i); filter.Matches(scan) {
+				t.add(scan.Time, scan.TotalCurrent)
+			}
+		}
+		return t.write(o, path)
+	})
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/mgf"
+	"github.com/danhitchcock/ms/ms2"
+	"github.com/danhitchcock/ms/mzml"
+	"github.com/danhitchcock/ms/mzxml"
+	"github.com/danhitchcock/ms/ndjson"
+	"github.com/danhitchcock/ms/parquet"
+	"github.com/danhitchcock/ms/sqlite"
+	_ "modernc.org/sqlite"
+)
+
+//extensions are the file extensions of the output formats, parquet writes
+//two files: <name>.scans.parquet and <name>.peaks.parquet
+var extensions = map[string]string{
+	"mzml":    ".mzML",
+	"mzxml":   ".mzXML",
+	"mgf":     ".mgf",
+	"ms1":     ".ms1",
+	"ms2":     ".ms2",
+	"parquet": "",
+	"sqlite":  ".sqlite",
+	"ndjson":  ".ndjson",
+}
+
+//convertOptions are the flags of the output formats
+type convertOptions struct {
+	*options
+	dir         string
+	precision   int
+	zlib        bool
+	numpress    bool
+	peaks       bool
+	compression string
+	rowGroup    int
+	maxCharge   int
+	charges     string
+	minPeaks    int
+	//candidates and codec are the parsed -charges and -compression
+	candidates []int
+	codec      parquet.Compression
+}
+
+func convert(args []string) error {
+	o := convertOptions{options: newOptions("convert", "-format format file...",
+		"Convert writes the runs in another format, next to the input files unless -o or -dir is given.\n"+
+			"Precursor charges that are not in the file are inferred from the isotope peaks in the preceding\n"+
+			"MS1 scan for mgf and ms2, within -tol.")}
+	o.formatFlag("mzml", "mzxml", "mgf", "ms1", "ms2", "parquet", "sqlite", "ndjson")
+	o.outFlag("name of the output file (name prefix for parquet), only with a single input file")
+	o.tolFlag(ms.PPM(10))
+	o.scanFlags(0)
+	o.fs.StringVar(&o.dir, "dir", "", "directory of the output files")
+	o.fs.IntVar(&o.precision, "precision", 64, "mzml, mzxml: bits of the m/z values, 32 or 64")
+	o.fs.BoolVar(&o.zlib, "zlib", false, "mzml, mzxml: compress the binary arrays with zlib")
+	o.fs.BoolVar(&o.numpress, "numpress", false, "mzml: encode the binary arrays with MS-Numpress (linear m/z, slof intensities)")
+	o.fs.BoolVar(&o.peaks, "peaks", false, "parquet, sqlite, ndjson: include the peaks")
+	o.fs.StringVar(&o.compression, "compression", "snappy", "parquet: compression of the data pages: none, snappy or gzip")
+	o.fs.IntVar(&o.rowGroup, "rowgroup", 1<<20, "parquet: number of rows per row group")
+	o.fs.IntVar(&o.maxCharge, "maxcharge", 6, "mgf, ms2: highest charge tried when inferring precursor charges, 0 disables inference")
+	o.fs.StringVar(&o.charges, "charges", "2,3", "ms2: comma separated candidate charges of precursors of which the charge is not known")
+	o.fs.IntVar(&o.minPeaks, "minpeaks", 1, "mgf: minimal number of peaks of an exported scan")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if o.outName != "" && len(o.files) > 1 {
+		return usageError{fmt.Errorf("-o with %d input files", len(o.files))}
+	}
+	if o.precision != 32 && o.precision != 64 {
+		return usageError{fmt.Errorf("-precision must be 32 or 64")}
+	}
+	for _, c := range strings.Split(o.charges, ",") {
+		if c == "" {
+			continue
+		}
+		z, err := strconv.Atoi(c)
+		if err != nil {
+			return usageError{fmt.Errorf("bad charge %q", c)}
+		}
+		o.candidates = append(o.candidates, z)
+	}
+	switch o.compression {
+	case "none":
+		o.codec = parquet.Uncompressed
+	case "snappy":
+		o.codec = parquet.Snappy
+	case "gzip":
+		o.codec = parquet.Gzip
+	default:
+		return usageError{fmt.Errorf("unknown compression %q", o.compression)}
+	}
+	filter, err := o.filter()
+	if err != nil {
+		return err
+	}
+	return o.each(func(path string, file ms.Reader) error {
+		out := o.outName
+		if out == "" {
+			out = strings.TrimSuffix(path, filepath.Ext(path)) + extensions[o.format]
+			if o.dir != "" {
+				out = filepath.Join(o.dir, filepath.Base(out))
+			}
+		}
+		if out == path {
+			return fmt.Errorf("output file is the input file")
+		}
+		switch o.format {
+		case "mzml", "mzxml":
+			return o.writeXML(out, file, filter)
+		case "mgf":
+			return o.writeMGF(out, file, filter)
+		case "ms1", "ms2":
+			return o.writeMS2(out, file)
+		case "parquet":
+			return o.writeParquet(out, file, filter)
+		case "sqlite":
+			if err := os.Remove(out); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			db, err := sql.Open("sqlite", out)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			return sqlite.Export(db, file, sqlite.Options{Filter: filter, Peaks: o.peaks})
+		case "ndjson":
+			return create(out, func(f *os.File) error {
+				return ndjson.Export(f, file, ndjson.Options{Filter: filter, Peaks: o.peaks})
+			})
+		}
+		return nil
+	})
+}
+
+//create creates the file, calls write with it and closes it
+func create(name string, write func(f *os.File) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+//writeXML writes mzML or mzXML. The headers of the selected scans are read
+//first, then the scans are read again one by one while writing.
+func (o *convertOptions) writeXML(out string, file ms.Reader, filter ms.ScanFilter) error {
+	var headers []ms.Scan
+	var numbers []int
+	for i := 1; i <= file.NScans(); i++ {
+		scan := file.Scan(i)
+		if filter.Matches(scan) {
+			scan.Spectrum = nil
+			headers = append(headers, scan)
+			numbers = append(numbers, i)
+		}
+	}
+	return create(out, func(f *os.File) error {
+		if o.format == "mzxml" {
+			w, err := mzxml.NewWriter(f, file.Metadata(), headers, mzxml.Options{Float64: o.precision == 64, Zlib: o.zlib})
+			if err != nil {
+				return err
+			}
+			for _, i := range numbers {
+				if err := w.WriteSpectrum(file.Scan(i)); err != nil {
+					return err
+				}
+			}
+			return w.Close()
+		}
+		opts := mzml.Options{MzFloat32: o.precision == 32}
+		if o.zlib {
+			opts.Compression = mzml.Zlib
+		}
+		if o.numpress {
+			opts.MzNumpress = mzml.NumpressLinear
+			opts.IntensityNumpress = mzml.NumpressSlof
+		}
+		w, err := mzml.NewWriter(f, file.Metadata(), headers, opts)
+		if err != nil {
+			return err
+		}
+		for _, i := range numbers {
+			if err := w.WriteSpectrum(file.Scan(i)); err != nil {
+				return err
+			}
+		}
+		if err := w.WriteChromatograms(file.Chromatograms()); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+}
+
+//writeMGF writes the MSn scans as MGF
+func (o *convertOptions) writeMGF(out string, file ms.Reader, filter ms.ScanFilter) error {
+	return create(out, func(f *os.File) error {
+		w, err := mgf.NewWriter(f)
+		if err != nil {
+			return err
+		}
+		meta := file.Metadata()
+		//ms1 is the spectrum of the last MS1 scan, for inferring charges
+		var ms1 ms.Spectrum
+		for i := 1; i <= file.NScans(); i++ {
+			scan := file.Scan(i)
+			if scan.MSLevel == 1 {
+				ms1 = scan.Spectrum
+				continue
+			}
+			if !filter.Matches(scan) || len(scan.Spectrum) < o.minPeaks {
+				continue
+			}
+			if err := w.Write(mgf.FromScan(meta, scan, o.inferCharge(ms1, scan))); err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	})
+}
+
+//inferCharge returns the charge of the precursor of the MS2 scan from the
+//isotopes in the MS1 spectrum, 0 if it is not found or the instrument
+//already determined it
+func (o *convertOptions) inferCharge(ms1 ms.Spectrum, scan ms.Scan) int {
+	if o.maxCharge <= 0 || len(scan.PrecursorMzs) != 1 {
+		return 0
+	}
+	if len(scan.Precursors) == 1 && scan.Precursors[0].Charge != 0 {
+		return 0
+	}
+	return ms.InferCharge(ms1, scan.PrecursorMzs[0], o.tol, o.maxCharge)
+}
+
+//writeMS2 writes the MS1 or MS2 scans in the MS1 or MS2 text format
+func (o *convertOptions) writeMS2(out string, file ms.Reader) error {
+	level := uint8(1)
+	if o.format == "ms2" {
+		level = 2
+	}
+	filter, err := o.filter()
+	if err != nil {
+		return err
+	}
+	filter.MSLevel = level
+	return create(out, func(f *os.File) error {
+		w, err := ms2.NewWriter(f, ms2.Header(file.Metadata(), strings.ToUpper(o.format)))
+		if err != nil {
+			return err
+		}
+		var ms1 ms.Spectrum
+		for i := 1; i <= file.NScans(); i++ {
+			scan := file.Scan(i)
+			if scan.MSLevel == 1 {
+				ms1 = scan.Spectrum
+			}
+			if !filter.Matches(scan) {
+				continue
+			}
+			zs := o.candidates
+			if z := o.inferCharge(ms1, scan); z != 0 {
+				zs = []int{z}
+			}
+			if err := w.Write(ms2.FromScan(scan, zs...)); err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	})
+}
+
+//writeParquet writes the scans table, and the peaks table with -peaks
+func (o *convertOptions) writeParquet(out string, file ms.Reader, filter ms.ScanFilter) error {
+	opts := parquet.Options{RowGroupSize: o.rowGroup, Compression: o.codec}
+	return create(out+".scans.parquet", func(scans *os.File) error {
+		if !o.peaks {
+			return parquet.Export(scans, nil, file, filter, opts)
+		}
+		return create(out+".peaks.parquet", func(peaks *os.File) error {
+			return parquet.Export(scans, peaks, file, filter, opts)
+		})
+	})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+	_ "github.com/danhitchcock/ms/mzml"
+	_ "github.com/danhitchcock/ms/mzxml"
+	_ "github.com/danhitchcock/ms/unthermo"
+)
+
+//options are the flags shared by the commands, every command registers
+//the ones it uses
+type options struct {
+	fs      *flag.FlagSet
+	formats []string
+
+	format    string
+	tol       ms.Tolerance
+	minTime   float64
+	maxTime   float64
+	level     uint
+	analyzers string
+	scanText  string
+	outName   string
+
+	//files are the input files after expanding the patterns
+	files []string
+	out   io.Writer
+	//combined is true for text output that is one table of all files
+	combined bool
+}
+
+//newOptions returns the options of a command, args describes the arguments
+//after the flags in the usage message
+func newOptions(name string, args string, doc string) *options {
+	o := &options{fs: flag.NewFlagSet(name, flag.ContinueOnError), out: os.Stdout}
+	o.fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ms %s [flags] %s\n\n%s\n\nFlags:\n", name, args, doc)
+		o.fs.PrintDefaults()
+	}
+	return o
+}
+
+//formatFlag registers -format, the first format is the default
+func (o *options) formatFlag(formats ...string) {
+	o.formats = formats
+	o.fs.StringVar(&o.format, "format", formats[0], "output format: "+strings.Join(formats, ", "))
+}
+
+//tolFlag registers -tol
+func (o *options) tolFlag(def ms.Tolerance) {
+	o.tol = def
+	o.fs.Var((*tolerance)(&o.tol), "tol", "m/z tolerance, e.g. 10ppm or 0.02Da")
+}
+
+//timeFlags registers -mintime and -maxtime
+func (o *options) timeFlags() {
+	o.fs.Float64Var(&o.minTime, "mintime", 0, "minimal retention time in minutes")
+	o.fs.Float64Var(&o.maxTime, "maxtime", 0, "maximal retention time in minutes, 0 means no maximum")
+}
+
+//scanFlags registers -level, -analyzer and -filter, and the time flags
+func (o *options) scanFlags(level uint) {
+	o.fs.UintVar(&o.level, "level", level, "MS level of the scans, 0 selects all levels")
+	o.fs.StringVar(&o.analyzers, "analyzer", "", "comma separated analyzers of the scans, e.g. FTMS,ITMS")
+	o.fs.StringVar(&o.scanText, "filter", "", "text in the filter line of the scans, e.g. \"Full ms \"")
+	o.timeFlags()
+}
+
+//outFlag registers -o
+func (o *options) outFlag(usage string) {
+	o.fs.StringVar(&o.outName, "o", "", usage)
+}
+
+//parse parses the flags and expands the file patterns, at least one file is required
+func (o *options) parse(args []string) error {
+	if err := o.fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError{}
+	}
+	if o.formats != nil && !contains(o.formats, o.format) {
+		return usageError{fmt.Errorf("unknown format %q, use one of %s", o.format, strings.Join(o.formats, ", "))}
+	}
+	seen := make(map[string]bool)
+	for _, pattern := range o.fs.Args() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return usageError{err}
+		}
+		if matches == nil {
+			//not a pattern, or one without matches: let opening it report the error
+			matches = []string{pattern}
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				o.files = append(o.files, m)
+			}
+		}
+	}
+	if len(o.files) == 0 {
+		o.fs.Usage()
+		return usageError{fmt.Errorf("no input files")}
+	}
+	return nil
+}
+
+//filter returns the scan filter of the scan flags
+func (o *options) filter() (f ms.ScanFilter, err error) {
+	f = ms.ScanFilter{MSLevel: uint8(o.level), MinTime: o.minTime, MaxTime: o.maxTime, FilterContains: o.scanText}
+	for _, a := range strings.Split(o.analyzers, ",") {
+		if a == "" {
+			continue
+		}
+		an, ok := parseAnalyzer(a)
+		if !ok {
+			return f, usageError{fmt.Errorf("unknown analyzer %q", a)}
+		}
+		f.Analyzers = append(f.Analyzers, an)
+	}
+	return f, nil
+}
+
+func parseAnalyzer(s string) (ms.Analyzer, bool) {
+	for a := ms.ITMS; a <= ms.Undefined; a++ {
+		if strings.EqualFold(s, a.String()) {
+			return a, true
+		}
+	}
+	return ms.Undefined, false
+}
+
+//each opens every file and calls fun with it, text output of more than one
+//file is separated by a "# file" line
+func (o *options) each(fun func(path string, file ms.Reader) error) error {
+	for _, path := range o.files {
+		file, err := ms.Open(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if len(o.files) > 1 && o.format == "text" && !o.combined {
+			fmt.Fprintf(o.out, "# %s\n", path)
+		}
+		err = fun(path, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+//writeJSON writes v as a line of JSON
+func (o *options) writeJSON(v interface{}) error {
+	return json.NewEncoder(o.out).Encode(v)
+}
+
+//tolerance is the flag.Value of an ms.Tolerance
+type tolerance ms.Tolerance
+
+func (t *tolerance) String() string {
+	return ms.Tolerance(*t).String()
+}
+
+func (t *tolerance) Set(s string) error {
+	v, err := ms.ParseTolerance(s)
+	*t = tolerance(v)
+	return err
+}
+
+//floats is a flag.Value of a list of numbers, set by repeating the flag
+//or with comma separated values
+type floats []float64
+
+func (f *floats) String() string {
+	s := make([]string, len(*f))
+	for i, v := range *f {
+		s[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *floats) Set(s string) error {
+	for _, v := range strings.Split(s, ",") {
+		x, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return err
+		}
+		*f = append(*f, x)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/unthermo"
+)
+
+//runInfo is the summary of a file
+type runInfo struct {
+	File string `json:"file"`
+	//Format is e.g. "Thermo RAW", Version is the version of RAW files
+	Format           string     `json:"format"`
+	Version          int        `json:"version,omitempty"`
+	InstrumentVendor string     `json:"instrument_vendor,omitempty"`
+	InstrumentModel  string     `json:"instrument_model,omitempty"`
+	InstrumentSerial string     `json:"instrument_serial,omitempty"`
+	SoftwareVersion  string     `json:"software_version,omitempty"`
+	AcquisitionDate  *time.Time `json:"acquisition_date,omitempty"`
+	SampleID         string     `json:"sample_id,omitempty"`
+	SampleComment    string     `json:"sample_comment,omitempty"`
+	InstrumentMethod string     `json:"instrument_method,omitempty"`
+	//MethodEmbedded is only known for RAW files
+	MethodEmbedded   *bool          `json:"method_embedded,omitempty"`
+	Controllers      []controller   `json:"controllers,omitempty"`
+	StartTime        float64        `json:"start_time"`
+	EndTime          float64        `json:"end_time"`
+	LowMz            float64        `json:"low_mz"`
+	HighMz           float64        `json:"high_mz"`
+	Scans            int            `json:"scans"`
+	ScansPerLevel    map[string]int `json:"scans_per_level"`
+	ScansPerAnalyzer map[string]int `json:"scans_per_analyzer"`
+	Filters          []filterCount  `json:"filters,omitempty"`
+	//SyntheticFilters is set when the filters are synthesized from the scan
+	//events of a RAW file rather than read, they lack the activation
+	SyntheticFilters bool `json:"synthetic_filters,omitempty"`
+}
+
+//controller is a device of a RAW file
+type controller struct {
+	Device    string `json:"device,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Serial    string `json:"serial,omitempty"`
+	MS        bool   `json:"ms"`
+	FirstScan int    `json:"first_scan"`
+	LastScan  int    `json:"last_scan"`
+}
+
+//filterCount is the number of scans of a scan filter
+type filterCount struct {
+	Filter string `json:"filter"`
+	Scans  int    `json:"scans"`
+}
+
+func info(args []string) error {
+	o := newOptions("info", "file...", "Info summarises the runs: the file format (and version of RAW files), acquisition\n"+
+		"date, instrument, sample, retention time and m/z range, the number of scans per MS\n"+
+		"level and analyzer, the scan filters with their number of scans, and for RAW files\n"+
+		"the controllers (devices) and whether the instrument method is embedded. The filters\n"+
+		"of RAW files are synthesized from the scan events and lack the activation.")
+	o.formatFlag("text", "json")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	return o.each(func(path string, file ms.Reader) error {
+		info := summarize(path, file)
+		if o.format == "json" {
+			return o.writeJSON(info)
+		}
+		printInfo(o, info)
+		return nil
+	})
+}
+
+//summarize returns the summary of the file
+func summarize(path string, file ms.Reader) runInfo {
+	meta := file.Metadata()
+	info := runInfo{
+		File:             path,
+		Format:           meta.FileFormat,
+		InstrumentVendor: meta.InstrumentVendor,
+		InstrumentModel:  meta.InstrumentModel,
+		InstrumentSerial: meta.InstrumentSerial,
+		SoftwareVersion:  meta.SoftwareVersion,
+		SampleID:         meta.SampleID,
+		SampleComment:    meta.SampleComment,
+		InstrumentMethod: meta.InstrumentMethod,
+		StartTime:        meta.StartTime,
+		EndTime:          meta.EndTime,
+		LowMz:            meta.LowMz,
+		HighMz:           meta.HighMz,
+		Scans:            file.NScans(),
+		ScansPerLevel:    make(map[string]int),
+		ScansPerAnalyzer: make(map[string]int),
+	}
+	if !meta.AcquisitionDate.IsZero() {
+		info.AcquisitionDate = &meta.AcquisitionDate
+	}
+	if raw, ok := file.(*unthermo.File); ok {
+		info.Version = int(raw.Version())
+		embedded := raw.MethodEmbedded()
+		info.MethodEmbedded = &embedded
+		info.SyntheticFilters = true
+		for _, c := range raw.Controllers() {
+			info.Controllers = append(info.Controllers, controller(c))
+		}
+	}
+
+	filters := make(map[string]int)
+	for i := 1; i <= file.NScans(); i++ {
+		scan := file.Scan(i)
+		info.ScansPerLevel["ms"+strconv.Itoa(int(scan.MSLevel))]++
+		info.ScansPerAnalyzer[scan.Analyzer.String()]++
+		if scan.Filter != "" {
+			filters[scan.Filter]++
+		}
+	}
+	for f, n := range filters {
+		info.Filters = append(info.Filters, filterCount{f, n})
+	}
+	//most frequent first
+	sort.Slice(info.Filters, func(i, j int) bool {
+		a, b := info.Filters[i], info.Filters[j]
+		return a.Scans > b.Scans || a.Scans == b.Scans && a.Filter < b.Filter
+	})
+	return info
+}
+
+func printInfo(o *options, info runInfo) {
+	line := func(key string, format string, a ...interface{}) {
+		fmt.Fprintf(o.out, "%-18s %s\n", key+":", fmt.Sprintf(format, a...))
+	}
+	line("File", "%s", info.File)
+	if info.Version != 0 {
+		line("Format", "%s, version %d", info.Format, info.Version)
+	} else {
+		line("Format", "%s", info.Format)
+	}
+	if info.AcquisitionDate != nil {
+		line("Acquired", "%s", info.AcquisitionDate.Format("2006-01-02 15:04:05"))
+	}
+	if info.InstrumentModel != "" || info.InstrumentVendor != "" {
+		line("Instrument", "%s", strings.TrimSpace(info.InstrumentVendor+" "+info.InstrumentModel))
+	}
+	if info.InstrumentSerial != "" {
+		line("Serial number", "%s", info.InstrumentSerial)
+	}
+	if info.SoftwareVersion != "" {
+		line("Software", "%s", info.SoftwareVersion)
+	}
+	if info.SampleID != "" {
+		line("Sample", "%s", info.SampleID)
+	}
+	if info.SampleComment != "" {
+		line("Comment", "%s", info.SampleComment)
+	}
+	if info.InstrumentMethod != "" {
+		line("Method", "%s", info.InstrumentMethod)
+	}
+	if info.MethodEmbedded != nil {
+		embedded := "no"
+		if *info.MethodEmbedded {
+			embedded = "yes"
+		}
+		line("Method embedded", "%s", embedded)
+	}
+	for i, c := range info.Controllers {
+		key := ""
+		if i == 0 {
+			key = "Controllers:"
+		}
+		desc := strings.TrimSpace(c.Device + " " + c.Model)
+		if c.Serial != "" {
+			desc += " (SN " + c.Serial + ")"
+		}
+		if c.MS {
+			desc += fmt.Sprintf(", MS scans %d-%d", c.FirstScan, c.LastScan)
+		}
+		fmt.Fprintf(o.out, "%-18s %s\n", key, desc)
+	}
+	line("Retention time", "%.2f-%.2f min", info.StartTime, info.EndTime)
+	line("m/z range", "%.2f-%.2f", info.LowMz, info.HighMz)
+	line("Scans", "%d", info.Scans)
+	line("  per MS level", "%s", counts(info.ScansPerLevel))
+	line("  per analyzer", "%s", counts(info.ScansPerAnalyzer))
+	if len(info.Filters) > 0 {
+		if info.SyntheticFilters {
+			fmt.Fprintln(o.out, "Scan filters (synthesized, without activation):")
+		} else {
+			fmt.Fprintln(o.out, "Scan filters:")
+		}
+		for _, f := range info.Filters {
+			fmt.Fprintf(o.out, "  %8d  %s\n", f.Scans, f.Filter)
+		}
+	}
+}
+
+//counts formats the counts sorted by key, e.g. "ms1: 10, ms2: 30"
+func counts(m map[string]int) string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s := make([]string, len(keys))
+	for i, k := range keys {
+		s[i] = fmt.Sprintf("%s: %d", k, m[k])
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,101 @@
+/*Command ms reads Thermo RAW, mzML and mzXML files.
+
+  Usage:
+      ms <command> [flags] file...
+
+  The commands are:
+      info       print file, instrument and run information
+      scans      list the scans
+      spectrum   print the spectrum of a scan
+      xic        print extracted ion chromatograms of m/z values or a target list
+      tic        print the total ion current chromatogram
+      convert    convert to mzML, mzXML, MGF, MS1/MS2, Parquet, SQLite or NDJSON
+      quant      merge iTRAQ or TMT reporter ions of HCD scans into CID scans
+      peakstats  measure the XIC peaks of targets in a QC table
+
+  The files may be glob patterns, e.g. 'data/*.raw', which is useful
+  on shells that do not expand them. The common flags are the same for
+  every command: -tol (e.g. 10ppm or 0.02Da), -mintime and -maxtime in
+  minutes, -level and -analyzer to select scans, and -format for the
+  output (text or json). "ms <command> -h" lists the flags of a command.
+
+  Example:
+      ms xic -mz 361.1466 -tol 5ppm -format json data/*.raw
+
+  ms exits with status 1 on errors and 2 on bad usage.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+//command is a subcommand of ms
+type command struct {
+	name  string
+	short string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"info", "print file, instrument and run information", info},
+	{"scans", "list the scans", scans},
+	{"spectrum", "print the spectrum of a scan", spectrum},
+	{"xic", "print extracted ion chromatograms of m/z values or a target list", xic},
+	{"tic", "print the total ion current chromatogram", tic},
+	{"convert", "convert to mzML, mzXML, MGF, MS1/MS2, Parquet, SQLite or NDJSON", convert},
+	{"quant", "merge iTRAQ or TMT reporter ions of HCD scans into CID scans", quant},
+	{"peakstats", "measure the XIC peaks of targets in a QC table", peakstats},
+}
+
+//usageError is an error in the command line, it is nil if the flag
+//package reported it already
+type usageError struct {
+	error
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, "Usage: ms <command> [flags] file...\n\nThe commands are:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "    %-10s %s\n", c.name, c.short)
+	}
+	fmt.Fprint(os.Stderr, "\nRun \"ms <command> -h\" for the flags of a command.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage()
+		return
+	}
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		err := c.run(os.Args[2:])
+		var ue usageError
+		switch {
+		case err == nil:
+		case errors.Is(err, flag.ErrHelp):
+		case errors.As(err, &ue):
+			if ue.error != nil {
+				fmt.Fprintf(os.Stderr, "ms %s: %v\n", name, ue.error)
+			}
+			os.Exit(2)
+		default:
+			fmt.Fprintf(os.Stderr, "ms %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "ms: unknown command %q\n", name)
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"runtime"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/chromatogram"
+)
+
+//peakStats is the row of a target in a file, the peak values are 0 if
+//no peak was found
+type peakStats struct {
+	File       string  `json:"file"`
+	Name       string  `json:"name"`
+	Mz         float64 `json:"mz"`
+	ExpectedRT float64 `json:"expected_rt,omitempty"`
+	Found      bool    `json:"found"`
+	RT         float64 `json:"rt"`
+	//RTDelta is the apex minus the expected retention time
+	RTDelta   float64 `json:"rt_delta"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+	Height    float64 `json:"height"`
+	//Area is the area above the baseline
+	Area float64 `json:"area"`
+	FWHM float64 `json:"fwhm"`
+	//PPM is the mass error of the apex
+	PPM     float64 `json:"ppm"`
+	Points  int     `json:"points"`
+	SNR     float64 `json:"snr"`
+	Tailing float64 `json:"tailing_factor"`
+	//Fit is the fit of the peak model of -fit, if it succeeded
+	Fit *fitStats `json:"fit,omitempty"`
+}
+
+//fitStats is the JSON object of a chromatogram.Fit
+type fitStats struct {
+	Model     string  `json:"model"`
+	Area      float64 `json:"area"`
+	AreaErr   float64 `json:"area_err"`
+	Center    float64 `json:"center"`
+	CenterErr float64 `json:"center_err"`
+	Sigma     float64 `json:"sigma"`
+	SigmaErr  float64 `json:"sigma_err"`
+	Tau       float64 `json:"tau,omitempty"`
+	TauErr    float64 `json:"tau_err,omitempty"`
+	RSquared  float64 `json:"r_squared"`
+	RMSE      float64 `json:"rmse"`
+	Converged bool    `json:"converged"`
+}
+
+var peakStatsHeader = []string{"file", "name", "mz", "expected_rt", "rt", "rt_delta", "start_time", "end_time",
+	"height", "area", "fwhm", "ppm", "points", "snr", "tailing_factor"}
+
+//fitHeader are the columns of the fit, after those of peakStatsHeader
+var fitHeader = []string{"fit_area", "fit_area_err", "fit_center", "fit_center_err", "fit_sigma", "fit_sigma_err",
+	"fit_tau", "fit_tau_err", "fit_r2"}
+
+//cells returns the values of the columns of peakStatsHeader, and of fitHeader
+//if fit is true. The peak values are empty if no peak was found.
+func (s peakStats) cells(fit bool) []string {
+	f := func(v float64, prec int) string { return strconv.FormatFloat(v, 'f', prec, 64) }
+	c := []string{s.File, s.Name, f(s.Mz, 5), "", "", "", "", "", "", "", "", "", "", "", ""}
+	if s.ExpectedRT != 0 {
+		c[3] = f(s.ExpectedRT, 3)
+	}
+	if s.Found {
+		copy(c[4:], []string{f(s.RT, 3), "", f(s.StartTime, 3), f(s.EndTime, 3), strconv.FormatFloat(s.Height, 'g', 5, 64),
+			strconv.FormatFloat(s.Area, 'g', 5, 64), f(s.FWHM, 4), f(s.PPM, 2), strconv.Itoa(s.Points), f(s.SNR, 1), f(s.Tailing, 2)})
+		if s.ExpectedRT != 0 {
+			c[5] = f(s.RTDelta, 3)
+		}
+	}
+	if !fit {
+		return c
+	}
+	fc := make([]string, len(fitHeader))
+	if s.Fit != nil {
+		g := func(v float64) string { return strconv.FormatFloat(v, 'g', 4, 64) }
+		copy(fc, []string{g(s.Fit.Area), g(s.Fit.AreaErr), f(s.Fit.Center, 3), g(s.Fit.CenterErr), g(s.Fit.Sigma), g(s.Fit.SigmaErr),
+			"", "", f(s.Fit.RSquared, 4)})
+		if s.Fit.Model == chromatogram.EMG.String() {
+			fc[6], fc[7] = g(s.Fit.Tau), g(s.Fit.TauErr)
+		}
+	}
+	return append(c, fc...)
+}
+
+func peakstats(args []string) error {
+	o := newOptions("peakstats", "-mz m/z[,m/z...] | -targets file  file...", "Peakstats measures the highest chromatographic peak in the extracted ion\n"+
+		"chromatogram (XIC) of every target in every file, for system suitability QC. The\n"+
+		"targets are the -mz values, or those of a target list as for xic, whose columns\n"+
+		"are name, mz, formula, adduct, charge, rt, rtmin, rtmax and tol.\n"+
+		"The result is one table of all files with the apex retention time and its\n"+
+		"deviation from the expected rt, the peak boundaries, the height, area above the\n"+
+		"baseline, full width at half maximum (FWHM), mass error of the apex in ppm, the\n"+
+		"points across the peak, signal to noise ratio and USP tailing factor. Targets\n"+
+		"without a peak have empty values. Times are in minutes.\n"+
+		"With -fit, a Gaussian or exponentially modified Gaussian (EMG) is fitted to the\n"+
+		"peak above the baseline, which adds the area, center, sigma and the decay time\n"+
+		"tau of the model with their standard errors, and the R² of the fit.")
+	o.formatFlag("text", "csv", "tsv", "json")
+	o.tolFlag(ms.PPM(10))
+	o.scanFlags(1)
+	var mzs floats
+	o.fs.Var(&mzs, "mz", "m/z of the ions, repeat the flag or separate them by commas")
+	targetFile := o.fs.String("targets", "", "CSV or TSV `file` of the targets")
+	window := o.fs.Float64("rtwindow", 0, "retention time window in minutes around the expected rt of targets")
+	peakOpts := chromatogram.DefaultOptions
+	o.fs.IntVar(&peakOpts.Smoothing, "smooth", peakOpts.Smoothing, "width of the smoothing filter in scans")
+	o.fs.Float64Var(&peakOpts.MinSNR, "minsnr", peakOpts.MinSNR, "minimal signal to noise ratio of peaks")
+	fitName := o.fs.String("fit", "", "peak `model` to fit: gaussian or emg")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	var fit *chromatogram.Model
+	if *fitName != "" {
+		m, err := chromatogram.ParseModel(*fitName)
+		if err != nil {
+			return usageError{err}
+		}
+		fit = &m
+	}
+	targets, err := loadTargets(mzs, *targetFile, *window)
+	if err != nil {
+		return err
+	}
+	filter, err := o.filter()
+	if err != nil {
+		return err
+	}
+	o.combined = true
+
+	header := peakStatsHeader
+	if fit != nil {
+		header = append(header[:len(header):len(header)], fitHeader...)
+	}
+	var tw *tabwriter.Writer
+	var cw *csv.Writer
+	switch o.format {
+	case "text":
+		tw = tabwriter.NewWriter(o.out, 0, 8, 2, ' ', 0)
+		writeTabbed(tw, header)
+	case "csv", "tsv":
+		cw = csv.NewWriter(o.out)
+		if o.format == "tsv" {
+			cw.Comma = '\t'
+		}
+		cw.Write(header)
+	}
+	xicOpts := ms.XICOptions{Tol: o.tol, Filter: filter, Zeros: true, Workers: runtime.GOMAXPROCS(0)}
+	err = o.each(func(path string, file ms.Reader) error {
+		xics := ms.ExtractXICs(file, xicTargets(targets), xicOpts)
+		for i, t := range targets {
+			s := measureTarget(t, xics[i], peakOpts, fit)
+			s.File = path
+			switch {
+			case tw != nil:
+				writeTabbed(tw, s.cells(fit != nil))
+			case cw != nil:
+				cw.Write(s.cells(fit != nil))
+			default:
+				if err := o.writeJSON(s); err != nil {
+					return err
+				}
+			}
+		}
+		if cw != nil {
+			cw.Flush()
+			return cw.Error()
+		}
+		return nil
+	})
+	if tw != nil {
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+//measureTarget returns the statistics of the highest peak of the XIC of the
+//target, and the fit of the model if it is not nil
+func measureTarget(t target, xic ms.Chromatogram, opts chromatogram.Options, model *chromatogram.Model) peakStats {
+	s := peakStats{Name: t.ID, Mz: t.Mz, ExpectedRT: t.RT}
+	p, ok := chromatogram.Highest(chromatogram.Detect(xic.Points, opts))
+	if !ok {
+		return s
+	}
+	s.Found = true
+	s.RT, s.StartTime, s.EndTime = p.Time, p.StartTime, p.EndTime
+	if t.RT != 0 {
+		s.RTDelta = p.Time - t.RT
+	}
+	s.Height, s.Area, s.FWHM = p.Height, p.CorrectedArea, p.FWHM
+	s.PPM = ms.PPMError(t.Mz, p.Mz)
+	s.Points, s.SNR, s.Tailing = p.Points, p.SNR, p.TailingFactor
+	if model == nil {
+		return s
+	}
+	if f, err := chromatogram.FitPeak(xic.Points, p, *model); err == nil {
+		s.Fit = &fitStats{Model: f.Model.String(), Area: f.Area, AreaErr: f.AreaErr, Center: f.Center, CenterErr: f.CenterErr,
+			Sigma: f.Sigma, SigmaErr: f.SigmaErr, Tau: f.Tau, TauErr: f.TauErr, RSquared: f.RSquared, RMSE: f.RMSE, Converged: f.Converged}
+	}
+	return s
+}
+
+//writeTabbed writes a line of cells to a tabwriter, empty cells as "-"
+func writeTabbed(tw *tabwriter.Writer, cells []string) {
+	for i, c := range cells {
+		if c == "" {
+			c = "-"
+		}
+		if i > 0 {
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprint(tw, c)
+	}
+	fmt.Fprintln(tw)
+}
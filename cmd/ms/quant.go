@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/isobaric"
+	"github.com/danhitchcock/ms/mgf"
+)
+
+func quant(args []string) error {
+	o := newOptions("quant", "file...", "Quant prints the MS2 scans with the reporter ions of an isobaric labeling\n"+
+		"reagent as MGF. The reporter ions of the HCD scans of a precursor are inserted\n"+
+		"in the CID, ETD or ECD scans of the same precursor, within -tol, summed if\n"+
+		"there are more HCD scans. With SPS-MS3 the reporter ions are taken from the\n"+
+		"HCD MS3 scans instead. HCD scans of precursors without a CID scan, such as in\n"+
+		"HCD only methods, are printed themselves, and so are scans of other activations.\n"+
+		"Scans of unknown activation, such as those of RAW files, are taken for CID scans\n"+
+		"if they are of the ion trap and for HCD scans if they are of the Orbitrap (FTMS).\n"+
+		"The reagents are:\n"+
+		"    "+reagentNames()+"\n"+
+		"The N and C channels of TMT 10 and up are 6 mDa apart, the tolerance must\n"+
+		"separate them, e.g. 10ppm at high resolution.")
+	o.tolFlag(ms.PPM(10))
+	reagentName := o.fs.String("reagent", "iTRAQ4", "isobaric labeling `reagent`")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	reagent, ok := isobaric.ReagentByName(*reagentName)
+	if !ok {
+		return usageError{fmt.Errorf("unknown reagent %q, use one of %s", *reagentName, reagentNames())}
+	}
+	if err := reagent.Check(o.tol); err != nil {
+		return usageError{err}
+	}
+	w, err := mgf.NewWriter(o.out)
+	if err != nil {
+		return err
+	}
+	err = o.each(func(path string, file ms.Reader) error {
+		return extendCIDScans(w, file, reagent, o.tol)
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+//reagentNames returns the names of the known reagents
+func reagentNames() string {
+	names := make([]string, len(isobaric.Reagents))
+	for i, r := range isobaric.Reagents {
+		names[i] = r.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+//precursorGroup holds the MSn scans of a precursor between MS1 scans
+type precursorGroup struct {
+	mz float64
+	//ids are the scans for identification (CID, ETD or ECD), hcd the HCD MS2 scans
+	ids []ms.Scan
+	hcd []ms.Scan
+	//reports are the summed reporter peaks of the HCD MS2 scans, or of the
+	//HCD MS3 scans if sps is true
+	reports ms.Spectrum
+	sps     bool
+}
+
+//add sums the reporter peaks of a scan
+func (g *precursorGroup) add(peaks ms.Spectrum) {
+	if g.reports == nil {
+		g.reports = peaks
+		return
+	}
+	for i := range peaks {
+		g.reports[i] = addPeaks(g.reports[i], peaks[i])
+	}
+}
+
+//extendCIDScans merges the reporter ion peaks of HCD scans, MS2 or SPS-MS3,
+//into the identification scans of the same precursor and writes the merged
+//spectra, and the HCD MS2 scans of precursors without identification scan.
+//The scans are told apart by their activation, those of other or unknown
+//activations, such as of RAW files, are written as they are.
+func extendCIDScans(w *mgf.Writer, file ms.Reader, reagent isobaric.Reagent, tol ms.Tolerance) error {
+	var groups []*precursorGroup
+	var others []ms.Scan
+	meta := file.Metadata()
+	//ms1 is the spectrum of the MS1 scan preceding the MS2 scans, for inferring charges
+	var ms1 ms.Spectrum
+
+	//group returns the group of the first precursor m/z within the tolerance
+	//of one of a group, nil if there is none and create is false
+	group := func(mzs []float64, create bool) *precursorGroup {
+		for _, mz := range mzs {
+			min, max := tol.Window(mz)
+			for _, g := range groups {
+				if g.mz >= min && g.mz <= max {
+					return g
+				}
+			}
+		}
+		if !create {
+			return nil
+		}
+		g := &precursorGroup{mz: mzs[0]}
+		groups = append(groups, g)
+		return g
+	}
+
+	//flush writes the scans in the order of their scan numbers
+	flush := func() error {
+		scans := others
+		for _, g := range groups {
+			for _, scan := range g.ids {
+				//the reporter peaks are appended, missing reporters are left out
+				scan.Spectrum = append(ms.Spectrum(nil), scan.Spectrum...)
+				for _, peak := range g.reports {
+					if peak.I > 0 {
+						scan.Spectrum = append(scan.Spectrum, peak)
+					}
+				}
+				sort.Sort(scan.Spectrum)
+				scans = append(scans, scan)
+			}
+			//HCD MS2 scans without identification scan are printed themselves,
+			//SPS-MS3 scans are not fit for identification
+			if len(g.ids) == 0 && !g.sps {
+				scans = append(scans, g.hcd...)
+			}
+		}
+		sort.Slice(scans, func(i, j int) bool { return scans[i].Number < scans[j].Number })
+		for _, scan := range scans {
+			charge := 0
+			if len(scan.Precursors) == 0 || scan.Precursors[0].Charge == 0 {
+				charge = ms.InferCharge(ms1, scan.PrecursorMzs[0], ms.PPM(10), 6)
+			}
+			if err := w.Write(mgf.FromScan(meta, scan, charge)); err != nil {
+				return err
+			}
+		}
+		groups, others = nil, nil
+		return nil
+	}
+
+	for i := 1; i <= file.NScans(); i++ {
+		scan := file.Scan(i)
+		if scan.MSLevel == 1 {
+			if err := flush(); err != nil {
+				return err
+			}
+			ms1 = scan.Spectrum
+			continue
+		}
+		if len(scan.PrecursorMzs) == 0 {
+			continue
+		}
+		//the activation of the last precursor made the fragments
+		activation := ms.UnknownActivation
+		if n := len(scan.Precursors); n > 0 {
+			activation = scan.Precursors[n-1].Activation
+		}
+		if activation == ms.UnknownActivation {
+			activation = analyzerActivation(scan.Analyzer)
+		}
+		switch {
+		case scan.MSLevel == 2 && activation == ms.HCD:
+			g := group(scan.PrecursorMzs[:1], true)
+			g.add(reagent.Peaks(scan.Spectrum, tol))
+			g.hcd = append(g.hcd, scan)
+		case scan.MSLevel == 2 && (activation == ms.CID || activation == ms.ETD || activation == ms.ECD):
+			g := group(scan.PrecursorMzs[:1], true)
+			g.ids = append(g.ids, scan)
+		case scan.MSLevel == 3 && activation == ms.HCD:
+			//the precursors of an SPS-MS3 scan are the precursor of the MS2
+			//scan and the fragments selected from it
+			if g := group(scan.PrecursorMzs, false); g != nil {
+				if !g.sps {
+					g.sps, g.reports = true, nil
+				}
+				g.add(reagent.Peaks(scan.Spectrum, tol))
+			}
+		case scan.MSLevel == 2:
+			others = append(others, scan)
+		}
+	}
+	return flush()
+}
+
+//analyzerActivation returns the usual activation of the scans of the analyzer
+//in methods with reporter ions: CID in the ion trap and HCD for the Orbitrap
+func analyzerActivation(a ms.Analyzer) ms.Activation {
+	switch a {
+	case ms.ITMS:
+		return ms.CID
+	case ms.FTMS:
+		return ms.HCD
+	}
+	return ms.UnknownActivation
+}
+
+//addPeaks returns the peak of the summed intensity, at the m/z of the more intense peak
+func addPeaks(a ms.Peak, b ms.Peak) ms.Peak {
+	if b.I > a.I {
+		a.Mz = b.Mz
+	}
+	a.I += b.I
+	return a
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/isobaric"
+	"github.com/danhitchcock/ms/mgf"
+)
+
+//memReader is an ms.Reader of scans in memory
+type memReader []ms.Scan
+
+func (r memReader) NScans() int                      { return len(r) }
+func (r memReader) Scan(sn int) ms.Scan              { return r[sn-1] }
+func (r memReader) Spectrum(sn int) ms.Spectrum      { return r[sn-1].Spectrum }
+func (r memReader) Metadata() ms.Metadata            { return ms.Metadata{} }
+func (r memReader) Chromatograms() []ms.Chromatogram { return nil }
+func (r memReader) Close() error                     { return nil }
+
+func TestExtendCIDScans(t *testing.T) {
+	reagent, _ := isobaric.ReagentByName("TMT6")
+	//reporters returns a spectrum with the reporter ions of intensity i
+	reporters := func(i float32) ms.Spectrum {
+		var s ms.Spectrum
+		for _, r := range reagent.Reporters {
+			s = append(s, ms.Peak{Mz: r.Mz, I: i})
+		}
+		return s
+	}
+	fragment := ms.Spectrum{{Mz: 300, I: 100}}
+	ms1 := func(sn int) ms.Scan { return ms.Scan{Number: sn, MSLevel: 1, Analyzer: ms.FTMS} }
+	msn := func(sn int, level uint8, a ms.Analyzer, act ms.Activation, s ms.Spectrum, mzs ...float64) ms.Scan {
+		scan := ms.Scan{Number: sn, MSLevel: level, Analyzer: a, Spectrum: s, PrecursorMzs: mzs}
+		for _, mz := range mzs {
+			scan.Precursors = append(scan.Precursors, ms.Precursor{Mz: mz, Charge: 2, Activation: act})
+		}
+		return scan
+	}
+	run := memReader{
+		//RAW files: the activation is unknown, the analyzer tells
+		ms1(1),
+		msn(2, 2, ms.ITMS, ms.UnknownActivation, fragment, 500),
+		msn(3, 2, ms.FTMS, ms.UnknownActivation, reporters(7), 500),
+		//a CID scan with two HCD scans of the precursor
+		ms1(4),
+		msn(5, 2, ms.ITMS, ms.CID, fragment, 600),
+		msn(6, 2, ms.FTMS, ms.HCD, reporters(10), 600),
+		msn(7, 2, ms.FTMS, ms.HCD, reporters(5), 600.001),
+		//HCD only
+		ms1(8),
+		msn(9, 2, ms.FTMS, ms.HCD, append(reporters(4), fragment...), 700),
+		msn(10, 2, ms.FTMS, ms.HCD, fragment, 800),
+		//SPS-MS3: the reporter ions of the MS3 scan replace those of the MS2 scan
+		ms1(11),
+		msn(12, 2, ms.ITMS, ms.CID, fragment, 900),
+		msn(13, 2, ms.FTMS, ms.HCD, reporters(10), 900),
+		msn(14, 3, ms.FTMS, ms.HCD, reporters(3), 900, 300),
+		//other activations are printed as they are
+		ms1(15),
+		msn(16, 2, ms.TOFMS, ms.UnknownActivation, fragment, 1000),
+	}
+	want := map[string]ms.Spectrum{
+		"2":  append(reporters(7), fragment...),
+		"5":  append(reporters(15), fragment...),
+		"9":  append(reporters(4), fragment...),
+		"10": fragment,
+		"12": append(reporters(3), fragment...),
+		"16": fragment,
+	}
+	order := []string{"2", "5", "9", "10", "12", "16"}
+
+	var out bytes.Buffer
+	w, err := mgf.NewWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := extendCIDScans(w, run, reagent, ms.PPM(10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	r := mgf.NewReader(&out)
+	var got []string
+	for {
+		s, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s.Scans)
+		if w, ok := want[s.Scans]; ok && !reflect.DeepEqual(s.Peaks, w) {
+			t.Errorf("scan %s has peaks %v, want %v", s.Scans, s.Peaks, w)
+		}
+	}
+	if !reflect.DeepEqual(got, order) {
+		t.Errorf("scans %v, want %v", got, order)
+	}
+}
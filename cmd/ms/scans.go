@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/ndjson"
+)
+
+func scans(args []string) error {
+	o := newOptions("scans", "file...", "Scans lists the scans, as a table or as a JSON object per scan (NDJSON).")
+	o.formatFlag("text", "json")
+	o.scanFlags(0)
+	peaks := o.fs.Bool("peaks", false, "include the peaks in json output")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	filter, err := o.filter()
+	if err != nil {
+		return err
+	}
+	return o.each(func(path string, file ms.Reader) error {
+		var tw *tabwriter.Writer
+		if o.format == "text" {
+			tw = tabwriter.NewWriter(o.out, 0, 8, 1, ' ', 0)
+			fmt.Fprintln(tw, "scan\trt\tlevel\tanalyzer\tprecursor\ttic\tpeaks\tfilter")
+		}
+		for i := 1; i <= file.NScans(); i++ {
+			scan := file.Scan(i)
+			if !filter.Matches(scan) {
+				continue
+			}
+			if tw == nil {
+				s := ndjson.FromScan(scan, *peaks)
+				if len(o.files) > 1 {
+					s.File = path
+				}
+				if err := o.writeJSON(s); err != nil {
+					return err
+				}
+				continue
+			}
+			precursor := ""
+			if n := len(scan.PrecursorMzs); n > 0 {
+				precursor = fmt.Sprintf("%.4f", scan.PrecursorMzs[n-1])
+			}
+			fmt.Fprintf(tw, "%d\t%.4f\t%d\t%v\t%s\t%.4g\t%d\t%s\n", scan.Number, scan.Time, scan.MSLevel,
+				scan.Analyzer, precursor, scan.TotalCurrent, len(scan.Spectrum), scan.Filter)
+		}
+		if tw != nil {
+			return tw.Flush()
+		}
+		return nil
+	})
+}
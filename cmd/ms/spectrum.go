@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/ndjson"
+)
+
+func spectrum(args []string) error {
+	o := newOptions("spectrum", "file...", "Spectrum prints the peaks of a scan, a line with m/z and intensity per peak,\nor the scan as a JSON object.")
+	o.formatFlag("text", "json")
+	sn := o.fs.Int("scan", 1, "the scan number")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	return o.each(func(path string, file ms.Reader) error {
+		if *sn < 1 || *sn > file.NScans() {
+			return fmt.Errorf("scan %d is out of bounds [1, %d]", *sn, file.NScans())
+		}
+		scan := file.Scan(*sn)
+		if o.format == "json" {
+			s := ndjson.FromScan(scan, true)
+			if len(o.files) > 1 {
+				s.File = path
+			}
+			return o.writeJSON(s)
+		}
+		for _, peak := range scan.Spectrum {
+			fmt.Fprintln(o.out, peak.Mz, peak.I)
+		}
+		return nil
+	})
+}
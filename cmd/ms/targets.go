@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/chem"
+)
+
+//target is an ion of which the chromatogram is extracted, the ID is its name
+//and a zero Tol is the tolerance of the -tol flag
+type target struct {
+	ms.XICTarget
+	Charge int
+	//RT is the expected retention time in minutes, 0 if it is unknown
+	RT float64
+}
+
+//loadTargets returns the targets of the -mz values and of the target list
+//if path is not empty, the window sets the windows around expected times
+func loadTargets(mzs []float64, path string, window float64) ([]target, error) {
+	targets := mzTargets(mzs)
+	if path != "" {
+		ts, err := readTargets(path)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, ts...)
+	}
+	if len(targets) == 0 {
+		return nil, usageError{fmt.Errorf("-mz or -targets is required")}
+	}
+	setWindows(targets, window)
+	return targets, nil
+}
+
+//mzTargets returns the targets of m/z values
+func mzTargets(mzs []float64) []target {
+	ts := make([]target, len(mzs))
+	for i, mz := range mzs {
+		ts[i].ID, ts[i].Mz = strconv.FormatFloat(mz, 'f', -1, 64), mz
+	}
+	return ts
+}
+
+//setWindows sets the retention time window of the targets with an expected
+//retention time and without a window to that time plus or minus width minutes
+func setWindows(targets []target, width float64) {
+	for i, t := range targets {
+		if width > 0 && t.RT > 0 && t.MinTime == 0 && t.MaxTime == 0 {
+			targets[i].MinTime, targets[i].MaxTime = math.Max(t.RT-width, 0), t.RT+width
+		}
+	}
+}
+
+//xicTargets returns the targets for ms.ExtractXICs
+func xicTargets(targets []target) []ms.XICTarget {
+	ts := make([]ms.XICTarget, len(targets))
+	for i, t := range targets {
+		ts[i] = t.XICTarget
+	}
+	return ts
+}
+
+//targetColumns are the known columns of a target list
+var targetColumns = []string{"name", "mz", "formula", "adduct", "charge", "rt", "rtmin", "rtmax", "tol"}
+
+//readTargets reads a target list, a CSV or TSV file with a header line that
+//names the columns: name, mz, formula, adduct, charge, rt, rtmin, rtmax and tol.
+//Every target needs either an m/z or a formula, the m/z of a formula is that
+//of its adduct or else that of its (de)protonated ion with the charge,
+//1 by default. Lines starting with # are comments.
+func readTargets(path string) ([]target, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	//the file is TSV if the header has tabs
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && line[0] != '#' {
+			if strings.Contains(line, "\t") {
+				r.Comma = '\t'
+			}
+			break
+		}
+	}
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: no header: %v", path, err)
+	}
+	columns := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !contains(targetColumns, h) {
+			return nil, fmt.Errorf("%s: unknown column %q, use %s", path, h, strings.Join(targetColumns, ", "))
+		}
+		columns[h] = i
+	}
+	if _, ok := columns["mz"]; !ok {
+		if _, ok := columns["formula"]; !ok {
+			return nil, fmt.Errorf("%s: a mz or formula column is required", path)
+		}
+	}
+
+	var targets []target
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return targets, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		line, _ := r.FieldPos(0)
+		t, err := parseTarget(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %v", path, line, err)
+		}
+		targets = append(targets, t)
+	}
+}
+
+//parseTarget returns the target of the fields of a line of a target list
+func parseTarget(field func(name string) string) (t target, err error) {
+	number := func(name string) float64 {
+		s := field(name)
+		if s == "" || err != nil {
+			return 0
+		}
+		var v float64
+		if v, err = strconv.ParseFloat(s, 64); err != nil {
+			err = fmt.Errorf("bad %s %q", name, s)
+		}
+		return v
+	}
+	t.ID = field("name")
+	t.Mz = number("mz")
+	t.RT = number("rt")
+	t.MinTime = number("rtmin")
+	t.MaxTime = number("rtmax")
+	if s := field("charge"); s != "" && err == nil {
+		if t.Charge, err = strconv.Atoi(strings.TrimSuffix(s, "+")); err != nil {
+			err = fmt.Errorf("bad charge %q", s)
+		}
+	}
+	if s := field("tol"); s != "" && err == nil {
+		t.Tol, err = ms.ParseTolerance(s)
+	}
+	if err != nil {
+		return
+	}
+
+	if formula := field("formula"); formula != "" && t.Mz == 0 {
+		var f chem.Formula
+		if f, err = chem.ParseFormula(formula); err != nil {
+			return
+		}
+		if err = f.Validate(); err != nil {
+			return
+		}
+		name := formula
+		if s := field("adduct"); s != "" {
+			var a chem.Adduct
+			if a, err = chem.ParseAdduct(s); err != nil {
+				return
+			}
+			t.Mz, t.Charge, name = a.Mz(f), a.Charge, formula+" "+s
+		} else {
+			if t.Charge == 0 {
+				t.Charge = 1
+			}
+			t.Mz = chem.Mz(f.MonoisotopicMass(), t.Charge)
+		}
+		if t.ID == "" {
+			t.ID = name
+		}
+	}
+	switch {
+	case t.Mz <= 0:
+		err = fmt.Errorf("target without mz or formula")
+	case t.MaxTime != 0 && t.MaxTime < t.MinTime:
+		err = fmt.Errorf("rtmax %g is before rtmin %g", t.MaxTime, t.MinTime)
+	}
+	if t.ID == "" {
+		t.ID = strconv.FormatFloat(t.Mz, 'f', -1, 64)
+	}
+	return
+}
@@ -23,8 +23,71 @@ type Scan struct {
 	//PrecursorMzs is only filled with mz values at MSx scans.
 	PrecursorMzs []float64
 	Time         float64
+	Polarity     Polarity
+	//Number is the scan number in the run, counting from 1
+	Number int
+	//Centroided is true if Spectrum holds centroids instead of profile points
+	Centroided   bool
+	TotalCurrent float64
+	BasePeak     Peak
+	//LowMz and HighMz are the m/z range of the acquisition
+	LowMz  float64
+	HighMz float64
+	//Filter is a textual description of the scan, such as a Thermo filter line.
+	//RAW files hold no filter line, theirs is synthesized from the scan event
+	//and lacks the activation and other parts of the line Xcalibur shows.
+	Filter string
+	//Precursors holds details of the precursors in PrecursorMzs, in the same order
+	Precursors []Precursor
+	//InjectionTime is the ion injection time in ms, 0 if it is unknown
+	InjectionTime float64
 }
 
+//Precursor is an ion that was isolated and fragmented for an MSx scan
+type Precursor struct {
+	Mz float64
+	//Charge is 0 if it is unknown
+	Charge int
+	//Intensity is 0 if it is unknown
+	Intensity  float32
+	Activation Activation
+	//Energy is the activation energy, e.g. the (normalized) collision energy,
+	//0 if it is unknown
+	Energy float64
+}
+
+//Activation is the fragmentation method of a precursor
+type Activation int
+
+//The zero Activation is unknown
+const (
+	UnknownActivation Activation = iota
+	CID
+	HCD
+	ETD
+	ECD
+	ETHCD
+	UVPD
+)
+
+var activationNames = [...]string{"unknown", "CID", "HCD", "ETD", "ECD", "EThcD", "UVPD"}
+
+func (a Activation) String() string {
+	if a < 0 || int(a) >= len(activationNames) {
+		return activationNames[0]
+	}
+	return activationNames[a]
+}
+
+//Polarity is the sign of the charge of the ions in a scan
+type Polarity int8
+
+//The zero Polarity is unknown
+const (
+	Negative Polarity = -1
+	Positive Polarity = 1
+)
+
 //Analyzer is the mass analyzer
 type Analyzer int
 
@@ -39,6 +102,25 @@ const (
 	Undefined
 )
 
+var analyzerNames = [...]string{"ITMS", "TQMS", "SQMS", "TOFMS", "FTMS", "Sector", "Undefined"}
+
+func (a Analyzer) String() string {
+	if a < 0 || int(a) >= len(analyzerNames) {
+		return analyzerNames[Undefined]
+	}
+	return analyzerNames[a]
+}
+
+func (p Polarity) String() string {
+	switch p {
+	case Positive:
+		return "+"
+	case Negative:
+		return "-"
+	}
+	return ""
+}
+
 //Spectrum implements sort.Interface for []Peak based on m/z
 
 func (a Spectrum) Len() int           { return len(a) }
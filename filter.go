@@ -0,0 +1,44 @@
+package ms
+
+import "strings"
+
+//ScanFilter selects scans by their properties, the zero value selects every scan
+type ScanFilter struct {
+	//MSLevel selects one MS level if it is not 0
+	MSLevel uint8
+	//Analyzers selects scans of these analyzers if it is not empty
+	Analyzers []Analyzer
+	//Polarity selects scans of one polarity if it is not 0
+	Polarity Polarity
+	//MinTime and MaxTime select a retention time range in minutes,
+	//a MaxTime of 0 means no upper limit
+	MinTime float64
+	MaxTime float64
+	//FilterContains selects scans of which the Filter line contains it
+	FilterContains string
+}
+
+//Matches reports whether the scan passes the filter
+func (f ScanFilter) Matches(scan Scan) bool {
+	if f.MSLevel != 0 && scan.MSLevel != f.MSLevel {
+		return false
+	}
+	if f.Polarity != 0 && scan.Polarity != f.Polarity {
+		return false
+	}
+	if scan.Time < f.MinTime || (f.MaxTime != 0 && scan.Time > f.MaxTime) {
+		return false
+	}
+	if f.FilterContains != "" && !strings.Contains(scan.Filter, f.FilterContains) {
+		return false
+	}
+	if len(f.Analyzers) == 0 {
+		return true
+	}
+	for _, a := range f.Analyzers {
+		if scan.Analyzer == a {
+			return true
+		}
+	}
+	return false
+}
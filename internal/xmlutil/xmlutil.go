@@ -0,0 +1,84 @@
+//Package xmlutil holds the helpers that the mzML and mzXML writers share
+package xmlutil
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//CountingWriter keeps the position in, and the SHA-1 of the output
+type CountingWriter struct {
+	w *bufio.Writer
+	h hash.Hash
+	//N is the number of bytes written
+	N int64
+}
+
+//NewCountingWriter returns a buffered CountingWriter to w
+func NewCountingWriter(w io.Writer) *CountingWriter {
+	return &CountingWriter{w: bufio.NewWriter(w), h: sha1.New()}
+}
+
+func (c *CountingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.h.Write(p[:n])
+	c.N += int64(n)
+	return n, err
+}
+
+//SHA1 returns the hex encoded SHA-1 of the output so far
+func (c *CountingWriter) SHA1() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
+
+//Flush writes the buffered output
+func (c *CountingWriter) Flush() error {
+	return c.w.Flush()
+}
+
+//Esc escapes text for use in an attribute
+func Esc(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+//Ftoa formats a float with the fewest digits that represent it
+func Ftoa(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+//FileURI returns the file URI of the absolute path of fn
+func FileURI(fn string) string {
+	abs, err := filepath.Abs(fn)
+	if err != nil {
+		abs = fn
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs
+	}
+	return "file://" + abs
+}
+
+//FileSHA1 returns the hex encoded SHA-1 of the file
+func FileSHA1(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
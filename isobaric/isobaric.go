@@ -0,0 +1,120 @@
+//Package isobaric defines the reporter ions of isobaric labeling reagents,
+//iTRAQ and TMT, and finds them in fragment spectra.
+package isobaric
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/chem"
+)
+
+//Reporter is a reporter ion, a channel of a reagent
+type Reporter struct {
+	Name string
+	Mz   float64
+}
+
+//Reagent is an isobaric labeling reagent, its reporter ions are in ascending m/z
+type Reagent struct {
+	Name      string
+	Reporters []Reporter
+}
+
+//reporter returns the reporter ion of the base composition with c13 carbons
+//and n15 nitrogens labelled
+func reporter(name string, base string, c13 int, n15 int) Reporter {
+	f := chem.MustParseFormula(base)
+	f["C"] -= c13
+	f["13C"] += c13
+	f["N"] -= n15
+	f["15N"] += n15
+	return Reporter{name, f.Mz(1)}
+}
+
+//The reporter ions of iTRAQ are C6H13N2+ and those of TMT C8H16N+, the
+//channels differ in their 13C and 15N labels. The N and C channels of TMT
+//with the same nominal mass are 6.32 mDa apart, they need high resolution.
+var (
+	ITRAQ4 = Reagent{"iTRAQ4", []Reporter{
+		reporter("114", itraq, 1, 0), reporter("115", itraq, 1, 1),
+		reporter("116", itraq, 2, 1), reporter("117", itraq, 3, 1)}}
+	ITRAQ8 = Reagent{"iTRAQ8", []Reporter{
+		reporter("113", itraq, 0, 0), reporter("114", itraq, 1, 0),
+		reporter("115", itraq, 1, 1), reporter("116", itraq, 2, 1),
+		reporter("117", itraq, 3, 1), reporter("118", itraq, 3, 2),
+		reporter("119", itraq, 4, 2), reporter("121", itraq, 6, 2)}}
+	TMT6 = Reagent{"TMT6", []Reporter{
+		reporter("126", tmt, 0, 0), reporter("127", tmt, 0, 1),
+		reporter("128", tmt, 2, 0), reporter("129", tmt, 2, 1),
+		reporter("130", tmt, 4, 0), reporter("131", tmt, 4, 1)}}
+	TMT10    = Reagent{"TMT10", tmtReporters(10)}
+	TMT11    = Reagent{"TMT11", tmtReporters(11)}
+	TMTpro16 = Reagent{"TMTpro16", tmtReporters(16)}
+	TMTpro18 = Reagent{"TMTpro18", tmtReporters(18)}
+)
+
+const (
+	itraq = "C6H13N2"
+	tmt   = "C8H16N"
+)
+
+//tmtReporters returns the first n channels of TMT(pro): 126, 127N, 127C,
+//128N and so on. Channel k has k/2 13C, the N channels also a 15N.
+func tmtReporters(n int) []Reporter {
+	rs := make([]Reporter, n)
+	for k := range rs {
+		c13, n15 := k/2, k%2
+		name := fmt.Sprint(126 + (k+1)/2)
+		switch {
+		case k == 0:
+		case n15 == 1:
+			name += "N"
+		default:
+			name += "C"
+		}
+		rs[k] = reporter(name, tmt, c13, n15)
+	}
+	return rs
+}
+
+//Reagents are the known reagents
+var Reagents = []Reagent{ITRAQ4, ITRAQ8, TMT6, TMT10, TMT11, TMTpro16, TMTpro18}
+
+//ReagentByName returns the reagent of a name, ignoring case
+func ReagentByName(name string) (Reagent, bool) {
+	for _, r := range Reagents {
+		if strings.EqualFold(r.Name, name) {
+			return r, true
+		}
+	}
+	return Reagent{}, false
+}
+
+//Check returns an error if the tolerance windows of reporter ions overlap,
+//so that their peaks cannot be told apart
+func (r Reagent) Check(tol ms.Tolerance) error {
+	for i := 1; i < len(r.Reporters); i++ {
+		a, b := r.Reporters[i-1], r.Reporters[i]
+		if _, max := tol.Window(a.Mz); max >= b.Mz-tol.Delta(b.Mz) {
+			return fmt.Errorf("isobaric: %s reporters %s and %s are %.4f apart, too close for a tolerance of %v",
+				r.Name, a.Name, b.Name, b.Mz-a.Mz, tol)
+		}
+	}
+	return nil
+}
+
+//Peaks returns the most intense peak within the tolerance of each reporter
+//ion in the spectrum, in the order of the reporters. Missing reporters have
+//a peak at their m/z with intensity 0.
+func (r Reagent) Peaks(spectrum ms.Spectrum, tol ms.Tolerance) ms.Spectrum {
+	peaks := make(ms.Spectrum, len(r.Reporters))
+	for i, rep := range r.Reporters {
+		peaks[i] = ms.Peak{Mz: rep.Mz}
+		if found := spectrum.Around(rep.Mz, tol); len(found) > 0 {
+			peaks[i] = found.MaxPeak()
+		}
+	}
+	return peaks
+}
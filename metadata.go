@@ -0,0 +1,60 @@
+package ms
+
+import "time"
+
+//Metadata describes a run and the instrument that acquired it
+type Metadata struct {
+	//SourceFile is the path of the file the run was read from
+	SourceFile string
+	//FileFormat names the format of the source file, e.g. "Thermo RAW"
+	FileFormat       string
+	InstrumentVendor string
+	InstrumentModel  string
+	InstrumentSerial string
+	//SoftwareVersion is the version of the acquisition software
+	SoftwareVersion string
+	AcquisitionDate time.Time
+	SampleID        string
+	SampleComment   string
+	//InstrumentMethod is the name of the method the run was acquired with
+	InstrumentMethod string
+	//StartTime and EndTime are the retention time range in minutes
+	StartTime float64
+	EndTime   float64
+	//LowMz and HighMz are the m/z range of the run
+	LowMz  float64
+	HighMz float64
+}
+
+//ChromatogramType is the kind of trace in a Chromatogram
+type ChromatogramType int
+
+//Chromatogram types
+const (
+	UnknownChromatogram ChromatogramType = iota
+	//TIC is the total ion current chromatogram
+	TIC
+	//BPC is the base peak chromatogram
+	BPC
+	//XIC is an extracted (selected) ion chromatogram
+	XIC
+	//Detector is the trace of a detector other than the mass spectrometer, e.g. UV
+	Detector
+)
+
+//ChromatogramPoint is an intensity at a retention time. For traces derived
+//from scans, Mz and ScanNumber are the m/z of the peak and the scan it was
+//found in; they are 0 for other traces.
+type ChromatogramPoint struct {
+	Time       float64
+	I          float64
+	Mz         float64
+	ScanNumber int
+}
+
+//Chromatogram is an intensity trace over retention time
+type Chromatogram struct {
+	ID     string
+	Type   ChromatogramType
+	Points []ChromatogramPoint
+}
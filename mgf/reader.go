@@ -0,0 +1,191 @@
+package mgf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+)
+
+//Reader reads MGF spectra one by one
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+	//Globals are the params before the first spectrum, such as a default CHARGE
+	Globals []Param
+	charges []int
+}
+
+//NewReader returns a Reader reading from r
+func NewReader(r io.Reader) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &Reader{s: s}
+}
+
+//Next returns the next spectrum, or io.EOF if there are no more. Spectra
+//without a CHARGE get the global CHARGE.
+func (r *Reader) Next() (Spectrum, error) {
+	var s Spectrum
+	in := false
+	for r.s.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.s.Text())
+		if line == "" || strings.IndexByte("#;!/", line[0]) >= 0 {
+			continue
+		}
+		upper := strings.ToUpper(line)
+		switch {
+		case upper == "BEGIN IONS":
+			if in {
+				return s, r.errorf("BEGIN IONS inside a spectrum")
+			}
+			in = true
+			s = Spectrum{}
+			continue
+		case upper == "END IONS":
+			if !in {
+				return s, r.errorf("END IONS outside a spectrum")
+			}
+			if s.Charges == nil {
+				s.Charges = r.charges
+			}
+			return s, nil
+		}
+		if i := strings.IndexByte(line, '='); i > 0 {
+			key, value := strings.ToUpper(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+			var err error
+			if !in {
+				r.Globals = append(r.Globals, Param{key, value})
+				if key == "CHARGE" {
+					r.charges, err = ParseCharges(value)
+				}
+			} else {
+				err = s.set(key, value)
+			}
+			if err != nil {
+				return s, r.errorf("%v", err)
+			}
+			continue
+		}
+		if !in {
+			return s, r.errorf("peak outside a spectrum")
+		}
+		f := strings.Fields(line)
+		mz, err := strconv.ParseFloat(f[0], 64)
+		if err != nil {
+			return s, r.errorf("bad peak %q", line)
+		}
+		var in64 float64
+		if len(f) > 1 {
+			if in64, err = strconv.ParseFloat(f[1], 32); err != nil {
+				return s, r.errorf("bad peak %q", line)
+			}
+		}
+		s.Peaks = append(s.Peaks, ms.Peak{Mz: mz, I: float32(in64)})
+	}
+	if err := r.s.Err(); err != nil {
+		return s, err
+	}
+	if in {
+		return s, io.ErrUnexpectedEOF
+	}
+	return s, io.EOF
+}
+
+func (r *Reader) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("mgf: line %d: %s", r.line, fmt.Sprintf(format, a...))
+}
+
+//set sets the field of the key
+func (s *Spectrum) set(key string, value string) (err error) {
+	switch key {
+	case "TITLE":
+		s.Title = value
+	case "PEPMASS":
+		f := strings.Fields(value)
+		if len(f) == 0 {
+			return fmt.Errorf("empty PEPMASS")
+		}
+		if s.PepMass, err = strconv.ParseFloat(f[0], 64); err != nil {
+			return err
+		}
+		if len(f) > 1 {
+			s.PepIntensity, err = strconv.ParseFloat(f[1], 64)
+		}
+	case "CHARGE":
+		s.Charges, err = ParseCharges(value)
+	case "RTINSECONDS":
+		//a range "start-end" is reduced to its start
+		if i := strings.IndexByte(value, '-'); i > 0 {
+			value = value[:i]
+		}
+		s.RTInSeconds, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+	case "SCANS":
+		s.Scans = value
+	default:
+		s.Params = append(s.Params, Param{key, value})
+	}
+	return
+}
+
+//ParseCharges parses charges like "2+", "3-", "2", "2+ and 3+" or "2+,3+"
+func ParseCharges(v string) ([]int, error) {
+	v = strings.Replace(v, " and ", ",", -1)
+	var charges []int
+	for _, c := range strings.Split(v, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		sign := 1
+		switch {
+		case strings.HasSuffix(c, "+"):
+			c = c[:len(c)-1]
+		case strings.HasSuffix(c, "-"):
+			c, sign = c[:len(c)-1], -1
+		case strings.HasPrefix(c, "+"), strings.HasPrefix(c, "-"):
+			if c[0] == '-' {
+				sign = -1
+			}
+			c = c[1:]
+		}
+		z, err := strconv.Atoi(c)
+		if err != nil {
+			return nil, fmt.Errorf("bad CHARGE %q", v)
+		}
+		charges = append(charges, sign*z)
+	}
+	return charges, nil
+}
+
+//Scan returns the spectrum as an MS2 scan, with Time in minutes and Number
+//from SCANS if it is set. The polarity is the sign of the charges, unknown
+//without them.
+func (s Spectrum) Scan() ms.Scan {
+	scan := ms.Scan{
+		Analyzer: ms.Undefined,
+		MSLevel:  2,
+		Spectrum: s.Peaks,
+		Time:     s.RTInSeconds / 60,
+	}
+	scan.Number, _ = strconv.Atoi(strings.SplitN(s.Scans, "-", 2)[0])
+	if s.PepMass > 0 {
+		p := ms.Precursor{Mz: s.PepMass, Intensity: float32(s.PepIntensity)}
+		if len(s.Charges) == 1 {
+			p.Charge = abs(s.Charges[0])
+		}
+		if len(s.Charges) > 0 {
+			scan.Polarity = ms.Positive
+			if s.Charges[0] < 0 {
+				scan.Polarity = ms.Negative
+			}
+		}
+		scan.PrecursorMzs = []float64{p.Mz}
+		scan.Precursors = []ms.Precursor{p}
+	}
+	return scan
+}
@@ -0,0 +1,164 @@
+//Package mgf reads and writes Mascot Generic Format (MGF) peak lists
+package mgf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+)
+
+//Param is a key/value line of a spectrum or of the file header
+type Param struct {
+	Key   string
+	Value string
+}
+
+//Spectrum is an MGF spectrum, usually an MS2 scan
+type Spectrum struct {
+	Title string
+	//PepMass is the precursor m/z, PepIntensity is 0 if it is unknown
+	PepMass      float64
+	PepIntensity float64
+	//Charges are the possible precursor charges, negative for anions
+	Charges []int
+	//RTInSeconds is the retention time, note that ms.Scan.Time is in minutes
+	RTInSeconds float64
+	//Scans is the scan number or range, e.g. "12" or "12-14"
+	Scans string
+	//Params holds the other keys, such as SEQ or custom ones
+	Params []Param
+	Peaks  ms.Spectrum
+}
+
+//Writer writes MGF spectra
+type Writer struct {
+	w *bufio.Writer
+}
+
+//NewWriter returns a Writer writing to w, the global params are written
+//first, e.g. a default CHARGE or the search parameters.
+func NewWriter(w io.Writer, globals ...Param) (*Writer, error) {
+	wr := &Writer{bufio.NewWriter(w)}
+	for _, p := range globals {
+		fmt.Fprintf(wr.w, "%s=%s\n", strings.ToUpper(p.Key), p.Value)
+	}
+	if len(globals) > 0 {
+		wr.w.WriteByte('\n')
+	}
+	return wr, wr.w.Flush()
+}
+
+//Write writes one spectrum
+func (w *Writer) Write(s Spectrum) error {
+	o := w.w
+	o.WriteString("BEGIN IONS\n")
+	if s.Title != "" {
+		fmt.Fprintf(o, "TITLE=%s\n", s.Title)
+	}
+	if s.PepMass > 0 {
+		fmt.Fprintf(o, "PEPMASS=%s", ftoa(s.PepMass))
+		if s.PepIntensity > 0 {
+			fmt.Fprintf(o, " %s", ftoa(s.PepIntensity))
+		}
+		o.WriteByte('\n')
+	}
+	if len(s.Charges) > 0 {
+		fmt.Fprintf(o, "CHARGE=%s\n", FormatCharges(s.Charges))
+	}
+	if s.RTInSeconds > 0 {
+		fmt.Fprintf(o, "RTINSECONDS=%s\n", ftoa(s.RTInSeconds))
+	}
+	if s.Scans != "" {
+		fmt.Fprintf(o, "SCANS=%s\n", s.Scans)
+	}
+	for _, p := range s.Params {
+		fmt.Fprintf(o, "%s=%s\n", strings.ToUpper(p.Key), p.Value)
+	}
+	for _, p := range s.Peaks {
+		fmt.Fprintf(o, "%s %s\n", ftoa(p.Mz), strconv.FormatFloat(float64(p.I), 'f', -1, 32))
+	}
+	_, err := o.WriteString("END IONS\n\n")
+	return err
+}
+
+//Flush writes the buffered spectra to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+//FormatCharges formats charges the Mascot way, e.g. "2+" or "2+ and 3+"
+func FormatCharges(charges []int) string {
+	s := make([]string, len(charges))
+	for i, z := range charges {
+		if z < 0 {
+			s[i] = strconv.Itoa(-z) + "-"
+		} else {
+			s[i] = strconv.Itoa(z) + "+"
+		}
+	}
+	return strings.Join(s, " and ")
+}
+
+//Title returns the title of the scan in the convention of msconvert and the
+//TPP, e.g. `run.12.12.2 File:"run.raw", NativeID:"controllerType=0 controllerNumber=1 scan=12"`.
+//The charge is 0 if it is unknown.
+func Title(meta ms.Metadata, scan ms.Scan, charge int) string {
+	file := filepath.Base(meta.SourceFile)
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+	title := fmt.Sprintf("%s.%d.%d.%d", base, scan.Number, scan.Number, abs(charge))
+	return fmt.Sprintf(`%s File:"%s", NativeID:"%s"`, title, file, NativeID(meta, scan.Number))
+}
+
+//NativeID returns the native id of a scan, as used in mzML
+func NativeID(meta ms.Metadata, sn int) string {
+	if meta.FileFormat == "Thermo RAW" {
+		return "controllerType=0 controllerNumber=1 scan=" + strconv.Itoa(sn)
+	}
+	return "scan=" + strconv.Itoa(sn)
+}
+
+//FromScan returns the MGF spectrum of the scan, with the precursor of the
+//scan that was fragmented last. If the precursor charge is unknown, charge is
+//used if it is not 0 (e.g. a charge inferred with ms.InferCharge).
+func FromScan(meta ms.Metadata, scan ms.Scan, charge int) Spectrum {
+	s := Spectrum{
+		RTInSeconds: scan.Time * 60,
+		Scans:       strconv.Itoa(scan.Number),
+		Peaks:       scan.Spectrum,
+	}
+	if n := len(scan.Precursors); n > 0 {
+		p := scan.Precursors[n-1]
+		s.PepMass, s.PepIntensity = p.Mz, float64(p.Intensity)
+		if p.Charge != 0 {
+			charge = p.Charge
+		}
+	} else if n := len(scan.PrecursorMzs); n > 0 {
+		s.PepMass = scan.PrecursorMzs[n-1]
+	}
+	if charge != 0 {
+		charge = abs(charge)
+		if scan.Polarity == ms.Negative {
+			charge = -charge
+		}
+		s.Charges = []int{charge}
+	}
+	s.Title = Title(meta, scan, charge)
+	return s
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+//ftoa formats without exponent and trailing zeros
+func ftoa(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
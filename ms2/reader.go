@@ -0,0 +1,153 @@
+package ms2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+)
+
+//Reader reads the spectra of an MS1 or MS2 file one by one
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+	//Header holds the H lines, it is complete after the first Next
+	Header []Param
+	//next is the S line of the next spectrum
+	next string
+}
+
+//NewReader returns a Reader reading from r
+func NewReader(r io.Reader) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &Reader{s: s}
+}
+
+//Next returns the next spectrum, or io.EOF if there are no more
+func (r *Reader) Next() (Spectrum, error) {
+	var s Spectrum
+	for r.next == "" {
+		if !r.s.Scan() {
+			if err := r.s.Err(); err != nil {
+				return s, err
+			}
+			return s, io.EOF
+		}
+		r.line++
+		line := strings.TrimSpace(r.s.Text())
+		switch {
+		case line == "":
+		case line[0] == 'H':
+			f := strings.SplitN(line, "\t", 3)
+			h := Param{Key: strings.TrimSpace(f[len(f)-1])}
+			if len(f) == 3 {
+				h = Param{strings.TrimSpace(f[1]), strings.TrimSpace(f[2])}
+			}
+			r.Header = append(r.Header, h)
+		case line[0] == 'S':
+			r.next = line
+		default:
+			return s, r.errorf("%q before the first S line", line)
+		}
+	}
+	f := strings.Fields(r.next)
+	r.next = ""
+	if len(f) < 3 {
+		return s, r.errorf("bad S line")
+	}
+	var err error
+	if s.FirstScan, err = strconv.Atoi(f[1]); err != nil {
+		return s, r.errorf("bad S line")
+	}
+	if s.LastScan, err = strconv.Atoi(f[2]); err != nil {
+		return s, r.errorf("bad S line")
+	}
+	if len(f) > 3 {
+		if s.PrecursorMz, err = strconv.ParseFloat(f[3], 64); err != nil {
+			return s, r.errorf("bad S line")
+		}
+	}
+
+	for r.s.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.s.Text())
+		if line == "" {
+			continue
+		}
+		f := strings.Fields(line)
+		switch f[0] {
+		case "S":
+			r.next = line
+			return s, nil
+		case "I":
+			if len(f) < 2 {
+				return s, r.errorf("bad I line")
+			}
+			value := strings.Join(f[2:], " ")
+			switch f[1] {
+			case "RetTime":
+				s.RetTime, err = strconv.ParseFloat(value, 64)
+			case "IonInjectionTime":
+				s.IonInjectionTime, err = strconv.ParseFloat(value, 64)
+			case "PrecursorInt":
+				s.PrecursorInt, err = strconv.ParseFloat(value, 64)
+			default:
+				s.Info = append(s.Info, Param{f[1], value})
+			}
+		case "Z":
+			var c Charge
+			if len(f) < 3 {
+				return s, r.errorf("bad Z line")
+			}
+			if c.Z, err = strconv.Atoi(f[1]); err == nil {
+				c.MH, err = strconv.ParseFloat(f[2], 64)
+			}
+			s.Charges = append(s.Charges, c)
+		case "D":
+			//charge dependent analysis lines are not kept
+		default:
+			var mz, in float64
+			if mz, err = strconv.ParseFloat(f[0], 64); err == nil && len(f) > 1 {
+				in, err = strconv.ParseFloat(f[1], 32)
+			}
+			s.Peaks = append(s.Peaks, ms.Peak{Mz: mz, I: float32(in)})
+		}
+		if err != nil {
+			return s, r.errorf("bad line %q", line)
+		}
+	}
+	return s, r.s.Err()
+}
+
+func (r *Reader) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("ms2: line %d: %s", r.line, fmt.Sprintf(format, a...))
+}
+
+//Scan returns the spectrum as a scan, of MS level 2 if it has a precursor.
+//The precursor charge is set if there is a single Z line. The polarity is
+//unknown, the Z lines hold no sign.
+func (s Spectrum) Scan() ms.Scan {
+	scan := ms.Scan{
+		Analyzer: ms.Undefined,
+		MSLevel:  1,
+		Spectrum: s.Peaks,
+		Time:     s.RetTime,
+		Number:   s.FirstScan,
+
+		InjectionTime: s.IonInjectionTime,
+	}
+	if s.PrecursorMz > 0 {
+		p := ms.Precursor{Mz: s.PrecursorMz, Intensity: float32(s.PrecursorInt)}
+		if len(s.Charges) == 1 {
+			p.Charge = s.Charges[0].Z
+		}
+		scan.MSLevel = 2
+		scan.PrecursorMzs = []float64{p.Mz}
+		scan.Precursors = []ms.Precursor{p}
+	}
+	return scan
+}
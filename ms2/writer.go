@@ -0,0 +1,151 @@
+//Package ms2 reads and writes the MS1 and MS2 text formats of Sequest and Crux
+//(McDonald et al., Rapid Commun. Mass Spectrom. 2004)
+package ms2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/chem"
+)
+
+//Param is a header (H) or info (I) line
+type Param struct {
+	Key   string
+	Value string
+}
+
+//Charge is a Z line: a possible charge of the precursor with the resulting
+//singly protonated mass [M+H]+
+type Charge struct {
+	Z  int
+	MH float64
+}
+
+//Spectrum is a scan of an MS1 or MS2 file
+type Spectrum struct {
+	FirstScan int
+	LastScan  int
+	//PrecursorMz is 0 in MS1 files
+	PrecursorMz float64
+	//RetTime is in minutes, IonInjectionTime in ms, they are 0 if unknown
+	RetTime          float64
+	IonInjectionTime float64
+	PrecursorInt     float64
+	//Info holds the other I lines
+	Info    []Param
+	Charges []Charge
+	Peaks   ms.Spectrum
+}
+
+//Writer writes MS1 or MS2 files
+type Writer struct {
+	w *bufio.Writer
+}
+
+//NewWriter writes the header lines to w
+func NewWriter(w io.Writer, header []Param) (*Writer, error) {
+	wr := &Writer{bufio.NewWriter(w)}
+	for _, h := range header {
+		fmt.Fprintf(wr.w, "H\t%s\t%s\n", h.Key, h.Value)
+	}
+	return wr, wr.w.Flush()
+}
+
+//Header returns the usual header lines for a file converted from the run
+func Header(meta ms.Metadata, extension string) []Param {
+	h := []Param{
+		{"CreationDate", time.Now().Format("Mon Jan 2 15:04:05 2006")},
+		{"Extractor", "unthermo"},
+		{"ExtractorVersion", "1.0"},
+		{"ExtractorOptions", extension},
+	}
+	if meta.SourceFile != "" {
+		h = append(h, Param{"Comments", "Converted from " + filepath.Base(meta.SourceFile)})
+	}
+	if meta.InstrumentModel != "" {
+		h = append(h, Param{"InstrumentType", meta.InstrumentModel})
+	}
+	return h
+}
+
+//Write writes one spectrum
+func (w *Writer) Write(s Spectrum) error {
+	o := w.w
+	fmt.Fprintf(o, "S\t%06d\t%06d", s.FirstScan, s.LastScan)
+	if s.PrecursorMz > 0 {
+		fmt.Fprintf(o, "\t%s", ftoa(s.PrecursorMz))
+	}
+	o.WriteByte('\n')
+	fmt.Fprintf(o, "I\tRetTime\t%s\n", ftoa(s.RetTime))
+	if s.IonInjectionTime > 0 {
+		fmt.Fprintf(o, "I\tIonInjectionTime\t%s\n", ftoa(s.IonInjectionTime))
+	}
+	if s.PrecursorInt > 0 {
+		fmt.Fprintf(o, "I\tPrecursorInt\t%s\n", ftoa(s.PrecursorInt))
+	}
+	for _, p := range s.Info {
+		fmt.Fprintf(o, "I\t%s\t%s\n", p.Key, p.Value)
+	}
+	for _, c := range s.Charges {
+		fmt.Fprintf(o, "Z\t%d\t%s\n", c.Z, strconv.FormatFloat(c.MH, 'f', 5, 64))
+	}
+	for _, p := range s.Peaks {
+		if _, err := fmt.Fprintf(o, "%s %s\n", ftoa(p.Mz), strconv.FormatFloat(float64(p.I), 'f', -1, 32)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Flush writes the buffered spectra to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+//MH returns the Z line of the precursor m/z at the charge
+func MH(mz float64, z int) Charge {
+	if z < 0 {
+		z = -z
+	}
+	return Charge{z, chem.NeutralMass(mz, z) + chem.ProtonMass}
+}
+
+//FromScan returns the spectrum of the scan. The Z lines hold the precursor
+//charge of the instrument if the file has it (for RAW files from the trailer
+//extra), otherwise the candidate charges, e.g. one inferred with ms.InferCharge.
+func FromScan(scan ms.Scan, candidates ...int) Spectrum {
+	s := Spectrum{
+		FirstScan: scan.Number,
+		LastScan:  scan.Number,
+		RetTime:   scan.Time,
+		Peaks:     scan.Spectrum,
+
+		IonInjectionTime: scan.InjectionTime,
+	}
+	if n := len(scan.Precursors); n > 0 {
+		p := scan.Precursors[n-1]
+		s.PrecursorMz, s.PrecursorInt = p.Mz, float64(p.Intensity)
+		if p.Charge != 0 {
+			candidates = []int{p.Charge}
+		}
+	} else if n := len(scan.PrecursorMzs); n > 0 {
+		s.PrecursorMz = scan.PrecursorMzs[n-1]
+	}
+	if s.PrecursorMz > 0 {
+		for _, z := range candidates {
+			s.Charges = append(s.Charges, MH(s.PrecursorMz, z))
+		}
+	}
+	return s
+}
+
+//ftoa formats without exponent and trailing zeros
+func ftoa(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,161 @@
+package mzml
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/danhitchcock/ms/numpress"
+)
+
+//Compression is the encoding of binary data arrays
+type Compression int
+
+//Compression schemes
+const (
+	NoCompression Compression = iota
+	Zlib
+)
+
+//Numpress is the MS-Numpress codec applied to a binary data array before Compression
+type Numpress int
+
+//Numpress codecs, NumpressLinear suits m/z and time arrays and
+//NumpressSlof or NumpressPic intensity arrays
+const (
+	NoNumpress Numpress = iota
+	NumpressLinear
+	NumpressPic
+	NumpressSlof
+)
+
+//encode applies the codec, values are rounded according to its error bounds
+func (n Numpress) encode(values []float64) ([]byte, error) {
+	switch n {
+	case NumpressLinear:
+		return numpress.EncodeLinear(values, numpress.OptimalLinearFixedPoint(values))
+	case NumpressPic:
+		return numpress.EncodePic(values)
+	case NumpressSlof:
+		return numpress.EncodeSlof(values, numpress.OptimalSlofFixedPoint(values))
+	}
+	return nil, nil
+}
+
+//encodeArray encodes the values as little endian 64-bit floats (32-bit if single)
+//or with the Numpress codec, compresses them and returns them base64 encoded
+func encodeArray(values []float64, single bool, np Numpress, compression Compression) (string, error) {
+	var raw []byte
+	if np != NoNumpress {
+		var err error
+		if raw, err = np.encode(values); err != nil {
+			return "", err
+		}
+	} else if single {
+		raw = make([]byte, 4*len(values))
+		for i, v := range values {
+			binary.LittleEndian.PutUint32(raw[4*i:], math.Float32bits(float32(v)))
+		}
+	} else {
+		raw = make([]byte, 8*len(values))
+		for i, v := range values {
+			binary.LittleEndian.PutUint64(raw[8*i:], math.Float64bits(v))
+		}
+	}
+
+	if compression == Zlib {
+		var b bytes.Buffer
+		zw := zlib.NewWriter(&b)
+		if _, err := zw.Write(raw); err != nil {
+			return "", err
+		}
+		if err := zw.Close(); err != nil {
+			return "", err
+		}
+		raw = b.Bytes()
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+//decode inverts the codec
+func (n Numpress) decode(b []byte) ([]float64, error) {
+	switch n {
+	case NumpressLinear:
+		return numpress.DecodeLinear(b)
+	case NumpressPic:
+		return numpress.DecodePic(b)
+	case NumpressSlof:
+		return numpress.DecodeSlof(b)
+	}
+	return nil, nil
+}
+
+//decodeArray decodes a base64 encoded binary data array of which the
+//encoding is described by the cvParams
+func decodeArray(data string, params []cvParam) ([]float64, error) {
+	width, integer, zlibbed := 8, false, false
+	np := NoNumpress
+	for _, p := range params {
+		switch p.Accession {
+		case termFloat32.acc:
+			width = 4
+		case termFloat64.acc:
+			width = 8
+		case termInt32.acc:
+			width, integer = 4, true
+		case termInt64.acc:
+			width, integer = 8, true
+		case termZlib.acc:
+			zlibbed = true
+		case termNoCompression.acc:
+		default:
+			for n, t := range numpressTerms {
+				if p.Accession == t[0].acc {
+					np = n
+				} else if p.Accession == t[1].acc {
+					np, zlibbed = n, true
+				}
+			}
+		}
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return nil, err
+	}
+	if zlibbed {
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	if np != NoNumpress {
+		return np.decode(raw)
+	}
+
+	if len(raw)%width != 0 {
+		return nil, fmt.Errorf("mzml: binary array of %d bytes holds no %d-byte values", len(raw), width)
+	}
+	values := make([]float64, len(raw)/width)
+	for i := range values {
+		switch {
+		case width == 4 && integer:
+			values[i] = float64(int32(binary.LittleEndian.Uint32(raw[4*i:])))
+		case width == 4:
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:])))
+		case integer:
+			values[i] = float64(int64(binary.LittleEndian.Uint64(raw[8*i:])))
+		default:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(raw[8*i:]))
+		}
+	}
+	return values, nil
+}
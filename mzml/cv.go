@@ -0,0 +1,179 @@
+package mzml
+
+import "github.com/danhitchcock/ms"
+
+//term is a controlled vocabulary term
+type term struct {
+	acc  string
+	name string
+}
+
+//cvRef returns the vocabulary prefix of the accession
+func (t term) cvRef() string {
+	for i := range t.acc {
+		if t.acc[i] == ':' {
+			return t.acc[:i]
+		}
+	}
+	return ""
+}
+
+//PSI-MS and unit ontology terms used in the files
+var (
+	termMS1Spectrum        = term{"MS:1000579", "MS1 spectrum"}
+	termMSnSpectrum        = term{"MS:1000580", "MSn spectrum"}
+	termMSLevel            = term{"MS:1000511", "ms level"}
+	termCentroid           = term{"MS:1000127", "centroid spectrum"}
+	termProfile            = term{"MS:1000128", "profile spectrum"}
+	termPositive           = term{"MS:1000130", "positive scan"}
+	termNegative           = term{"MS:1000129", "negative scan"}
+	termTIC                = term{"MS:1000285", "total ion current"}
+	termBasePeakMz         = term{"MS:1000504", "base peak m/z"}
+	termBasePeakIntensity  = term{"MS:1000505", "base peak intensity"}
+	termLowestMz           = term{"MS:1000528", "lowest observed m/z"}
+	termHighestMz          = term{"MS:1000527", "highest observed m/z"}
+	termNoCombination      = term{"MS:1000795", "no combination"}
+	termScanStartTime      = term{"MS:1000016", "scan start time"}
+	termFilterString       = term{"MS:1000512", "filter string"}
+	termScanWindowLower    = term{"MS:1000501", "scan window lower limit"}
+	termScanWindowUpper    = term{"MS:1000500", "scan window upper limit"}
+	termIsolationTarget    = term{"MS:1000827", "isolation window target m/z"}
+	termSelectedIonMz      = term{"MS:1000744", "selected ion m/z"}
+	termChargeState        = term{"MS:1000041", "charge state"}
+	termPeakIntensity      = term{"MS:1000042", "peak intensity"}
+	termCollisionEnergy    = term{"MS:1000045", "collision energy"}
+	termMzArray            = term{"MS:1000514", "m/z array"}
+	termIntensityArray     = term{"MS:1000515", "intensity array"}
+	termTimeArray          = term{"MS:1000595", "time array"}
+	termFloat32            = term{"MS:1000521", "32-bit float"}
+	termFloat64            = term{"MS:1000523", "64-bit float"}
+	termInt32              = term{"MS:1000519", "32-bit integer"}
+	termInt64              = term{"MS:1000522", "64-bit integer"}
+	termNoCompression      = term{"MS:1000576", "no compression"}
+	termZlib               = term{"MS:1000574", "zlib compression"}
+	termTICChromatogram    = term{"MS:1000235", "total ion current chromatogram"}
+	termBPChromatogram     = term{"MS:1000628", "basepeak chromatogram"}
+	termSICChromatogram    = term{"MS:1000627", "selected ion current chromatogram"}
+	termChromatogramType   = term{"MS:1000626", "chromatogram type"}
+	termThermoRaw          = term{"MS:1000563", "Thermo RAW format"}
+	termThermoNativeID     = term{"MS:1000768", "Thermo nativeID format"}
+	termFileFormat         = term{"MS:1000560", "mass spectrometer file format"}
+	termScanNumberNativeID = term{"MS:1000776", "scan number only nativeID format"}
+	termSHA1               = term{"MS:1000569", "SHA-1"}
+	termInstrumentModel    = term{"MS:1000031", "instrument model"}
+	termThermoModel        = term{"MS:1000483", "Thermo Fisher Scientific instrument model"}
+	termSerialNumber       = term{"MS:1000529", "instrument serial number"}
+	termIonizationType     = term{"MS:1000008", "ionization type"}
+	termDetectorType       = term{"MS:1000026", "detector type"}
+	termInductiveDetector  = term{"MS:1000624", "inductive detector"}
+	termElectronMultiplier = term{"MS:1000253", "electron multiplier"}
+	termCustomSoftware     = term{"MS:1000799", "custom unreleased software tool"}
+	termXcalibur           = term{"MS:1000532", "Xcalibur"}
+	termConversion         = term{"MS:1000544", "Conversion to mzML"}
+	termMzUnit             = term{"MS:1000040", "m/z"}
+	termDetectorCounts     = term{"MS:1000131", "number of detector counts"}
+	termMinute             = term{"UO:0000031", "minute"}
+	termSecond             = term{"UO:0000010", "second"}
+	termElectronvolt       = term{"UO:0000266", "electronvolt"}
+)
+
+//numpressTerms are the MS-Numpress compressions, without and with zlib
+var numpressTerms = map[Numpress][2]term{
+	NumpressLinear: {{"MS:1002312", "MS-Numpress linear prediction compression"},
+		{"MS:1002746", "MS-Numpress linear prediction compression followed by zlib compression"}},
+	NumpressPic: {{"MS:1002313", "MS-Numpress positive integer compression"},
+		{"MS:1002747", "MS-Numpress positive integer compression followed by zlib compression"}},
+	NumpressSlof: {{"MS:1002314", "MS-Numpress short logged float compression"},
+		{"MS:1002748", "MS-Numpress short logged float compression followed by zlib compression"}},
+}
+
+//analyzerTerms are the mass analyzer types
+var analyzerTerms = map[ms.Analyzer]term{
+	ms.ITMS:   {"MS:1000264", "ion trap"},
+	ms.TQMS:   {"MS:1000081", "quadrupole"},
+	ms.SQMS:   {"MS:1000081", "quadrupole"},
+	ms.TOFMS:  {"MS:1000084", "time-of-flight"},
+	ms.FTMS:   {"MS:1000484", "orbitrap"},
+	ms.Sector: {"MS:1000080", "magnetic sector"},
+}
+
+//analyzerTerm returns the analyzer type, FTMS is an orbitrap unless the
+//instrument is an FT-ICR
+func analyzerTerm(a ms.Analyzer, model string) term {
+	if a == ms.FTMS && (model == "LTQ FT" || model == "LTQ FT Ultra") {
+		return term{"MS:1000079", "fourier transform ion cyclotron resonance mass spectrometer"}
+	}
+	if t, ok := analyzerTerms[a]; ok {
+		return t
+	}
+	return term{"MS:1000443", "mass analyzer type"}
+}
+
+//activationTerms are the dissociation methods
+var activationTerms = map[ms.Activation]term{
+	ms.CID:   {"MS:1000133", "collision-induced dissociation"},
+	ms.HCD:   {"MS:1000422", "beam-type collision-induced dissociation"},
+	ms.ETD:   {"MS:1000598", "electron transfer dissociation"},
+	ms.ECD:   {"MS:1000250", "electron capture dissociation"},
+	ms.ETHCD: {"MS:1002631", "Electron-Transfer/Higher-Energy Collision Dissociation (EThcD)"},
+	ms.UVPD:  {"MS:1003246", "ultraviolet photodissociation"},
+}
+
+//thermoModels are the Thermo instrument models, by the name the instrument reports
+var thermoModels = map[string]term{
+	"LTQ FT":                {"MS:1000448", "LTQ FT"},
+	"LTQ FT Ultra":          {"MS:1000557", "LTQ FT Ultra"},
+	"LTQ Orbitrap":          {"MS:1000449", "LTQ Orbitrap"},
+	"LTQ Orbitrap XL":       {"MS:1000556", "LTQ Orbitrap XL"},
+	"LTQ Orbitrap Velos":    {"MS:1001742", "LTQ Orbitrap Velos"},
+	"Orbitrap Elite":        {"MS:1001910", "LTQ Orbitrap Elite"},
+	"Q Exactive":            {"MS:1001911", "Q Exactive"},
+	"Q Exactive Plus":       {"MS:1002634", "Q Exactive Plus"},
+	"Q Exactive HF":         {"MS:1002523", "Q Exactive HF"},
+	"Q Exactive HF-X":       {"MS:1002877", "Q Exactive HF-X"},
+	"Orbitrap Fusion":       {"MS:1002416", "Orbitrap Fusion"},
+	"Orbitrap Fusion Lumos": {"MS:1002732", "Orbitrap Fusion Lumos"},
+	"Orbitrap Exploris 480": {"MS:1003028", "Orbitrap Exploris 480"},
+	"Orbitrap Eclipse":      {"MS:1003029", "Orbitrap Eclipse"},
+	"Orbitrap ID-X":         {"MS:1003112", "Orbitrap ID-X"},
+}
+
+//modelBranches are the instrument model term and the vendor branches below it
+var modelBranches = []term{
+	termInstrumentModel,
+	{"MS:1000121", "SCIEX instrument model"},
+	{"MS:1000122", "Bruker Daltonics instrument model"},
+	{"MS:1000124", "Shimadzu instrument model"},
+	{"MS:1000126", "Waters instrument model"},
+	{"MS:1000483", "Thermo Fisher Scientific instrument model"},
+	{"MS:1000490", "Agilent instrument model"},
+	{"MS:1000492", "Thermo Electron instrument model"},
+	{"MS:1000493", "Finnigan MAT instrument model"},
+	{"MS:1000494", "Thermo Scientific instrument model"},
+	{"MS:1000495", "Applied Biosystems instrument model"},
+}
+
+//modelBranch reports whether the term is one of modelBranches
+func modelBranch(acc string) bool {
+	for _, t := range modelBranches {
+		if t.acc == acc {
+			return true
+		}
+	}
+	return false
+}
+
+//instrumentModel reports whether the term belongs to the instrument model
+//branch (MS:1000031): it is one of modelBranches or a model of thermoModels.
+//The other terms of an instrument, such as its serial number, are not models.
+func instrumentModel(acc string) bool {
+	if modelBranch(acc) {
+		return true
+	}
+	for _, t := range thermoModels {
+		if t.acc == acc {
+			return true
+		}
+	}
+	return false
+}
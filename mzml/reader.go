@@ -0,0 +1,674 @@
+package mzml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danhitchcock/ms"
+)
+
+//File is an mzML file opened for random access to its spectra.
+//Scan numbers count the spectra in the file from 1, like in unthermo.File.
+type File struct {
+	f        *os.File
+	filename string
+	//offsets are the positions of the spectrum and chromatogram elements
+	spectra       []int64
+	chromatograms []int64
+	groups        map[string][]cvParam
+	configs       map[string]ms.Analyzer
+	defaultConfig string
+	meta          ms.Metadata
+}
+
+//cvParam is a controlled vocabulary term with a value
+type cvParam struct {
+	Accession     string `xml:"accession,attr"`
+	Name          string `xml:"name,attr"`
+	Value         string `xml:"value,attr"`
+	UnitAccession string `xml:"unitAccession,attr"`
+}
+
+//paramGroup is an element holding cvParams, directly or by reference
+type paramGroup struct {
+	Refs []struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"referenceableParamGroupRef"`
+	CvParams []cvParam `xml:"cvParam"`
+}
+
+//spectrumHeader is a spectrum element without its binary data arrays
+type spectrumHeader struct {
+	ID string `xml:"id,attr"`
+	paramGroup
+	Scans      []scanElement      `xml:"scanList>scan"`
+	Precursors []precursorElement `xml:"precursorList>precursor"`
+}
+
+type spectrumElement struct {
+	spectrumHeader
+	Arrays []arrayElement `xml:"binaryDataArrayList>binaryDataArray"`
+}
+
+type scanElement struct {
+	Config string `xml:"instrumentConfigurationRef,attr"`
+	paramGroup
+	Windows []paramGroup `xml:"scanWindowList>scanWindow"`
+}
+
+type precursorElement struct {
+	IsolationWindow paramGroup   `xml:"isolationWindow"`
+	SelectedIons    []paramGroup `xml:"selectedIonList>selectedIon"`
+	Activation      paramGroup   `xml:"activation"`
+}
+
+type arrayElement struct {
+	paramGroup
+	Binary string `xml:"binary"`
+}
+
+type chromatogramElement struct {
+	ID string `xml:"id,attr"`
+	paramGroup
+	Arrays []arrayElement `xml:"binaryDataArrayList>binaryDataArray"`
+}
+
+type configurationElement struct {
+	ID string `xml:"id,attr"`
+	paramGroup
+	Analyzers []paramGroup `xml:"componentList>analyzer"`
+	Software  struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"softwareRef"`
+}
+
+type softwareElement struct {
+	ID      string `xml:"id,attr"`
+	Version string `xml:"version,attr"`
+	paramGroup
+}
+
+type indexList struct {
+	Indexes []struct {
+		Name    string `xml:"name,attr"`
+		Offsets []struct {
+			Pos int64 `xml:",chardata"`
+		} `xml:"offset"`
+	} `xml:"index"`
+}
+
+func init() {
+	ms.RegisterFormat("mzML", isMzML, func(fn string) (ms.Reader, error) {
+		file, err := Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		return &file, nil
+	})
+}
+
+//isMzML reports whether the start of the file holds an mzML element
+func isMzML(head []byte) bool {
+	return bytes.Contains(head, []byte("<mzML")) || bytes.Contains(head, []byte("<indexedmzML"))
+}
+
+//Open opens the mzML file and reads its header and index. If the file is not
+//indexed, or the index is wrong, the index is built by reading the whole file.
+func Open(fn string) (file File, err error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return
+	}
+	file = File{f: f, filename: fn, groups: make(map[string][]cvParam), configs: make(map[string]ms.Analyzer)}
+	file.meta.SourceFile = fn
+	file.meta.FileFormat = "mzML"
+	if err = file.readHeader(); err != nil {
+		f.Close()
+		return
+	}
+	if !file.readIndex() {
+		if err = file.buildIndex(); err != nil {
+			f.Close()
+			return
+		}
+	}
+	if n := len(file.spectra); n > 0 {
+		first, last := file.header(1), file.header(n)
+		file.meta.StartTime, file.meta.EndTime = first.Time, last.Time
+		file.meta.LowMz, file.meta.HighMz = mzRange(file.header, n)
+	}
+	return
+}
+
+//Close closes the mzML file
+func (mf *File) Close() error {
+	return mf.f.Close()
+}
+
+//NScans returns the number of spectra in the file
+func (mf *File) NScans() int {
+	return len(mf.spectra)
+}
+
+//Metadata returns the file, sample and instrument information of the run
+func (mf *File) Metadata() ms.Metadata {
+	return mf.meta
+}
+
+/*
+   AllScans is a convenience function that runs over all spectra in the mzML file
+
+   On every encountered MS Scan, the function fun is called
+*/
+func (mf *File) AllScans(fun func(scan ms.Scan)) {
+	for i := 1; i <= mf.NScans(); i++ {
+		fun(mf.Scan(i))
+	}
+}
+
+/*
+   Scan returns the scan at the scan number in argument
+
+   The scan number is the position of the spectrum in the file, counting from 1.
+   The Number of the scan is taken from the native id of the spectrum when it has
+   one, it differs from the position when the file does not hold every scan of the run.
+*/
+func (mf *File) Scan(sn int) (scan ms.Scan) {
+	scan, err := mf.readScan(sn, true)
+	if err != nil {
+		log.Print("Scan ", sn, ": ", err)
+	}
+	return
+}
+
+//Spectrum returns the spectrum of the scan number in argument
+func (mf *File) Spectrum(sn int) ms.Spectrum {
+	return mf.Scan(sn).Spectrum
+}
+
+//header returns the scan without reading its spectrum
+func (mf *File) header(sn int) ms.Scan {
+	scan, err := mf.readScan(sn, false)
+	if err != nil {
+		log.Print("Scan ", sn, ": ", err)
+	}
+	return scan
+}
+
+//readScan reads the spectrum element of the scan number and maps it to an ms.Scan
+func (mf *File) readScan(sn int, arrays bool) (scan ms.Scan, err error) {
+	if sn < 1 || sn > mf.NScans() {
+		err = fmt.Errorf("scan number %d is out of bounds [1, %d]", sn, mf.NScans())
+		return
+	}
+	var el spectrumElement
+	if arrays {
+		err = mf.decodeAt(mf.spectra[sn-1], &el)
+	} else {
+		err = mf.decodeAt(mf.spectra[sn-1], &el.spectrumHeader)
+	}
+	if err != nil {
+		return
+	}
+
+	scan.Number = nativeScanNumber(el.ID, sn)
+	scan.Analyzer = mf.configs[mf.defaultConfig]
+	scan.MSLevel = 1
+	for _, p := range mf.params(el.paramGroup) {
+		switch p.Accession {
+		case termMSLevel.acc:
+			scan.MSLevel = uint8(atoi(p.Value))
+		case termCentroid.acc:
+			scan.Centroided = true
+		case termPositive.acc:
+			scan.Polarity = ms.Positive
+		case termNegative.acc:
+			scan.Polarity = ms.Negative
+		case termTIC.acc:
+			scan.TotalCurrent = atof(p.Value)
+		case termBasePeakMz.acc:
+			scan.BasePeak.Mz = atof(p.Value)
+		case termBasePeakIntensity.acc:
+			scan.BasePeak.I = float32(atof(p.Value))
+		case termLowestMz.acc:
+			scan.LowMz = atof(p.Value)
+		case termHighestMz.acc:
+			scan.HighMz = atof(p.Value)
+		}
+	}
+	if len(el.Scans) > 0 {
+		s := el.Scans[0]
+		if a, ok := mf.configs[s.Config]; ok {
+			scan.Analyzer = a
+		}
+		for _, p := range mf.params(s.paramGroup) {
+			switch p.Accession {
+			case termScanStartTime.acc:
+				scan.Time = atof(p.Value)
+				if p.UnitAccession == termSecond.acc {
+					scan.Time /= 60
+				}
+			case termFilterString.acc:
+				scan.Filter = p.Value
+			}
+		}
+		for _, w := range s.Windows {
+			for _, p := range mf.params(w) {
+				switch p.Accession {
+				case termScanWindowLower.acc:
+					scan.LowMz = atof(p.Value)
+				case termScanWindowUpper.acc:
+					scan.HighMz = atof(p.Value)
+				}
+			}
+		}
+	}
+	for _, pe := range el.Precursors {
+		p := mf.precursor(pe)
+		scan.Precursors = append(scan.Precursors, p)
+		scan.PrecursorMzs = append(scan.PrecursorMzs, p.Mz)
+	}
+
+	if !arrays {
+		return
+	}
+	var mzs, is []float64
+	for _, a := range el.Arrays {
+		params := mf.params(a.paramGroup)
+		var values *[]float64
+		for _, p := range params {
+			switch p.Accession {
+			case termMzArray.acc:
+				values = &mzs
+			case termIntensityArray.acc:
+				values = &is
+			}
+		}
+		if values == nil {
+			continue
+		}
+		if *values, err = decodeArray(a.Binary, params); err != nil {
+			return
+		}
+	}
+	if len(mzs) != len(is) {
+		err = fmt.Errorf("%d m/z and %d intensity values", len(mzs), len(is))
+		return
+	}
+	scan.Spectrum = make(ms.Spectrum, len(mzs))
+	for i := range mzs {
+		scan.Spectrum[i] = ms.Peak{Mz: mzs[i], I: float32(is[i])}
+	}
+	return
+}
+
+//precursor maps the precursor element
+func (mf *File) precursor(pe precursorElement) (p ms.Precursor) {
+	for _, c := range mf.params(pe.IsolationWindow) {
+		if c.Accession == termIsolationTarget.acc {
+			p.Mz = atof(c.Value)
+		}
+	}
+	if len(pe.SelectedIons) > 0 {
+		for _, c := range mf.params(pe.SelectedIons[0]) {
+			switch c.Accession {
+			case termSelectedIonMz.acc:
+				p.Mz = atof(c.Value)
+			case termChargeState.acc:
+				p.Charge = atoi(c.Value)
+			case termPeakIntensity.acc:
+				p.Intensity = float32(atof(c.Value))
+			}
+		}
+	}
+	for _, c := range mf.params(pe.Activation) {
+		if c.Accession == termCollisionEnergy.acc {
+			p.Energy = atof(c.Value)
+			continue
+		}
+		for a, t := range activationTerms {
+			if c.Accession != t.acc {
+				continue
+			}
+			switch {
+			case p.Activation == ms.ETD && a == ms.HCD, p.Activation == ms.HCD && a == ms.ETD:
+				p.Activation = ms.ETHCD
+			case p.Activation == ms.UnknownActivation:
+				p.Activation = a
+			}
+		}
+	}
+	return
+}
+
+//Chromatograms returns the chromatograms stored in the file
+func (mf *File) Chromatograms() []ms.Chromatogram {
+	var chroms []ms.Chromatogram
+	for _, pos := range mf.chromatograms {
+		var el chromatogramElement
+		if err := mf.decodeAt(pos, &el); err != nil {
+			log.Print("Chromatogram at ", pos, ": ", err)
+			continue
+		}
+		c := ms.Chromatogram{ID: el.ID}
+		for _, p := range mf.params(el.paramGroup) {
+			for t, term := range chromatogramTerms {
+				if p.Accession == term.acc {
+					c.Type = t
+				}
+			}
+		}
+		var times, is []float64
+		for _, a := range el.Arrays {
+			params := mf.params(a.paramGroup)
+			for _, p := range params {
+				var err error
+				switch p.Accession {
+				case termTimeArray.acc:
+					times, err = decodeArray(a.Binary, params)
+					if p.UnitAccession == termSecond.acc {
+						for i := range times {
+							times[i] /= 60
+						}
+					}
+				case termIntensityArray.acc:
+					is, err = decodeArray(a.Binary, params)
+				}
+				if err != nil {
+					log.Print("Chromatogram ", el.ID, ": ", err)
+				}
+			}
+		}
+		for i := 0; i < len(times) && i < len(is); i++ {
+			c.Points = append(c.Points, ms.ChromatogramPoint{Time: times[i], I: is[i]})
+		}
+		chroms = append(chroms, c)
+	}
+	return chroms
+}
+
+//params returns the cvParams of the element including those of referenced groups
+func (mf *File) params(g paramGroup) []cvParam {
+	if len(g.Refs) == 0 {
+		return g.CvParams
+	}
+	params := append([]cvParam(nil), g.CvParams...)
+	for _, r := range g.Refs {
+		params = append(params, mf.groups[r.Ref]...)
+	}
+	return params
+}
+
+//decodeAt decodes the element starting at the offset
+func (mf *File) decodeAt(pos int64, v interface{}) error {
+	d := xml.NewDecoder(io.NewSectionReader(mf.f, pos, 1<<62))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return d.DecodeElement(v, &start)
+		}
+	}
+}
+
+//readHeader reads the elements before the spectrum list
+func (mf *File) readHeader() error {
+	d := xml.NewDecoder(mf.f)
+	var software []softwareElement
+	var configs []configurationElement
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return errors.New("mzml: no run in file")
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "referenceableParamGroup":
+			var g struct {
+				ID string `xml:"id,attr"`
+				paramGroup
+			}
+			if err := d.DecodeElement(&g, &start); err != nil {
+				return err
+			}
+			mf.groups[g.ID] = g.CvParams
+		case "sourceFile":
+			var s struct {
+				paramGroup
+			}
+			if err := d.DecodeElement(&s, &start); err != nil {
+				return err
+			}
+			for _, p := range mf.params(s.paramGroup) {
+				if p.Accession == termThermoRaw.acc {
+					mf.meta.InstrumentVendor = "Thermo Scientific"
+				}
+			}
+		case "sample":
+			for _, a := range start.Attr {
+				if a.Name.Local == "name" && mf.meta.SampleID == "" {
+					mf.meta.SampleID = a.Value
+				}
+			}
+		case "software":
+			var s softwareElement
+			if err := d.DecodeElement(&s, &start); err != nil {
+				return err
+			}
+			software = append(software, s)
+		case "instrumentConfiguration":
+			var c configurationElement
+			if err := d.DecodeElement(&c, &start); err != nil {
+				return err
+			}
+			configs = append(configs, c)
+		case "run":
+			for _, a := range start.Attr {
+				switch a.Name.Local {
+				case "defaultInstrumentConfigurationRef":
+					mf.defaultConfig = a.Value
+				case "startTimeStamp":
+					mf.meta.AcquisitionDate, _ = time.Parse(time.RFC3339, a.Value)
+				}
+			}
+			mf.readConfigurations(configs, software)
+			return nil
+		}
+	}
+}
+
+//readConfigurations takes the analyzers of the configurations, and the
+//instrument model and serial number of the first
+func (mf *File) readConfigurations(configs []configurationElement, software []softwareElement) {
+	for i, c := range configs {
+		mf.configs[c.ID] = ms.Undefined
+		for _, a := range c.Analyzers {
+			for _, p := range mf.params(a) {
+				mf.configs[c.ID] = analyzerFromTerm(p.Accession)
+			}
+		}
+		if i > 0 {
+			continue
+		}
+		for _, p := range mf.params(c.paramGroup) {
+			switch {
+			case p.Accession == termSerialNumber.acc:
+				mf.meta.InstrumentSerial = p.Value
+			case !instrumentModel(p.Accession):
+			case p.Value != "":
+				mf.meta.InstrumentModel = p.Value
+			case modelBranch(p.Accession) && mf.meta.InstrumentModel != "":
+				//a branch without a value does not replace a model
+			default:
+				mf.meta.InstrumentModel = p.Name
+			}
+		}
+		for _, s := range software {
+			if s.ID == c.Software.Ref {
+				mf.meta.SoftwareVersion = s.Version
+			}
+		}
+	}
+	if mf.defaultConfig == "" && len(configs) > 0 {
+		mf.defaultConfig = configs[0].ID
+	}
+}
+
+//analyzerFromTerm returns the analyzer of a mass analyzer type term
+func analyzerFromTerm(acc string) ms.Analyzer {
+	switch acc {
+	case "MS:1000484", "MS:1000079":
+		return ms.FTMS
+	case "MS:1000264", "MS:1000082", "MS:1000291", "MS:1000083", "MS:1000078":
+		return ms.ITMS
+	case "MS:1000081":
+		return ms.TQMS
+	case "MS:1000084":
+		return ms.TOFMS
+	case "MS:1000080":
+		return ms.Sector
+	}
+	return ms.Undefined
+}
+
+var indexListOffset = regexp.MustCompile(`<indexListOffset>\s*(\d+)\s*</indexListOffset>`)
+
+//readIndex reads the index of an indexed mzML file and reports whether it is usable
+func (mf *File) readIndex() bool {
+	fi, err := mf.f.Stat()
+	if err != nil {
+		return false
+	}
+	tail := int64(4096)
+	if tail > fi.Size() {
+		tail = fi.Size()
+	}
+	b := make([]byte, tail)
+	if _, err := mf.f.ReadAt(b, fi.Size()-tail); err != nil {
+		return false
+	}
+	m := indexListOffset.FindSubmatch(b)
+	if m == nil {
+		return false
+	}
+	pos, _ := strconv.ParseInt(string(m[1]), 10, 64)
+	var list indexList
+	if err := mf.decodeAt(pos, &list); err != nil {
+		return false
+	}
+	for _, idx := range list.Indexes {
+		offsets := make([]int64, len(idx.Offsets))
+		for i, o := range idx.Offsets {
+			offsets[i] = o.Pos
+		}
+		switch idx.Name {
+		case "spectrum":
+			mf.spectra = offsets
+		case "chromatogram":
+			mf.chromatograms = offsets
+		}
+	}
+	return mf.startsWith(mf.spectra, "<spectrum") && mf.startsWith(mf.chromatograms, "<chromatogram")
+}
+
+//startsWith checks that the first and last offsets point at the element
+func (mf *File) startsWith(offsets []int64, tag string) bool {
+	if len(offsets) == 0 {
+		return true
+	}
+	b := make([]byte, len(tag)+1)
+	for _, i := range []int{0, len(offsets) - 1} {
+		if _, err := mf.f.ReadAt(b, offsets[i]); err != nil {
+			return false
+		}
+		if !bytes.HasPrefix(b, []byte(tag)) || (b[len(tag)] != ' ' && b[len(tag)] != '>') {
+			return false
+		}
+	}
+	return true
+}
+
+//buildIndex finds the spectrum and chromatogram elements by reading the file
+func (mf *File) buildIndex() error {
+	mf.spectra, mf.chromatograms = nil, nil
+	d := xml.NewDecoder(io.NewSectionReader(mf.f, 0, 1<<62))
+	for {
+		pos := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "spectrum":
+			mf.spectra = append(mf.spectra, pos)
+		case "chromatogram":
+			mf.chromatograms = append(mf.chromatograms, pos)
+		default:
+			continue
+		}
+		if err := d.Skip(); err != nil {
+			return err
+		}
+	}
+}
+
+//mzRange returns the lowest and highest m/z of the headers of the n scans
+func mzRange(header func(sn int) ms.Scan, n int) (low, high float64) {
+	for sn := 1; sn <= n; sn++ {
+		h := header(sn)
+		if h.LowMz > 0 && (low == 0 || h.LowMz < low) {
+			low = h.LowMz
+		}
+		if h.HighMz > high {
+			high = h.HighMz
+		}
+	}
+	return
+}
+
+//nativeScanNumber returns the scan number of a native id like
+//"controllerType=0 controllerNumber=1 scan=12", or def if it has none
+func nativeScanNumber(id string, def int) int {
+	for _, f := range strings.Fields(id) {
+		if strings.HasPrefix(f, "scan=") || strings.HasPrefix(f, "scanId=") {
+			if n, err := strconv.Atoi(f[strings.IndexByte(f, '=')+1:]); err == nil {
+				return n
+			}
+		}
+	}
+	return def
+}
+
+func atof(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func atoi(s string) int {
+	v, _ := strconv.Atoi(s)
+	return v
+}
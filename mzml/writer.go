@@ -0,0 +1,500 @@
+//Package mzml reads and writes the HUPO-PSI mzML format
+package mzml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/internal/xmlutil"
+)
+
+//Options controls the encoding of the binary data arrays
+type Options struct {
+	//MzFloat32 writes m/z and time arrays as 32-bit floats instead of 64-bit
+	MzFloat32 bool
+	//IntensityFloat64 writes intensity arrays as 64-bit floats instead of 32-bit
+	IntensityFloat64 bool
+	//MzNumpress and IntensityNumpress encode the arrays with MS-Numpress, which
+	//takes the place of the float encoding and is followed by Compression
+	MzNumpress        Numpress
+	IntensityNumpress Numpress
+	Compression       Compression
+}
+
+//Writer writes an indexed mzML 1.1 file. Spectra are written in order with
+//WriteSpectrum, optionally followed by WriteChromatograms, and Close writes the index.
+type Writer struct {
+	out     *xmlutil.CountingWriter
+	opts    Options
+	thermo  bool
+	configs map[ms.Analyzer]string
+	//nSpectra is the spectrum count announced in the header
+	nSpectra      int
+	spectra       []offset
+	chromatograms []offset
+	//lastID is the id of the last spectrum written at each MS level
+	lastID   map[uint8]string
+	finished bool
+}
+
+//offset is an entry of the index
+type offset struct {
+	id  string
+	pos int64
+}
+
+//NewWriter writes the mzML header to w. The headers are the scans that will be written
+//(their spectra are not needed), they determine the spectrum count, MS levels and
+//instrument configurations. The SHA-1 of meta.SourceFile is computed if it is set.
+func NewWriter(w io.Writer, meta ms.Metadata, headers []ms.Scan, opts Options) (*Writer, error) {
+	wr := &Writer{
+		out:      xmlutil.NewCountingWriter(w),
+		opts:     opts,
+		thermo:   meta.FileFormat == "Thermo RAW",
+		configs:  make(map[ms.Analyzer]string),
+		nSpectra: len(headers),
+		lastID:   make(map[uint8]string),
+	}
+	var checksum string
+	if meta.SourceFile != "" {
+		var err error
+		if checksum, err = xmlutil.FileSHA1(meta.SourceFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var analyzers []ms.Analyzer
+	var ms1, msn bool
+	for _, h := range headers {
+		if _, ok := wr.configs[h.Analyzer]; !ok {
+			analyzers = append(analyzers, h.Analyzer)
+			wr.configs[h.Analyzer] = "IC" + strconv.Itoa(len(analyzers))
+		}
+		if h.MSLevel > 1 {
+			msn = true
+		} else {
+			ms1 = true
+		}
+	}
+
+	var name string
+	if meta.SourceFile != "" {
+		name = strings.TrimSuffix(filepath.Base(meta.SourceFile), filepath.Ext(meta.SourceFile))
+	}
+	o := wr.out
+	fmt.Fprint(o, `<?xml version="1.0" encoding="utf-8"?>`+"\n")
+	fmt.Fprint(o, `<indexedmzML xmlns="http://psi.hupo.org/ms/mzml" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://psi.hupo.org/ms/mzml http://psidev.info/files/ms/mzML/xsd/mzML1.1.2_idx.xsd">`+"\n")
+	fmt.Fprintf(o, `  <mzML xmlns="http://psi.hupo.org/ms/mzml" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://psi.hupo.org/ms/mzml http://psidev.info/files/ms/mzML/xsd/mzML1.1.0.xsd" id="%s" version="1.1.0">`+"\n", xmlutil.Esc(name))
+	fmt.Fprint(o, `    <cvList count="2">`+"\n")
+	fmt.Fprint(o, `      <cv id="MS" fullName="Proteomics Standards Initiative Mass Spectrometry Ontology" version="4.1.30" URI="https://raw.githubusercontent.com/HUPO-PSI/psi-ms-CV/master/psi-ms.obo"/>`+"\n")
+	fmt.Fprint(o, `      <cv id="UO" fullName="Unit Ontology" version="09:04:2014" URI="https://raw.githubusercontent.com/bio-ontology-research-group/unit-ontology/master/unit.obo"/>`+"\n")
+	fmt.Fprint(o, "    </cvList>\n")
+
+	fmt.Fprint(o, "    <fileDescription>\n      <fileContent>\n")
+	if ms1 || !msn {
+		wr.cv(8, termMS1Spectrum, "")
+	}
+	if msn {
+		wr.cv(8, termMSnSpectrum, "")
+	}
+	fmt.Fprint(o, "      </fileContent>\n")
+	if meta.SourceFile != "" {
+		fmt.Fprint(o, `      <sourceFileList count="1">`+"\n")
+		fmt.Fprintf(o, `        <sourceFile id="RAW1" name="%s" location="%s">`+"\n",
+			xmlutil.Esc(filepath.Base(meta.SourceFile)), xmlutil.Esc(xmlutil.FileURI(filepath.Dir(meta.SourceFile))))
+		if wr.thermo {
+			wr.cv(10, termThermoNativeID, "")
+			wr.cv(10, termThermoRaw, "")
+		} else {
+			wr.cv(10, termScanNumberNativeID, "")
+			wr.cv(10, termFileFormat, meta.FileFormat)
+		}
+		wr.cv(10, termSHA1, checksum)
+		fmt.Fprint(o, "        </sourceFile>\n      </sourceFileList>\n")
+	}
+	fmt.Fprint(o, "    </fileDescription>\n")
+
+	if meta.SampleID != "" {
+		fmt.Fprint(o, `    <sampleList count="1">`+"\n")
+		fmt.Fprintf(o, `      <sample id="sample1" name="%s"/>`+"\n", xmlutil.Esc(meta.SampleID))
+		fmt.Fprint(o, "    </sampleList>\n")
+	}
+
+	nsoftware := 1
+	if wr.thermo {
+		nsoftware++
+	}
+	fmt.Fprintf(o, `    <softwareList count="%d">`+"\n", nsoftware)
+	fmt.Fprint(o, `      <software id="ms" version="1">`+"\n")
+	wr.cv(8, termCustomSoftware, "github.com/danhitchcock/ms")
+	fmt.Fprint(o, "      </software>\n")
+	if wr.thermo {
+		version := meta.SoftwareVersion
+		if version == "" {
+			version = "unknown"
+		}
+		fmt.Fprintf(o, `      <software id="Xcalibur" version="%s">`+"\n", xmlutil.Esc(version))
+		wr.cv(8, termXcalibur, "")
+		fmt.Fprint(o, "      </software>\n")
+	}
+	fmt.Fprint(o, "    </softwareList>\n")
+
+	wr.writeConfigurations(meta, analyzers)
+
+	fmt.Fprint(o, `    <dataProcessingList count="1">`+"\n")
+	fmt.Fprint(o, `      <dataProcessing id="conversion">`+"\n")
+	fmt.Fprint(o, `        <processingMethod order="1" softwareRef="ms">`+"\n")
+	wr.cv(10, termConversion, "")
+	fmt.Fprint(o, "        </processingMethod>\n      </dataProcessing>\n    </dataProcessingList>\n")
+
+	fmt.Fprintf(o, `    <run id="%s" defaultInstrumentConfigurationRef="IC1"`, ncname(name))
+	if meta.SourceFile != "" {
+		fmt.Fprint(o, ` defaultSourceFileRef="RAW1"`)
+	}
+	if meta.SampleID != "" {
+		fmt.Fprint(o, ` sampleRef="sample1"`)
+	}
+	if !meta.AcquisitionDate.IsZero() {
+		fmt.Fprintf(o, ` startTimeStamp="%s"`, meta.AcquisitionDate.Format(time.RFC3339))
+	}
+	fmt.Fprint(o, ">\n")
+	fmt.Fprintf(o, `      <spectrumList count="%d" defaultDataProcessingRef="conversion">`+"\n", wr.nSpectra)
+	return wr, nil
+}
+
+//writeConfigurations writes an instrument configuration for every analyzer
+func (w *Writer) writeConfigurations(meta ms.Metadata, analyzers []ms.Analyzer) {
+	o := w.out
+	n := len(analyzers)
+	if n == 0 {
+		n = 1
+	}
+	fmt.Fprintf(o, `    <instrumentConfigurationList count="%d">`+"\n", n)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(o, `      <instrumentConfiguration id="IC%d">`+"\n", i+1)
+		if t, ok := thermoModels[meta.InstrumentModel]; ok && w.thermo {
+			w.cv(8, t, "")
+		} else if w.thermo {
+			w.cv(8, termThermoModel, meta.InstrumentModel)
+		} else {
+			w.cv(8, termInstrumentModel, meta.InstrumentModel)
+		}
+		if meta.InstrumentSerial != "" {
+			w.cv(8, termSerialNumber, meta.InstrumentSerial)
+		}
+		if i < len(analyzers) {
+			a := analyzers[i]
+			fmt.Fprint(o, `        <componentList count="3">`+"\n")
+			fmt.Fprint(o, `          <source order="1">`+"\n")
+			w.cv(12, termIonizationType, "")
+			fmt.Fprint(o, "          </source>\n")
+			fmt.Fprint(o, `          <analyzer order="2">`+"\n")
+			w.cv(12, analyzerTerm(a, meta.InstrumentModel), "")
+			fmt.Fprint(o, "          </analyzer>\n")
+			fmt.Fprint(o, `          <detector order="3">`+"\n")
+			switch a {
+			case ms.FTMS:
+				w.cv(12, termInductiveDetector, "")
+			case ms.ITMS, ms.TQMS, ms.SQMS:
+				w.cv(12, termElectronMultiplier, "")
+			default:
+				w.cv(12, termDetectorType, "")
+			}
+			fmt.Fprint(o, "          </detector>\n        </componentList>\n")
+		}
+		if w.thermo {
+			fmt.Fprint(o, `        <softwareRef ref="Xcalibur"/>`+"\n")
+		}
+		fmt.Fprint(o, "      </instrumentConfiguration>\n")
+	}
+	fmt.Fprint(o, "    </instrumentConfigurationList>\n")
+}
+
+//nativeID returns the spectrum id in the native id format of the source
+func (w *Writer) nativeID(scan ms.Scan) string {
+	if w.thermo {
+		return "controllerType=0 controllerNumber=1 scan=" + strconv.Itoa(scan.Number)
+	}
+	return "scan=" + strconv.Itoa(scan.Number)
+}
+
+//WriteSpectrum writes the scan with its spectrum
+func (w *Writer) WriteSpectrum(scan ms.Scan) error {
+	if w.finished {
+		return errors.New("mzml: spectrum written after the chromatograms")
+	}
+	mzs := make([]float64, len(scan.Spectrum))
+	is := make([]float64, len(scan.Spectrum))
+	for i, p := range scan.Spectrum {
+		mzs[i] = p.Mz
+		is[i] = float64(p.I)
+	}
+	mzData, err := encodeArray(mzs, w.opts.MzFloat32, w.opts.MzNumpress, w.opts.Compression)
+	if err != nil {
+		return err
+	}
+	iData, err := encodeArray(is, !w.opts.IntensityFloat64, w.opts.IntensityNumpress, w.opts.Compression)
+	if err != nil {
+		return err
+	}
+
+	id := w.nativeID(scan)
+	o := w.out
+	w.spectra = append(w.spectra, offset{id, o.N + 8})
+	fmt.Fprintf(o, `        <spectrum index="%d" id="%s" defaultArrayLength="%d">`+"\n", len(w.spectra)-1, xmlutil.Esc(id), len(scan.Spectrum))
+	level := scan.MSLevel
+	if level == 0 {
+		level = 1
+	}
+	w.cv(10, termMSLevel, strconv.Itoa(int(level)))
+	if level == 1 {
+		w.cv(10, termMS1Spectrum, "")
+	} else {
+		w.cv(10, termMSnSpectrum, "")
+	}
+	if scan.Centroided {
+		w.cv(10, termCentroid, "")
+	} else {
+		w.cv(10, termProfile, "")
+	}
+	switch scan.Polarity {
+	case ms.Positive:
+		w.cv(10, termPositive, "")
+	case ms.Negative:
+		w.cv(10, termNegative, "")
+	}
+	w.cv(10, termTIC, xmlutil.Ftoa(scan.TotalCurrent))
+	if scan.BasePeak.Mz > 0 {
+		w.cvUnit(10, termBasePeakMz, xmlutil.Ftoa(scan.BasePeak.Mz), termMzUnit)
+		w.cvUnit(10, termBasePeakIntensity, xmlutil.Ftoa(float64(scan.BasePeak.I)), termDetectorCounts)
+	}
+	if len(scan.Spectrum) > 0 {
+		w.cvUnit(10, termLowestMz, xmlutil.Ftoa(scan.Spectrum[0].Mz), termMzUnit)
+		w.cvUnit(10, termHighestMz, xmlutil.Ftoa(scan.Spectrum[len(scan.Spectrum)-1].Mz), termMzUnit)
+	}
+
+	fmt.Fprint(o, `          <scanList count="1">`+"\n")
+	w.cv(12, termNoCombination, "")
+	if ref := w.configs[scan.Analyzer]; ref != "" && ref != "IC1" {
+		fmt.Fprintf(o, `            <scan instrumentConfigurationRef="%s">`+"\n", ref)
+	} else {
+		fmt.Fprint(o, "            <scan>\n")
+	}
+	w.cvUnit(14, termScanStartTime, xmlutil.Ftoa(scan.Time), termMinute)
+	if scan.Filter != "" {
+		w.cv(14, termFilterString, scan.Filter)
+	}
+	if scan.HighMz > 0 {
+		fmt.Fprint(o, `              <scanWindowList count="1">`+"\n                <scanWindow>\n")
+		w.cvUnit(18, termScanWindowLower, xmlutil.Ftoa(scan.LowMz), termMzUnit)
+		w.cvUnit(18, termScanWindowUpper, xmlutil.Ftoa(scan.HighMz), termMzUnit)
+		fmt.Fprint(o, "                </scanWindow>\n              </scanWindowList>\n")
+	}
+	fmt.Fprint(o, "            </scan>\n          </scanList>\n")
+
+	precursors := scan.Precursors
+	if len(precursors) == 0 {
+		for _, mz := range scan.PrecursorMzs {
+			precursors = append(precursors, ms.Precursor{Mz: mz})
+		}
+	}
+	if len(precursors) > 0 {
+		fmt.Fprintf(o, `          <precursorList count="%d">`+"\n", len(precursors))
+		for _, p := range precursors {
+			if ref, ok := w.lastID[level-1]; ok {
+				fmt.Fprintf(o, `            <precursor spectrumRef="%s">`+"\n", xmlutil.Esc(ref))
+			} else {
+				fmt.Fprint(o, "            <precursor>\n")
+			}
+			fmt.Fprint(o, "              <isolationWindow>\n")
+			w.cvUnit(16, termIsolationTarget, xmlutil.Ftoa(p.Mz), termMzUnit)
+			fmt.Fprint(o, "              </isolationWindow>\n")
+			fmt.Fprint(o, `              <selectedIonList count="1">`+"\n                <selectedIon>\n")
+			w.cvUnit(18, termSelectedIonMz, xmlutil.Ftoa(p.Mz), termMzUnit)
+			if p.Charge != 0 {
+				w.cv(18, termChargeState, strconv.Itoa(p.Charge))
+			}
+			if p.Intensity > 0 {
+				w.cvUnit(18, termPeakIntensity, xmlutil.Ftoa(float64(p.Intensity)), termDetectorCounts)
+			}
+			fmt.Fprint(o, "                </selectedIon>\n              </selectedIonList>\n")
+			fmt.Fprint(o, "              <activation>\n")
+			if t, ok := activationTerms[p.Activation]; ok {
+				w.cv(16, t, "")
+			}
+			if p.Energy > 0 {
+				w.cvUnit(16, termCollisionEnergy, xmlutil.Ftoa(p.Energy), termElectronvolt)
+			}
+			fmt.Fprint(o, "              </activation>\n            </precursor>\n")
+		}
+		fmt.Fprint(o, "          </precursorList>\n")
+	}
+
+	fmt.Fprint(o, `          <binaryDataArrayList count="2">`+"\n")
+	w.writeArray(termMzArray, termMzUnit, mzData, w.opts.MzFloat32, w.opts.MzNumpress)
+	w.writeArray(termIntensityArray, termDetectorCounts, iData, !w.opts.IntensityFloat64, w.opts.IntensityNumpress)
+	fmt.Fprint(o, "          </binaryDataArrayList>\n        </spectrum>\n")
+
+	w.lastID[level] = id
+	return nil
+}
+
+//writeArray writes an encoded binary data array. Numpress arrays
+//decode to 64-bit floats.
+func (w *Writer) writeArray(array term, unit term, data string, single bool, np Numpress) {
+	fmt.Fprintf(w.out, `            <binaryDataArray encodedLength="%d">`+"\n", len(data))
+	if single && np == NoNumpress {
+		w.cv(14, termFloat32, "")
+	} else {
+		w.cv(14, termFloat64, "")
+	}
+	if t, ok := numpressTerms[np]; ok {
+		if w.opts.Compression == Zlib {
+			w.cv(14, t[1], "")
+		} else {
+			w.cv(14, t[0], "")
+		}
+	} else if w.opts.Compression == Zlib {
+		w.cv(14, termZlib, "")
+	} else {
+		w.cv(14, termNoCompression, "")
+	}
+	w.cvUnit(14, array, "", unit)
+	fmt.Fprintf(w.out, "              <binary>%s</binary>\n            </binaryDataArray>\n", data)
+}
+
+//chromatogramTerms are the chromatogram types
+var chromatogramTerms = map[ms.ChromatogramType]term{
+	ms.TIC: termTICChromatogram,
+	ms.BPC: termBPChromatogram,
+	ms.XIC: termSICChromatogram,
+}
+
+//WriteChromatograms writes the chromatograms after the last spectrum, it may be called once
+func (w *Writer) WriteChromatograms(chroms []ms.Chromatogram) error {
+	if w.finished {
+		return errors.New("mzml: chromatograms written twice")
+	}
+	if err := w.endSpectra(); err != nil {
+		return err
+	}
+	if len(chroms) == 0 {
+		return nil
+	}
+	o := w.out
+	fmt.Fprintf(o, `      <chromatogramList count="%d" defaultDataProcessingRef="conversion">`+"\n", len(chroms))
+	for i, c := range chroms {
+		times := make([]float64, len(c.Points))
+		is := make([]float64, len(c.Points))
+		for j, p := range c.Points {
+			times[j] = p.Time
+			is[j] = p.I
+		}
+		tData, err := encodeArray(times, w.opts.MzFloat32, w.opts.MzNumpress, w.opts.Compression)
+		if err != nil {
+			return err
+		}
+		iData, err := encodeArray(is, !w.opts.IntensityFloat64, w.opts.IntensityNumpress, w.opts.Compression)
+		if err != nil {
+			return err
+		}
+		id := c.ID
+		if id == "" {
+			id = "chromatogram" + strconv.Itoa(i+1)
+		}
+		w.chromatograms = append(w.chromatograms, offset{id, o.N + 8})
+		fmt.Fprintf(o, `        <chromatogram index="%d" id="%s" defaultArrayLength="%d">`+"\n", i, xmlutil.Esc(id), len(c.Points))
+		if t, ok := chromatogramTerms[c.Type]; ok {
+			w.cv(10, t, "")
+		} else {
+			w.cv(10, termChromatogramType, "")
+		}
+		fmt.Fprint(o, `          <binaryDataArrayList count="2">`+"\n")
+		w.writeArray(termTimeArray, termMinute, tData, w.opts.MzFloat32, w.opts.MzNumpress)
+		w.writeArray(termIntensityArray, termDetectorCounts, iData, !w.opts.IntensityFloat64, w.opts.IntensityNumpress)
+		fmt.Fprint(o, "          </binaryDataArrayList>\n        </chromatogram>\n")
+	}
+	fmt.Fprint(o, "      </chromatogramList>\n")
+	return nil
+}
+
+//endSpectra closes the spectrum list
+func (w *Writer) endSpectra() error {
+	w.finished = true
+	fmt.Fprint(w.out, "      </spectrumList>\n")
+	if len(w.spectra) != w.nSpectra {
+		return fmt.Errorf("mzml: %d spectra written, %d announced", len(w.spectra), w.nSpectra)
+	}
+	return nil
+}
+
+//Close writes the index and the file checksum and flushes the output.
+//It does not close the underlying writer.
+func (w *Writer) Close() error {
+	var err error
+	if !w.finished {
+		err = w.endSpectra()
+	}
+	o := w.out
+	fmt.Fprint(o, "    </run>\n  </mzML>\n")
+	indexOffset := o.N + 2
+	nindex := 1
+	if len(w.chromatograms) > 0 {
+		nindex++
+	}
+	fmt.Fprintf(o, `  <indexList count="%d">`+"\n", nindex)
+	writeIndex(o, "spectrum", w.spectra)
+	if len(w.chromatograms) > 0 {
+		writeIndex(o, "chromatogram", w.chromatograms)
+	}
+	fmt.Fprint(o, "  </indexList>\n")
+	fmt.Fprintf(o, "  <indexListOffset>%d</indexListOffset>\n", indexOffset)
+	fmt.Fprint(o, "  <fileChecksum>")
+	fmt.Fprintf(o, "%s</fileChecksum>\n</indexedmzML>\n", o.SHA1())
+	if ferr := o.Flush(); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+//writeIndex writes the offsets of the elements
+func writeIndex(o io.Writer, name string, offsets []offset) {
+	fmt.Fprintf(o, `    <index name="%s">`+"\n", name)
+	for _, off := range offsets {
+		fmt.Fprintf(o, `      <offset idRef="%s">%d</offset>`+"\n", xmlutil.Esc(off.id), off.pos)
+	}
+	fmt.Fprint(o, "    </index>\n")
+}
+
+//cv writes a cvParam at the indentation
+func (w *Writer) cv(indent int, t term, value string) {
+	fmt.Fprintf(w.out, `%s<cvParam cvRef="%s" accession="%s" name="%s" value="%s"/>`+"\n",
+		strings.Repeat(" ", indent), t.cvRef(), t.acc, xmlutil.Esc(t.name), xmlutil.Esc(value))
+}
+
+//cvUnit writes a cvParam with a unit at the indentation
+func (w *Writer) cvUnit(indent int, t term, value string, unit term) {
+	fmt.Fprintf(w.out, `%s<cvParam cvRef="%s" accession="%s" name="%s" value="%s" unitCvRef="%s" unitAccession="%s" unitName="%s"/>`+"\n",
+		strings.Repeat(" ", indent), t.cvRef(), t.acc, xmlutil.Esc(t.name), xmlutil.Esc(value), unit.cvRef(), unit.acc, xmlutil.Esc(unit.name))
+}
+
+//ncname makes s usable as an xs:ID
+func ncname(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' ||
+			i > 0 && (c >= '0' && c <= '9' || c == '-' || c == '.')) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "run"
+	}
+	return string(b)
+}
@@ -0,0 +1,168 @@
+package mzml
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/danhitchcock/ms"
+)
+
+//testScans returns an MS1 scan and two MS2 scans of it
+func testScans() []ms.Scan {
+	ms1 := ms.Scan{Number: 1, MSLevel: 1, Time: 0.5, Polarity: ms.Positive, Analyzer: ms.FTMS, Centroided: true,
+		LowMz: 100, HighMz: 2000, Filter: "FTMS + c NSI Full ms [100.0000-2000.0000]",
+		Spectrum: ms.Spectrum{{Mz: 100.123456789, I: 1.5}, {Mz: 500.987654321, I: 12345.678}, {Mz: 1999.000001, I: 3e6}}}
+	ms1.TotalCurrent = 1.5 + 12345.678 + 3e6
+	ms1.BasePeak = ms1.Spectrum[2]
+	cid := ms.Scan{Number: 2, MSLevel: 2, Time: 0.51, Polarity: ms.Positive, Analyzer: ms.ITMS, Centroided: true,
+		LowMz: 130, HighMz: 1000, TotalCurrent: 300, BasePeak: ms.Peak{Mz: 400.25, I: 200},
+		Precursors:   []ms.Precursor{{Mz: 500.987654321, Charge: 2, Intensity: 12345.678, Activation: ms.CID, Energy: 35}},
+		PrecursorMzs: []float64{500.987654321},
+		Spectrum:     ms.Spectrum{{Mz: 200.5, I: 100}, {Mz: 400.25, I: 200}}}
+	hcd := ms.Scan{Number: 3, MSLevel: 2, Time: 0.52, Polarity: ms.Negative, Analyzer: ms.FTMS,
+		Precursors:   []ms.Precursor{{Mz: 1999.000001, Charge: -3, Activation: ms.HCD, Energy: 28}},
+		PrecursorMzs: []float64{1999.000001},
+		Spectrum:     ms.Spectrum{}}
+	return []ms.Scan{ms1, cid, hcd}
+}
+
+//writeTemp writes the scans with the options to a file in the test directory
+func writeTemp(t *testing.T, scans []ms.Scan, opts Options) string {
+	fn := filepath.Join(t.TempDir(), "test.mzML")
+	f, err := os.Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w, err := NewWriter(f, ms.Metadata{InstrumentModel: "test"}, scans, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range scans {
+		if err := w.WriteSpectrum(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return fn
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		//mzTol and iTol are the relative errors of the encodings
+		mzTol, iTol float64
+	}{
+		{"default", Options{}, 0, 1e-7},
+		{"32-bit m/z", Options{MzFloat32: true}, 1e-7, 1e-7},
+		{"64-bit intensities", Options{IntensityFloat64: true}, 0, 0},
+		{"zlib", Options{Compression: Zlib}, 0, 1e-7},
+		{"numpress", Options{MzNumpress: NumpressLinear, IntensityNumpress: NumpressSlof}, 1e-8, 1e-3},
+		{"numpress and zlib", Options{MzNumpress: NumpressLinear, IntensityNumpress: NumpressPic, Compression: Zlib}, 1e-8, 0.5},
+	}
+	scans := testScans()
+	for _, tt := range tests {
+		fn := writeTemp(t, scans, tt.opts)
+		file, err := Open(fn)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if file.NScans() != len(scans) {
+			t.Fatalf("%s: %d scans, want %d", tt.name, file.NScans(), len(scans))
+		}
+		for i, want := range scans {
+			got := file.Scan(i + 1)
+			if got.Number != want.Number || got.MSLevel != want.MSLevel || got.Polarity != want.Polarity ||
+				got.Analyzer != want.Analyzer || got.Centroided != want.Centroided || got.Time != want.Time ||
+				got.Filter != want.Filter || got.TotalCurrent != want.TotalCurrent || got.BasePeak != want.BasePeak {
+				t.Errorf("%s: scan %d is %+v, want %+v", tt.name, i+1, got, want)
+			}
+			if want.HighMz > 0 && (got.LowMz != want.LowMz || got.HighMz != want.HighMz) {
+				t.Errorf("%s: scan %d window is %v-%v, want %v-%v", tt.name, i+1, got.LowMz, got.HighMz, want.LowMz, want.HighMz)
+			}
+			if len(got.Precursors) != len(want.Precursors) {
+				t.Errorf("%s: scan %d has precursors %+v, want %+v", tt.name, i+1, got.Precursors, want.Precursors)
+			} else {
+				for k := range want.Precursors {
+					if got.Precursors[k] != want.Precursors[k] || got.PrecursorMzs[k] != want.PrecursorMzs[k] {
+						t.Errorf("%s: scan %d precursor is %+v, want %+v", tt.name, i+1, got.Precursors[k], want.Precursors[k])
+					}
+				}
+			}
+			if len(got.Spectrum) != len(want.Spectrum) {
+				t.Errorf("%s: scan %d has %d peaks, want %d", tt.name, i+1, len(got.Spectrum), len(want.Spectrum))
+				continue
+			}
+			for k, p := range want.Spectrum {
+				q := got.Spectrum[k]
+				if math.Abs(q.Mz-p.Mz) > tt.mzTol*p.Mz || math.Abs(float64(q.I-p.I)) > tt.iTol*float64(p.I) {
+					t.Errorf("%s: scan %d peak %d is %v, want %v", tt.name, i+1, k, q, p)
+				}
+			}
+		}
+		meta := file.Metadata()
+		if meta.StartTime != scans[0].Time || meta.EndTime != scans[len(scans)-1].Time {
+			t.Errorf("%s: run is %v-%v", tt.name, meta.StartTime, meta.EndTime)
+		}
+		if meta.LowMz != 100 || meta.HighMz != 2000 {
+			t.Errorf("%s: m/z range is %v-%v, want 100-2000", tt.name, meta.LowMz, meta.HighMz)
+		}
+		if h := file.header(1); h.Number != 1 || len(h.Spectrum) != 0 {
+			t.Errorf("%s: header is scan %d with %d peaks", tt.name, h.Number, len(h.Spectrum))
+		}
+		file.Close()
+	}
+}
+
+func TestIndex(t *testing.T) {
+	scans := testScans()
+	fn := writeTemp(t, scans, Options{Compression: Zlib})
+	file, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if !file.readIndex() {
+		t.Fatal("the index is not usable")
+	}
+	indexed := file.spectra
+	if err := file.buildIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if len(indexed) != len(file.spectra) {
+		t.Fatalf("%d offsets in the index, %d spectra", len(indexed), len(file.spectra))
+	}
+	for i := range indexed {
+		if indexed[i] != file.spectra[i] {
+			t.Errorf("spectrum %d is at %d, the index has %d", i+1, file.spectra[i], indexed[i])
+		}
+	}
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the index references the spectra by id
+	for i, m := range regexp.MustCompile(`<offset idRef="([^"]*)">(\d+)</offset>`).FindAllSubmatch(b, -1) {
+		if id := "id=\"" + string(m[1]) + "\""; !bytes.HasPrefix(b[indexed[i]:], []byte(`<spectrum index=`)) ||
+			!bytes.Contains(b[indexed[i]:indexed[i]+100], []byte(id)) {
+			t.Errorf("offset %s does not point at the spectrum %s", m[2], m[1])
+		}
+	}
+	//the checksum covers the file up to and including <fileChecksum>
+	tag := []byte("<fileChecksum>")
+	i := bytes.Index(b, tag) + len(tag)
+	sum := sha1.Sum(b[:i])
+	if got := string(b[i : i+40]); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("file checksum is %s, want %s", got, hex.EncodeToString(sum[:]))
+	}
+}
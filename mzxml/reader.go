@@ -0,0 +1,547 @@
+package mzxml
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/danhitchcock/ms"
+)
+
+func init() {
+	ms.RegisterFormat("mzXML", isMzXML, func(fn string) (ms.Reader, error) {
+		file, err := Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		return &file, nil
+	})
+}
+
+//isMzXML reports whether the start of the file holds an mzXML element
+func isMzXML(head []byte) bool {
+	return bytes.Contains(head, []byte("<mzXML"))
+}
+
+//File is an mzXML file opened for random access to its scans.
+//Scan numbers count the scans in the file from 1, like in unthermo.File.
+type File struct {
+	f           *os.File
+	offsets     []int64
+	instruments map[string]ms.Analyzer
+	meta        ms.Metadata
+	//latin1 is set for ISO-8859-1 encoded files
+	latin1 bool
+}
+
+//scanHeader is a scan element without its peaks
+type scanHeader struct {
+	Num               int              `xml:"num,attr"`
+	MSLevel           int              `xml:"msLevel,attr"`
+	Centroided        string           `xml:"centroided,attr"`
+	Polarity          string           `xml:"polarity,attr"`
+	RetentionTime     string           `xml:"retentionTime,attr"`
+	Instrument        string           `xml:"msInstrumentID,attr"`
+	CollisionEnergy   float64          `xml:"collisionEnergy,attr"`
+	LowMz             float64          `xml:"lowMz,attr"`
+	HighMz            float64          `xml:"highMz,attr"`
+	StartMz           float64          `xml:"startMz,attr"`
+	EndMz             float64          `xml:"endMz,attr"`
+	BasePeakMz        float64          `xml:"basePeakMz,attr"`
+	BasePeakIntensity float64          `xml:"basePeakIntensity,attr"`
+	TotIonCurrent     float64          `xml:"totIonCurrent,attr"`
+	FilterLine        string           `xml:"filterLine,attr"`
+	Precursors        []precursorMzTag `xml:"precursorMz"`
+}
+
+type scanElement struct {
+	scanHeader
+	Peaks peaksElement `xml:"peaks"`
+}
+
+type precursorMzTag struct {
+	Intensity  float64 `xml:"precursorIntensity,attr"`
+	Charge     int     `xml:"precursorCharge,attr"`
+	Activation string  `xml:"activationMethod,attr"`
+	Mz         string  `xml:",chardata"`
+}
+
+type peaksElement struct {
+	Compression string `xml:"compressionType,attr"`
+	Precision   int    `xml:"precision,attr"`
+	ByteOrder   string `xml:"byteOrder,attr"`
+	ContentType string `xml:"contentType,attr"`
+	Data        string `xml:",chardata"`
+}
+
+type instrumentElement struct {
+	ID           string         `xml:"msInstrumentID,attr"`
+	Manufacturer categoryValue  `xml:"msManufacturer"`
+	Model        categoryValue  `xml:"msModel"`
+	Analyzer     categoryValue  `xml:"msMassAnalyzer"`
+	Software     softwareAttrib `xml:"software"`
+}
+
+type categoryValue struct {
+	Value string `xml:"value,attr"`
+}
+
+type softwareAttrib struct {
+	Version string `xml:"version,attr"`
+}
+
+//Open opens the mzXML file and reads its header and index. If the file is not
+//indexed, or the index is wrong, the index is built by reading the whole file.
+func Open(fn string) (file File, err error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return
+	}
+	file = File{f: f, instruments: make(map[string]ms.Analyzer)}
+	head := make([]byte, 256)
+	n, _ := f.ReadAt(head, 0)
+	file.latin1 = latin1Declaration.Match(head[:n])
+	file.meta.SourceFile = fn
+	file.meta.FileFormat = "mzXML"
+	if err = file.readHeader(); err != nil {
+		f.Close()
+		return
+	}
+	if !file.readIndex() {
+		if err = file.buildIndex(); err != nil {
+			f.Close()
+			return
+		}
+	}
+	if n := len(file.offsets); n > 0 {
+		first, last := file.header(1), file.header(n)
+		file.meta.StartTime, file.meta.EndTime = first.Time, last.Time
+		file.meta.LowMz, file.meta.HighMz = mzRange(file.header, n)
+	}
+	return
+}
+
+//Close closes the mzXML file
+func (xf *File) Close() error {
+	return xf.f.Close()
+}
+
+//NScans returns the number of scans in the file
+func (xf *File) NScans() int {
+	return len(xf.offsets)
+}
+
+//Metadata returns the file and instrument information of the run
+func (xf *File) Metadata() ms.Metadata {
+	return xf.meta
+}
+
+/*
+   AllScans is a convenience function that runs over all scans in the mzXML file
+
+   On every encountered MS Scan, the function fun is called
+*/
+func (xf *File) AllScans(fun func(scan ms.Scan)) {
+	for i := 1; i <= xf.NScans(); i++ {
+		fun(xf.Scan(i))
+	}
+}
+
+/*
+   Scan returns the scan at the scan number in argument
+*/
+func (xf *File) Scan(sn int) (scan ms.Scan) {
+	scan, err := xf.readScan(sn, true)
+	if err != nil {
+		log.Print("Scan ", sn, ": ", err)
+	}
+	return
+}
+
+//Spectrum returns the spectrum of the scan number in argument
+func (xf *File) Spectrum(sn int) ms.Spectrum {
+	return xf.Scan(sn).Spectrum
+}
+
+//header returns the scan without reading its spectrum
+func (xf *File) header(sn int) ms.Scan {
+	scan, err := xf.readScan(sn, false)
+	if err != nil {
+		log.Print("Scan ", sn, ": ", err)
+	}
+	return scan
+}
+
+//Chromatograms returns the total ion current and base peak chromatograms,
+//built from the scan attributes as mzXML does not store chromatograms
+func (xf *File) Chromatograms() []ms.Chromatogram {
+	tic := ms.Chromatogram{ID: "TIC", Type: ms.TIC}
+	bpc := ms.Chromatogram{ID: "BPC", Type: ms.BPC}
+	for i := 1; i <= xf.NScans(); i++ {
+		scan := xf.header(i)
+		if scan.MSLevel != 1 {
+			continue
+		}
+		tic.Points = append(tic.Points, ms.ChromatogramPoint{Time: scan.Time, I: scan.TotalCurrent, ScanNumber: i})
+		bpc.Points = append(bpc.Points, ms.ChromatogramPoint{Time: scan.Time, I: float64(scan.BasePeak.I), Mz: scan.BasePeak.Mz, ScanNumber: i})
+	}
+	return []ms.Chromatogram{tic, bpc}
+}
+
+//readScan reads the scan element of the scan number and maps it to an ms.Scan
+func (xf *File) readScan(sn int, peaks bool) (scan ms.Scan, err error) {
+	if sn < 1 || sn > xf.NScans() {
+		err = fmt.Errorf("scan number %d is out of bounds [1, %d]", sn, xf.NScans())
+		return
+	}
+	var el scanElement
+	if peaks {
+		err = xf.decodeAt(xf.offsets[sn-1], &el)
+	} else {
+		err = xf.decodeAt(xf.offsets[sn-1], &el.scanHeader)
+	}
+	if err != nil {
+		return
+	}
+
+	scan.Number = el.Num
+	scan.MSLevel = uint8(el.MSLevel)
+	scan.Centroided = el.Centroided == "1" || el.Centroided == "true"
+	switch el.Polarity {
+	case "+":
+		scan.Polarity = ms.Positive
+	case "-":
+		scan.Polarity = ms.Negative
+	}
+	scan.Time = parseDuration(el.RetentionTime)
+	scan.Analyzer = xf.instruments[el.Instrument]
+	if el.Instrument == "" && len(xf.instruments) > 0 {
+		scan.Analyzer = xf.instruments["1"]
+	}
+	scan.TotalCurrent = el.TotIonCurrent
+	scan.BasePeak = ms.Peak{Mz: el.BasePeakMz, I: float32(el.BasePeakIntensity)}
+	scan.LowMz, scan.HighMz = el.StartMz, el.EndMz
+	if scan.HighMz == 0 {
+		scan.LowMz, scan.HighMz = el.LowMz, el.HighMz
+	}
+	scan.Filter = el.FilterLine
+	for _, p := range el.Precursors {
+		mz, _ := strconv.ParseFloat(strings.TrimSpace(p.Mz), 64)
+		scan.PrecursorMzs = append(scan.PrecursorMzs, mz)
+		scan.Precursors = append(scan.Precursors, ms.Precursor{
+			Mz:         mz,
+			Charge:     p.Charge,
+			Intensity:  float32(p.Intensity),
+			Activation: parseActivation(p.Activation),
+			Energy:     el.CollisionEnergy,
+		})
+	}
+	if peaks {
+		scan.Spectrum, err = decodePeaks(el.Peaks)
+	}
+	return
+}
+
+//decodePeaks decodes the base64 m/z-intensity pairs
+func decodePeaks(p peaksElement) (ms.Spectrum, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(p.Data), ""))
+	if err != nil {
+		return nil, err
+	}
+	if p.Compression == "zlib" {
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	if p.ContentType != "" && p.ContentType != "m/z-int" {
+		return nil, fmt.Errorf("mzxml: unsupported peak content type %q", p.ContentType)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if p.ByteOrder == "little" {
+		order = binary.LittleEndian
+	}
+	width := 4
+	if p.Precision == 64 {
+		width = 8
+	}
+	if len(raw)%(2*width) != 0 {
+		return nil, fmt.Errorf("mzxml: %d bytes of peaks are no %d-bit pairs", len(raw), 8*width)
+	}
+	s := make(ms.Spectrum, len(raw)/(2*width))
+	for i := range s {
+		b := raw[2*width*i:]
+		if width == 8 {
+			s[i].Mz = math.Float64frombits(order.Uint64(b))
+			s[i].I = float32(math.Float64frombits(order.Uint64(b[8:])))
+		} else {
+			s[i].Mz = float64(math.Float32frombits(order.Uint32(b)))
+			s[i].I = math.Float32frombits(order.Uint32(b[4:]))
+		}
+	}
+	return s, nil
+}
+
+//mzRange returns the lowest and highest m/z of the headers of the n scans
+func mzRange(header func(sn int) ms.Scan, n int) (low, high float64) {
+	for sn := 1; sn <= n; sn++ {
+		h := header(sn)
+		if h.LowMz > 0 && (low == 0 || h.LowMz < low) {
+			low = h.LowMz
+		}
+		if h.HighMz > high {
+			high = h.HighMz
+		}
+	}
+	return
+}
+
+//parseActivation maps the mzXML activation method
+func parseActivation(method string) ms.Activation {
+	for a, name := range activationMethods {
+		if strings.EqualFold(method, name) {
+			return a
+		}
+	}
+	return ms.UnknownActivation
+}
+
+//parseAnalyzer maps the mzXML mass analyzer value
+func parseAnalyzer(value string) ms.Analyzer {
+	v := strings.ToLower(value)
+	switch {
+	case v == "ftms" || strings.Contains(v, "orbitrap") || strings.Contains(v, "fourier"):
+		return ms.FTMS
+	case v == "itms" || strings.Contains(v, "trap"):
+		return ms.ITMS
+	case v == "tofms" || strings.Contains(v, "tof") || strings.Contains(v, "time-of-flight"):
+		return ms.TOFMS
+	case v == "sqms":
+		return ms.SQMS
+	case v == "tqms" || strings.Contains(v, "quadrupole"):
+		return ms.TQMS
+	case v == "sector" || strings.Contains(v, "sector"):
+		return ms.Sector
+	}
+	return ms.Undefined
+}
+
+//parseDuration returns the minutes of an xs:duration such as "PT1M12.5S"
+func parseDuration(d string) float64 {
+	i := strings.IndexByte(d, 'T')
+	if i < 0 {
+		return 0
+	}
+	var minutes float64
+	num := ""
+	for _, c := range d[i+1:] {
+		switch c {
+		case 'H', 'M', 'S':
+			v, _ := strconv.ParseFloat(num, 64)
+			minutes += v * map[rune]float64{'H': 60, 'M': 1, 'S': 1.0 / 60}[c]
+			num = ""
+		default:
+			num += string(c)
+		}
+	}
+	return minutes
+}
+
+//readHeader reads the msRun attributes and instruments
+func (xf *File) readHeader() error {
+	d := xf.newDecoder(xf.f)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return errors.New("mzxml: no msRun in file")
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "msInstrument", "instrument":
+			var in instrumentElement
+			if err := d.DecodeElement(&in, &start); err != nil {
+				return err
+			}
+			if in.ID == "" {
+				in.ID = "1"
+			}
+			xf.instruments[in.ID] = parseAnalyzer(in.Analyzer.Value)
+			if xf.meta.InstrumentModel == "" {
+				xf.meta.InstrumentVendor = in.Manufacturer.Value
+				xf.meta.InstrumentModel = in.Model.Value
+				xf.meta.SoftwareVersion = in.Software.Version
+			}
+		case "scan":
+			return nil
+		}
+	}
+}
+
+var latin1Declaration = regexp.MustCompile(`(?i)^<\?xml[^>]*encoding=["'](iso-8859-1|latin1)["']`)
+
+var indexOffset = regexp.MustCompile(`<indexOffset>\s*(\d+)\s*</indexOffset>`)
+
+type indexElement struct {
+	Name    string `xml:"name,attr"`
+	Offsets []struct {
+		Pos int64 `xml:",chardata"`
+	} `xml:"offset"`
+}
+
+//readIndex reads the scan index and reports whether it is usable
+func (xf *File) readIndex() bool {
+	fi, err := xf.f.Stat()
+	if err != nil {
+		return false
+	}
+	tail := int64(4096)
+	if tail > fi.Size() {
+		tail = fi.Size()
+	}
+	b := make([]byte, tail)
+	if _, err := xf.f.ReadAt(b, fi.Size()-tail); err != nil {
+		return false
+	}
+	m := indexOffset.FindSubmatch(b)
+	if m == nil {
+		return false
+	}
+	pos, _ := strconv.ParseInt(string(m[1]), 10, 64)
+	var index indexElement
+	if err := xf.decodeAt(pos, &index); err != nil || index.Name != "scan" {
+		return false
+	}
+	for _, o := range index.Offsets {
+		xf.offsets = append(xf.offsets, o.Pos)
+	}
+	if len(xf.offsets) == 0 {
+		return false
+	}
+	tag := make([]byte, 6)
+	for _, pos := range []int64{xf.offsets[0], xf.offsets[len(xf.offsets)-1]} {
+		if _, err := xf.f.ReadAt(tag, pos); err != nil || string(tag) != "<scan " {
+			xf.offsets = nil
+			return false
+		}
+	}
+	return true
+}
+
+//buildIndex finds the scan elements, nested ones included, by searching
+//the file for their start tags. Base64 data and attribute values hold no '<'.
+func (xf *File) buildIndex() error {
+	xf.offsets = nil
+	r := bufio.NewReader(io.NewSectionReader(xf.f, 0, 1<<62))
+	tag := []byte("<scan")
+	var pos int64
+	matched := 0
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case matched == len(tag):
+			if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '>' {
+				xf.offsets = append(xf.offsets, pos-int64(len(tag)))
+			}
+			matched = 0
+		case c == tag[matched]:
+			matched++
+		default:
+			matched = 0
+		}
+		if matched == 0 && c == '<' {
+			matched = 1
+		}
+		pos++
+	}
+}
+
+//decodeAt decodes the element starting at the offset
+func (xf *File) decodeAt(pos int64, v interface{}) error {
+	d := xf.newDecoder(io.NewSectionReader(xf.f, pos, 1<<62))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return d.DecodeElement(v, &start)
+		}
+	}
+}
+
+//newDecoder returns a decoder that also reads the ISO-8859-1 files of older
+//converters, these are converted to UTF-8 before decoding
+func (xf *File) newDecoder(r io.Reader) *xml.Decoder {
+	if xf.latin1 {
+		r = &latin1Reader{r: bufio.NewReader(r)}
+	}
+	d := xml.NewDecoder(bufio.NewReader(r))
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "iso-8859-1", "latin1", "us-ascii":
+			return input, nil
+		}
+		return nil, fmt.Errorf("mzxml: unsupported charset %s", charset)
+	}
+	return d
+}
+
+//latin1Reader converts ISO-8859-1 to UTF-8
+type latin1Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(l.buf) > 0 {
+			c := copy(p[n:], l.buf)
+			l.buf = l.buf[c:]
+			n += c
+			continue
+		}
+		b, err := l.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b < utf8.RuneSelf {
+			p[n] = b
+			n++
+		} else {
+			l.buf = utf8.AppendRune(l.buf[:0], rune(b))
+		}
+	}
+	return n, nil
+}
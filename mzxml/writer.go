@@ -0,0 +1,283 @@
+//Package mzxml reads and writes the mzXML 3.2 format
+package mzxml
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/internal/xmlutil"
+)
+
+//Options controls the encoding of the peaks
+type Options struct {
+	//Float64 writes the peaks as 64-bit instead of 32-bit floats
+	Float64 bool
+	//Zlib compresses the peaks
+	Zlib bool
+}
+
+//Writer writes an indexed mzXML 3.2 file. Spectra are written in order with
+//WriteSpectrum and Close writes the scan index.
+type Writer struct {
+	out         *xmlutil.CountingWriter
+	opts        Options
+	instruments map[ms.Analyzer]int
+	nScans      int
+	offsets     []offset
+	//lastScan is the number of the last scan written at each MS level
+	lastScan map[uint8]int
+}
+
+//offset is an entry of the scan index
+type offset struct {
+	num int
+	pos int64
+}
+
+//NewWriter writes the mzXML header to w. The headers are the scans that will be written
+//(their spectra are not needed), they determine the scan count, run time and instruments.
+//The SHA-1 of meta.SourceFile is computed if it is set.
+func NewWriter(w io.Writer, meta ms.Metadata, headers []ms.Scan, opts Options) (*Writer, error) {
+	wr := &Writer{
+		out:         xmlutil.NewCountingWriter(w),
+		opts:        opts,
+		instruments: make(map[ms.Analyzer]int),
+		nScans:      len(headers),
+		lastScan:    make(map[uint8]int),
+	}
+	var analyzers []ms.Analyzer
+	centroided := len(headers) > 0
+	for _, h := range headers {
+		if _, ok := wr.instruments[h.Analyzer]; !ok {
+			analyzers = append(analyzers, h.Analyzer)
+			wr.instruments[h.Analyzer] = len(analyzers)
+		}
+		centroided = centroided && h.Centroided
+	}
+	if len(analyzers) == 0 {
+		analyzers = append(analyzers, ms.Undefined)
+	}
+
+	o := wr.out
+	fmt.Fprint(o, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprint(o, `<mzXML xmlns="http://sashimi.sourceforge.net/schema_revision/mzXML_3.2" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://sashimi.sourceforge.net/schema_revision/mzXML_3.2 http://sashimi.sourceforge.net/schema_revision/mzXML_3.2/mzXML_idx_3.2.xsd">`+"\n")
+	fmt.Fprintf(o, ` <msRun scanCount="%d"`, len(headers))
+	if len(headers) > 0 {
+		fmt.Fprintf(o, ` startTime="%s" endTime="%s"`, duration(headers[0].Time), duration(headers[len(headers)-1].Time))
+	}
+	fmt.Fprint(o, ">\n")
+
+	if meta.SourceFile != "" {
+		checksum, err := xmlutil.FileSHA1(meta.SourceFile)
+		if err != nil {
+			return nil, err
+		}
+		fileType := "RAWData"
+		if strings.EqualFold(filepath.Ext(meta.SourceFile), ".mzXML") {
+			fileType = "processedData"
+		}
+		fmt.Fprintf(o, `  <parentFile fileName="%s" fileType="%s" fileSha1="%s"/>`+"\n",
+			xmlutil.Esc(xmlutil.FileURI(meta.SourceFile)), fileType, checksum)
+	}
+
+	for i, a := range analyzers {
+		fmt.Fprintf(o, `  <msInstrument msInstrumentID="%d">`+"\n", i+1)
+		fmt.Fprintf(o, `   <msManufacturer category="msManufacturer" value="%s"/>`+"\n", xmlutil.Esc(orUnknown(meta.InstrumentVendor)))
+		fmt.Fprintf(o, `   <msModel category="msModel" value="%s"/>`+"\n", xmlutil.Esc(orUnknown(meta.InstrumentModel)))
+		fmt.Fprint(o, `   <msIonisation category="msIonisation" value="unknown"/>`+"\n")
+		fmt.Fprintf(o, `   <msMassAnalyzer category="msMassAnalyzer" value="%s"/>`+"\n", a)
+		fmt.Fprint(o, `   <msDetector category="msDetector" value="unknown"/>`+"\n")
+		fmt.Fprintf(o, `   <software type="acquisition" name="%s" version="%s"/>`+"\n",
+			acquisitionSoftware(meta), xmlutil.Esc(orUnknown(meta.SoftwareVersion)))
+		fmt.Fprint(o, "  </msInstrument>\n")
+	}
+
+	fmt.Fprintf(o, `  <dataProcessing centroided="%s">`+"\n", boolAttr(centroided))
+	fmt.Fprint(o, `   <software type="conversion" name="github.com/danhitchcock/ms" version="1"/>`+"\n")
+	fmt.Fprint(o, "  </dataProcessing>\n")
+	return wr, nil
+}
+
+//WriteSpectrum writes the scan with its spectrum
+func (w *Writer) WriteSpectrum(scan ms.Scan) error {
+	peaks, n, err := w.encodePeaks(scan.Spectrum)
+	if err != nil {
+		return err
+	}
+	level := scan.MSLevel
+	if level == 0 {
+		level = 1
+	}
+	num := scan.Number
+	if num == 0 {
+		num = len(w.offsets) + 1
+	}
+
+	o := w.out
+	w.offsets = append(w.offsets, offset{num, o.N + 2})
+	fmt.Fprintf(o, `  <scan num="%d" scanType="Full" centroided="%s" msLevel="%d" peaksCount="%d"`,
+		num, boolAttr(scan.Centroided), level, len(scan.Spectrum))
+	if p := scan.Polarity.String(); p != "" {
+		fmt.Fprintf(o, ` polarity="%s"`, p)
+	}
+	fmt.Fprintf(o, ` retentionTime="%s"`, duration(scan.Time))
+	if i := w.instruments[scan.Analyzer]; i > 1 {
+		fmt.Fprintf(o, ` msInstrumentID="%d"`, i)
+	}
+	if len(scan.Precursors) > 0 && scan.Precursors[0].Energy > 0 {
+		fmt.Fprintf(o, ` collisionEnergy="%s"`, xmlutil.Ftoa(scan.Precursors[0].Energy))
+	}
+	if len(scan.Spectrum) > 0 {
+		fmt.Fprintf(o, ` lowMz="%s" highMz="%s"`, xmlutil.Ftoa(scan.Spectrum[0].Mz), xmlutil.Ftoa(scan.Spectrum[len(scan.Spectrum)-1].Mz))
+	}
+	if scan.HighMz > 0 {
+		fmt.Fprintf(o, ` startMz="%s" endMz="%s"`, xmlutil.Ftoa(scan.LowMz), xmlutil.Ftoa(scan.HighMz))
+	}
+	if scan.BasePeak.Mz > 0 {
+		fmt.Fprintf(o, ` basePeakMz="%s" basePeakIntensity="%s"`, xmlutil.Ftoa(scan.BasePeak.Mz), xmlutil.Ftoa(float64(scan.BasePeak.I)))
+	}
+	fmt.Fprintf(o, ` totIonCurrent="%s"`, xmlutil.Ftoa(scan.TotalCurrent))
+	if scan.Filter != "" {
+		fmt.Fprintf(o, ` filterLine="%s"`, xmlutil.Esc(scan.Filter))
+	}
+	fmt.Fprint(o, ">\n")
+
+	precursors := scan.Precursors
+	if len(precursors) == 0 {
+		for _, mz := range scan.PrecursorMzs {
+			precursors = append(precursors, ms.Precursor{Mz: mz})
+		}
+	}
+	for _, p := range precursors {
+		fmt.Fprint(o, "   <precursorMz")
+		if sn, ok := w.lastScan[level-1]; ok {
+			fmt.Fprintf(o, ` precursorScanNum="%d"`, sn)
+		}
+		fmt.Fprintf(o, ` precursorIntensity="%s"`, xmlutil.Ftoa(float64(p.Intensity)))
+		if p.Charge != 0 {
+			fmt.Fprintf(o, ` precursorCharge="%d"`, p.Charge)
+		}
+		if m, ok := activationMethods[p.Activation]; ok {
+			fmt.Fprintf(o, ` activationMethod="%s"`, m)
+		}
+		fmt.Fprintf(o, ">%s</precursorMz>\n", xmlutil.Ftoa(p.Mz))
+	}
+
+	compression := "none"
+	if w.opts.Zlib {
+		compression = "zlib"
+	}
+	fmt.Fprintf(o, `   <peaks compressionType="%s" compressedLen="%d" precision="%d" byteOrder="network" contentType="m/z-int">%s</peaks>`+"\n",
+		compression, n, w.precision(), peaks)
+	fmt.Fprint(o, "  </scan>\n")
+
+	w.lastScan[level] = num
+	return nil
+}
+
+func (w *Writer) precision() int {
+	if w.opts.Float64 {
+		return 64
+	}
+	return 32
+}
+
+//encodePeaks returns the base64 encoded m/z-intensity pairs in network order,
+//and the compressed length if they are compressed
+func (w *Writer) encodePeaks(s ms.Spectrum) (string, int, error) {
+	var raw []byte
+	if w.opts.Float64 {
+		raw = make([]byte, 16*len(s))
+		for i, p := range s {
+			binary.BigEndian.PutUint64(raw[16*i:], math.Float64bits(p.Mz))
+			binary.BigEndian.PutUint64(raw[16*i+8:], math.Float64bits(float64(p.I)))
+		}
+	} else {
+		raw = make([]byte, 8*len(s))
+		for i, p := range s {
+			binary.BigEndian.PutUint32(raw[8*i:], math.Float32bits(float32(p.Mz)))
+			binary.BigEndian.PutUint32(raw[8*i+4:], math.Float32bits(p.I))
+		}
+	}
+	n := 0
+	if w.opts.Zlib {
+		var b bytes.Buffer
+		zw := zlib.NewWriter(&b)
+		if _, err := zw.Write(raw); err != nil {
+			return "", 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return "", 0, err
+		}
+		raw = b.Bytes()
+		n = len(raw)
+	}
+	return base64.StdEncoding.EncodeToString(raw), n, nil
+}
+
+//Close writes the scan index and the file checksum and flushes the output.
+//It does not close the underlying writer.
+func (w *Writer) Close() error {
+	o := w.out
+	fmt.Fprint(o, " </msRun>\n")
+	indexOffset := o.N + 1
+	fmt.Fprint(o, ` <index name="scan">`+"\n")
+	for _, off := range w.offsets {
+		fmt.Fprintf(o, `  <offset id="%d">%d</offset>`+"\n", off.num, off.pos)
+	}
+	fmt.Fprint(o, " </index>\n")
+	fmt.Fprintf(o, " <indexOffset>%d</indexOffset>\n", indexOffset)
+	fmt.Fprint(o, " <sha1>")
+	fmt.Fprintf(o, "%s</sha1>\n</mzXML>\n", o.SHA1())
+	if err := o.Flush(); err != nil {
+		return err
+	}
+	if len(w.offsets) != w.nScans {
+		return fmt.Errorf("mzxml: %d scans written, %d announced", len(w.offsets), w.nScans)
+	}
+	return nil
+}
+
+//activationMethods are the mzXML names of the fragmentation methods, the
+//schema has none for the others, such as EThcD and UVPD
+var activationMethods = map[ms.Activation]string{
+	ms.CID: "CID",
+	ms.HCD: "HCD",
+	ms.ETD: "ETD",
+	ms.ECD: "ECD",
+}
+
+func acquisitionSoftware(meta ms.Metadata) string {
+	if meta.FileFormat == "Thermo RAW" {
+		return "Xcalibur"
+	}
+	return "unknown"
+}
+
+//duration formats minutes as an xs:duration in seconds
+func duration(minutes float64) string {
+	return "PT" + strconv.FormatFloat(minutes*60, 'f', -1, 64) + "S"
+}
+
+func boolAttr(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
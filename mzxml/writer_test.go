@@ -0,0 +1,164 @@
+package mzxml
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/danhitchcock/ms"
+)
+
+//testScans returns an MS1 scan and two MS2 scans of it
+func testScans() []ms.Scan {
+	ms1 := ms.Scan{Number: 1, MSLevel: 1, Time: 0.5, Polarity: ms.Positive, Analyzer: ms.FTMS, Centroided: true,
+		LowMz: 100, HighMz: 2000, Filter: "FTMS + c NSI Full ms [100.0000-2000.0000]",
+		Spectrum: ms.Spectrum{{Mz: 100.123456789, I: 1.5}, {Mz: 500.987654321, I: 12345.678}, {Mz: 1999.000001, I: 3e6}}}
+	ms1.TotalCurrent = 1.5 + 12345.678 + 3e6
+	ms1.BasePeak = ms1.Spectrum[2]
+	cid := ms.Scan{Number: 2, MSLevel: 2, Time: 0.51, Polarity: ms.Positive, Analyzer: ms.ITMS, Centroided: true,
+		LowMz: 130, HighMz: 1000, TotalCurrent: 300, BasePeak: ms.Peak{Mz: 400.25, I: 200},
+		Precursors:   []ms.Precursor{{Mz: 500.987654321, Charge: 2, Intensity: 12345.678, Activation: ms.CID, Energy: 35}},
+		PrecursorMzs: []float64{500.987654321},
+		Spectrum:     ms.Spectrum{{Mz: 200.5, I: 100}, {Mz: 400.25, I: 200}}}
+	hcd := ms.Scan{Number: 4, MSLevel: 2, Time: 0.52, Polarity: ms.Negative, Analyzer: ms.FTMS,
+		Precursors:   []ms.Precursor{{Mz: 1999.000001, Charge: -3, Activation: ms.HCD, Energy: 28}},
+		PrecursorMzs: []float64{1999.000001}}
+	return []ms.Scan{ms1, cid, hcd}
+}
+
+//writeTemp writes the scans with the options to a file in the test directory
+func writeTemp(t *testing.T, scans []ms.Scan, opts Options) string {
+	fn := filepath.Join(t.TempDir(), "test.mzXML")
+	f, err := os.Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w, err := NewWriter(f, ms.Metadata{InstrumentModel: "test"}, scans, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range scans {
+		if err := w.WriteSpectrum(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return fn
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		//tol is the relative error of the encoding
+		tol float64
+	}{
+		{"32-bit", Options{}, 1e-7},
+		{"64-bit", Options{Float64: true}, 0},
+		{"32-bit zlib", Options{Zlib: true}, 1e-7},
+		{"64-bit zlib", Options{Float64: true, Zlib: true}, 0},
+	}
+	scans := testScans()
+	for _, tt := range tests {
+		fn := writeTemp(t, scans, tt.opts)
+		file, err := Open(fn)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if file.NScans() != len(scans) {
+			t.Fatalf("%s: %d scans, want %d", tt.name, file.NScans(), len(scans))
+		}
+		for i, want := range scans {
+			got := file.Scan(i + 1)
+			if got.Number != want.Number || got.MSLevel != want.MSLevel || got.Polarity != want.Polarity ||
+				got.Analyzer != want.Analyzer || got.Centroided != want.Centroided || math.Abs(got.Time-want.Time) > 1e-9 ||
+				got.Filter != want.Filter || got.TotalCurrent != want.TotalCurrent || got.BasePeak != want.BasePeak {
+				t.Errorf("%s: scan %d is %+v, want %+v", tt.name, i+1, got, want)
+			}
+			if want.HighMz > 0 && (got.LowMz != want.LowMz || got.HighMz != want.HighMz) {
+				t.Errorf("%s: scan %d window is %v-%v, want %v-%v", tt.name, i+1, got.LowMz, got.HighMz, want.LowMz, want.HighMz)
+			}
+			if len(got.Precursors) != len(want.Precursors) {
+				t.Errorf("%s: scan %d has precursors %+v, want %+v", tt.name, i+1, got.Precursors, want.Precursors)
+			} else {
+				for k := range want.Precursors {
+					if got.Precursors[k] != want.Precursors[k] || got.PrecursorMzs[k] != want.PrecursorMzs[k] {
+						t.Errorf("%s: scan %d precursor is %+v, want %+v", tt.name, i+1, got.Precursors[k], want.Precursors[k])
+					}
+				}
+			}
+			if len(got.Spectrum) != len(want.Spectrum) {
+				t.Errorf("%s: scan %d has %d peaks, want %d", tt.name, i+1, len(got.Spectrum), len(want.Spectrum))
+				continue
+			}
+			for k, p := range want.Spectrum {
+				q := got.Spectrum[k]
+				if math.Abs(q.Mz-p.Mz) > tt.tol*p.Mz || q.I != p.I {
+					t.Errorf("%s: scan %d peak %d is %v, want %v", tt.name, i+1, k, q, p)
+				}
+			}
+		}
+		meta := file.Metadata()
+		if meta.LowMz != 100 || meta.HighMz != 2000 {
+			t.Errorf("%s: m/z range is %v-%v, want 100-2000", tt.name, meta.LowMz, meta.HighMz)
+		}
+		if h := file.header(1); h.Number != 1 || len(h.Spectrum) != 0 {
+			t.Errorf("%s: header is scan %d with %d peaks", tt.name, h.Number, len(h.Spectrum))
+		}
+		file.Close()
+	}
+}
+
+func TestIndex(t *testing.T) {
+	scans := testScans()
+	fn := writeTemp(t, scans, Options{Zlib: true})
+	file, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.offsets = nil
+	if !file.readIndex() {
+		t.Fatal("the index is not usable")
+	}
+	indexed := file.offsets
+	if err := file.buildIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if len(indexed) != len(file.offsets) {
+		t.Fatalf("%d offsets in the index, %d scans", len(indexed), len(file.offsets))
+	}
+	for i := range indexed {
+		if indexed[i] != file.offsets[i] {
+			t.Errorf("scan %d is at %d, the index has %d", i+1, file.offsets[i], indexed[i])
+		}
+	}
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the index references the scans by number
+	for i, m := range regexp.MustCompile(`<offset id="(\d+)">(\d+)</offset>`).FindAllSubmatch(b, -1) {
+		pos, _ := strconv.Atoi(string(m[2]))
+		if !bytes.HasPrefix(b[pos:], []byte(`<scan num="`+string(m[1])+`"`)) || int64(pos) != indexed[i] {
+			t.Errorf("offset %s does not point at the scan %s", m[2], m[1])
+		}
+	}
+	//the checksum covers the file up to and including <sha1>
+	tag := []byte("<sha1>")
+	i := bytes.Index(b, tag) + len(tag)
+	sum := sha1.Sum(b[:i])
+	if got := string(b[i : i+40]); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("sha1 is %s, want %s", got, hex.EncodeToString(sum[:]))
+	}
+}
@@ -0,0 +1,127 @@
+/*Package ndjson exports scans as JSON objects, one per line (newline
+  delimited JSON), for web applications and scripts such as jq.
+
+  A scan looks like (on one line)
+      {"scan":2,"rt":0.2,"ms_level":2,"analyzer":"ITMS","polarity":"+","centroided":true,
+       "precursors":[{"mz":445.12,"charge":2,"activation":"CID","activation_energy":35}],
+       "tic":1200,"base_peak_mz":300.1,"base_peak_intensity":500,"low_mz":120,"high_mz":2000,
+       "peak_count":2,"filter":"ITMS + c d Full ms2 445.1200@cid35.00 [120.00-2000.00]",
+       "peaks":{"mz":[300.1,400.2],"intensity":[500,100]}}
+  The field names are the column names of the parquet and sqlite exports.
+  Retention times are in minutes, unknown precursor values are left out.
+*/
+package ndjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/danhitchcock/ms"
+)
+
+//Scan is the JSON object of a scan
+type Scan struct {
+	//File is the name of the file, it is only set by some tools
+	File              string      `json:"file,omitempty"`
+	Scan              int         `json:"scan"`
+	RT                float64     `json:"rt"`
+	MSLevel           uint8       `json:"ms_level"`
+	Analyzer          string      `json:"analyzer"`
+	Polarity          string      `json:"polarity"`
+	Centroided        bool        `json:"centroided"`
+	Precursors        []Precursor `json:"precursors,omitempty"`
+	TIC               float64     `json:"tic"`
+	BasePeakMz        float64     `json:"base_peak_mz"`
+	BasePeakIntensity float32     `json:"base_peak_intensity"`
+	LowMz             float64     `json:"low_mz"`
+	HighMz            float64     `json:"high_mz"`
+	PeakCount         int         `json:"peak_count"`
+	Filter            string      `json:"filter,omitempty"`
+	Peaks             *Peaks      `json:"peaks,omitempty"`
+}
+
+//Precursor is the JSON object of a precursor
+type Precursor struct {
+	Mz               float64 `json:"mz"`
+	Charge           int     `json:"charge,omitempty"`
+	Intensity        float32 `json:"intensity,omitempty"`
+	Activation       string  `json:"activation,omitempty"`
+	ActivationEnergy float64 `json:"activation_energy,omitempty"`
+}
+
+//Peaks holds the peaks of a spectrum as two arrays, which is more compact
+//than an array of pairs and plots directly
+type Peaks struct {
+	Mz        []float64 `json:"mz"`
+	Intensity []float32 `json:"intensity"`
+}
+
+//FromScan returns the JSON object of the scan, with its peaks if peaks is true
+func FromScan(scan ms.Scan, peaks bool) Scan {
+	s := Scan{
+		Scan:              scan.Number,
+		RT:                scan.Time,
+		MSLevel:           scan.MSLevel,
+		Analyzer:          scan.Analyzer.String(),
+		Polarity:          scan.Polarity.String(),
+		Centroided:        scan.Centroided,
+		TIC:               scan.TotalCurrent,
+		BasePeakMz:        scan.BasePeak.Mz,
+		BasePeakIntensity: scan.BasePeak.I,
+		LowMz:             scan.LowMz,
+		HighMz:            scan.HighMz,
+		PeakCount:         len(scan.Spectrum),
+		Filter:            scan.Filter,
+	}
+	for i, mz := range scan.PrecursorMzs {
+		p := Precursor{Mz: mz}
+		if i < len(scan.Precursors) {
+			sp := scan.Precursors[i]
+			p = Precursor{Mz: sp.Mz, Charge: sp.Charge, Intensity: sp.Intensity, ActivationEnergy: sp.Energy}
+			if sp.Activation != ms.UnknownActivation {
+				p.Activation = sp.Activation.String()
+			}
+		}
+		s.Precursors = append(s.Precursors, p)
+	}
+	if peaks {
+		s.Peaks = FromSpectrum(scan.Spectrum)
+	}
+	return s
+}
+
+//FromSpectrum returns the peaks of the spectrum
+func FromSpectrum(spectrum ms.Spectrum) *Peaks {
+	p := &Peaks{make([]float64, len(spectrum)), make([]float32, len(spectrum))}
+	for i, peak := range spectrum {
+		p.Mz[i], p.Intensity[i] = peak.Mz, peak.I
+	}
+	return p
+}
+
+//Options selects what is exported
+type Options struct {
+	Filter ms.ScanFilter
+	//Peaks includes the peaks of every scan
+	Peaks bool
+}
+
+//Export writes a line with the JSON object of every scan passing the filter
+func Export(w io.Writer, file ms.Reader, opts Options) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i := 1; i <= file.NScans(); i++ {
+		scan := file.Scan(i)
+		if !opts.Filter.Matches(scan) {
+			continue
+		}
+		if scan.Number == 0 {
+			scan.Number = i
+		}
+		if err := enc.Encode(FromScan(scan, opts.Peaks)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,282 @@
+//Package numpress implements the MS-Numpress compression codecs for
+//mass spectrometry data arrays. The output is byte compatible with the
+//reference implementation (https://github.com/ms-numpress/ms-numpress).
+//
+//Linear prediction (for m/z and retention time) stores the values as
+//fixed point integers and encodes the difference to a linear extrapolation
+//of the previous two, the absolute error is at most 0.5/fixedPoint.
+//Positive integer compression (for ion counts) rounds to the nearest
+//integer, the absolute error is at most 0.5.
+//Short logged float (for intensities) stores log(x+1) as a 16-bit fixed
+//point, the relative error of x+1 is at most exp(0.5/fixedPoint)-1.
+package numpress
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+//ErrOverflow is returned if a value does not fit the encoding
+var ErrOverflow = errors.New("numpress: value out of range")
+
+//ErrCorrupt is returned if encoded data cannot be decoded
+var ErrCorrupt = errors.New("numpress: corrupt input data")
+
+//encodeFixedPoint stores the fixed point as a big endian double
+func encodeFixedPoint(fixedPoint float64, b []byte) {
+	binary.BigEndian.PutUint64(b, math.Float64bits(fixedPoint))
+}
+
+func decodeFixedPoint(b []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+//encodeInt appends x to the half bytes: a count of leading zero (0-8) or
+//leading 0xf (9-15) half bytes, followed by the remaining half bytes least
+//significant first
+func encodeInt(x uint32, halfBytes []byte) []byte {
+	const mask = 0xf0000000
+	var l uint
+	switch x & mask {
+	case 0:
+		l = 8
+		for i := uint(0); i < 8; i++ {
+			if x&(mask>>(4*i)) != 0 {
+				l = i
+				break
+			}
+		}
+		halfBytes = append(halfBytes, byte(l))
+	case mask:
+		l = 7
+		for i := uint(0); i < 8; i++ {
+			m := uint32(mask) >> (4 * i)
+			if x&m != m {
+				l = i
+				break
+			}
+		}
+		halfBytes = append(halfBytes, byte(l+8))
+	default:
+		halfBytes = append(halfBytes, 0)
+	}
+	for i := l; i < 8; i++ {
+		halfBytes = append(halfBytes, byte(x>>(4*(i-l)))&0xf)
+	}
+	return halfBytes
+}
+
+//halfByteWriter packs half bytes, high half first
+type halfByteWriter struct {
+	b    []byte
+	half bool
+}
+
+func (w *halfByteWriter) write(halfBytes []byte) {
+	for _, hb := range halfBytes {
+		if w.half {
+			w.b[len(w.b)-1] |= hb
+		} else {
+			w.b = append(w.b, hb<<4)
+		}
+		w.half = !w.half
+	}
+}
+
+//halfByteReader unpacks the half bytes written by halfByteWriter
+type halfByteReader struct {
+	b    []byte
+	i    int
+	half bool
+}
+
+//done reports whether only the padding of an odd number of half bytes is left
+func (r *halfByteReader) done() bool {
+	if r.i >= len(r.b) {
+		return true
+	}
+	return r.i == len(r.b)-1 && r.half && r.b[r.i]&0xf == 0
+}
+
+func (r *halfByteReader) next() (byte, error) {
+	if r.i >= len(r.b) {
+		return 0, ErrCorrupt
+	}
+	var hb byte
+	if r.half {
+		hb = r.b[r.i] & 0xf
+		r.i++
+	} else {
+		hb = r.b[r.i] >> 4
+	}
+	r.half = !r.half
+	return hb, nil
+}
+
+//decodeInt reads an integer written by encodeInt
+func (r *halfByteReader) decodeInt() (uint32, error) {
+	head, err := r.next()
+	if err != nil {
+		return 0, err
+	}
+	var x uint32
+	n := uint(head)
+	if head > 8 {
+		n = uint(head) - 8
+		for i := uint(0); i < n; i++ {
+			x |= 0xf0000000 >> (4 * i)
+		}
+	}
+	for i := n; i < 8; i++ {
+		hb, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		x |= uint32(hb) << (4 * (i - n))
+	}
+	return x, nil
+}
+
+//OptimalLinearFixedPoint returns the largest fixed point for which
+//EncodeLinear does not overflow on the data
+func OptimalLinearFixedPoint(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	if len(data) == 1 {
+		return math.Floor(math.MaxInt32 / data[0])
+	}
+	max := math.Max(data[0], data[1])
+	for i := 2; i < len(data); i++ {
+		extrapol := data[i-1] + (data[i-1] - data[i-2])
+		max = math.Max(max, math.Ceil(math.Abs(data[i]-extrapol)+1))
+	}
+	return math.Floor(math.MaxInt32 / max)
+}
+
+//EncodeLinear encodes the data with linear prediction
+func EncodeLinear(data []float64, fixedPoint float64) ([]byte, error) {
+	w := halfByteWriter{b: make([]byte, 8, 8+4*len(data))}
+	encodeFixedPoint(fixedPoint, w.b)
+	var ints [3]int64
+	var halfBytes []byte
+	for i, v := range data {
+		f := v*fixedPoint + 0.5
+		if f > math.MaxInt64 || f < math.MinInt64 {
+			return nil, ErrOverflow
+		}
+		ints[0], ints[1], ints[2] = ints[1], ints[2], int64(f)
+		if i < 2 {
+			w.b = binary.LittleEndian.AppendUint32(w.b, uint32(ints[2]))
+			continue
+		}
+		diff := ints[2] - (ints[1] + (ints[1] - ints[0]))
+		if diff > math.MaxInt32 || diff < math.MinInt32 {
+			return nil, ErrOverflow
+		}
+		halfBytes = encodeInt(uint32(int32(diff)), halfBytes[:0])
+		w.write(halfBytes)
+	}
+	return w.b, nil
+}
+
+//DecodeLinear decodes data encoded by EncodeLinear
+func DecodeLinear(b []byte) ([]float64, error) {
+	if len(b) < 8 || len(b) > 8 && len(b) < 12 || len(b) > 12 && len(b) < 16 {
+		return nil, ErrCorrupt
+	}
+	fixedPoint := decodeFixedPoint(b)
+	var data []float64
+	var ints [3]int64
+	for i := 8; i < len(b) && i < 16; i += 4 {
+		ints[1], ints[2] = ints[2], int64(binary.LittleEndian.Uint32(b[i:]))
+		data = append(data, float64(ints[2])/fixedPoint)
+	}
+	if len(b) <= 16 {
+		return data, nil
+	}
+	r := halfByteReader{b: b[16:]}
+	for !r.done() {
+		x, err := r.decodeInt()
+		if err != nil {
+			return nil, err
+		}
+		ints[0], ints[1] = ints[1], ints[2]
+		ints[2] = ints[1] + (ints[1] - ints[0]) + int64(int32(x))
+		data = append(data, float64(ints[2])/fixedPoint)
+	}
+	return data, nil
+}
+
+//EncodePic encodes the data as positive integers, rounding the values
+func EncodePic(data []float64) ([]byte, error) {
+	w := halfByteWriter{b: make([]byte, 0, 2*len(data))}
+	var halfBytes []byte
+	for _, v := range data {
+		if v+0.5 > math.MaxInt32 || v < -0.5 {
+			return nil, ErrOverflow
+		}
+		halfBytes = encodeInt(uint32(v+0.5), halfBytes[:0])
+		w.write(halfBytes)
+	}
+	return w.b, nil
+}
+
+//DecodePic decodes data encoded by EncodePic
+func DecodePic(b []byte) ([]float64, error) {
+	var data []float64
+	r := halfByteReader{b: b}
+	for !r.done() {
+		x, err := r.decodeInt()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, float64(x))
+	}
+	return data, nil
+}
+
+//OptimalSlofFixedPoint returns the largest fixed point for which
+//EncodeSlof does not overflow on the data
+func OptimalSlofFixedPoint(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	max := 1.0
+	for _, v := range data {
+		max = math.Max(max, math.Log(v+1))
+	}
+	return math.Floor(math.MaxUint16 / max)
+}
+
+//EncodeSlof encodes the data as short logged floats
+func EncodeSlof(data []float64, fixedPoint float64) ([]byte, error) {
+	b := make([]byte, 8, 8+2*len(data))
+	encodeFixedPoint(fixedPoint, b)
+	for _, v := range data {
+		f := math.Log(v+1) * fixedPoint
+		if f > math.MaxUint16 || !(f >= -0.5) {
+			return nil, ErrOverflow
+		}
+		if f < 0 {
+			f = 0
+		}
+		b = binary.LittleEndian.AppendUint16(b, uint16(f+0.5))
+	}
+	return b, nil
+}
+
+//DecodeSlof decodes data encoded by EncodeSlof
+func DecodeSlof(b []byte) ([]float64, error) {
+	if len(b) < 8 || len(b)%2 != 0 {
+		return nil, ErrCorrupt
+	}
+	fixedPoint := decodeFixedPoint(b)
+	data := make([]float64, 0, (len(b)-8)/2)
+	for i := 8; i < len(b); i += 2 {
+		x := binary.LittleEndian.Uint16(b[i:])
+		data = append(data, math.Exp(float64(x)/fixedPoint)-1)
+	}
+	return data, nil
+}
@@ -0,0 +1,111 @@
+package numpress
+
+import (
+	"math"
+	"testing"
+)
+
+//ramp returns n values from start that are step apart, with a wobble so
+//that the linear prediction is not exact
+func ramp(n int, start float64, step float64) []float64 {
+	v := make([]float64, n)
+	for i := range v {
+		v[i] = start + float64(i)*step + 0.37*step*math.Sin(float64(i))
+	}
+	return v
+}
+
+var roundTrips = []struct {
+	name string
+	data []float64
+}{
+	{"empty", nil},
+	{"single", []float64{445.12003}},
+	{"two", []float64{445.12003, 445.12471}},
+	{"mz", ramp(1000, 200, 0.0137)},
+	{"large", ramp(100, 1e8, 12345.678)},
+}
+
+func TestLinear(t *testing.T) {
+	for _, tt := range roundTrips {
+		fixedPoint := OptimalLinearFixedPoint(tt.data)
+		b, err := EncodeLinear(tt.data, fixedPoint)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := DecodeLinear(b)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != len(tt.data) {
+			t.Fatalf("%s: decoded %d values, want %d", tt.name, len(got), len(tt.data))
+		}
+		max := 0.5 / fixedPoint
+		for i, v := range tt.data {
+			if d := math.Abs(got[i] - v); d > max*(1+1e-9) {
+				t.Errorf("%s: value %d is %v, want %v within %v", tt.name, i, got[i], v, max)
+			}
+		}
+	}
+}
+
+func TestPic(t *testing.T) {
+	data := [][]float64{nil, {7.4}, {0, 0.49, 0.5, 1, 12.6, 255.5, 4096.2, 1e6 + 0.3, math.MaxInt32 - 1}}
+	for _, d := range data {
+		b, err := EncodePic(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodePic(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(d) {
+			t.Fatalf("decoded %d values, want %d", len(got), len(d))
+		}
+		for i, v := range d {
+			if math.Abs(got[i]-v) > 0.5 {
+				t.Errorf("value %d is %v, want %v within 0.5", i, got[i], v)
+			}
+		}
+	}
+	if _, err := EncodePic([]float64{-1}); err != ErrOverflow {
+		t.Errorf("negative value: error %v, want ErrOverflow", err)
+	}
+}
+
+func TestSlof(t *testing.T) {
+	intensities := []float64{0, 0.3, 1, 17, 1234.5, 5.5e5, 3.2e7}
+	for _, d := range [][]float64{nil, {1e4}, intensities, ramp(100, 1e10, 1e9)} {
+		fixedPoint := OptimalSlofFixedPoint(d)
+		b, err := EncodeSlof(d, fixedPoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeSlof(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(d) {
+			t.Fatalf("decoded %d values, want %d", len(got), len(d))
+		}
+		//the error is relative to x+1
+		max := math.Exp(0.5/fixedPoint) - 1
+		for i, v := range d {
+			if r := math.Abs(got[i]+1-(v+1)) / (v + 1); r > max*(1+1e-9) {
+				t.Errorf("value %d is %v, want %v within a relative error of %v", i, got[i], v, max)
+			}
+		}
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	for _, b := range [][]byte{nil, make([]byte, 10), make([]byte, 13)} {
+		if _, err := DecodeLinear(b); err != ErrCorrupt {
+			t.Errorf("DecodeLinear of %d bytes: error %v, want ErrCorrupt", len(b), err)
+		}
+	}
+	if _, err := DecodeSlof(make([]byte, 9)); err != ErrCorrupt {
+		t.Errorf("DecodeSlof of 9 bytes: error %v, want ErrCorrupt", err)
+	}
+}
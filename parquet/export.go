@@ -0,0 +1,119 @@
+package parquet
+
+import (
+	"io"
+
+	"github.com/danhitchcock/ms"
+)
+
+//ScanColumns are the columns of the scans table. The precursor is the last
+//precursor of MSn scans, its columns are null in MS1 scans and the charge and
+//intensity are null if they are unknown. Retention times are in minutes.
+var ScanColumns = []Column{
+	{"scan", Int32, false},
+	{"rt", Double, false},
+	{"ms_level", Int32, false},
+	{"analyzer", String, false},
+	{"polarity", String, false},
+	{"centroided", Boolean, false},
+	{"precursor_mz", Double, true},
+	{"precursor_charge", Int32, true},
+	{"precursor_intensity", Float, true},
+	{"activation", String, true},
+	{"activation_energy", Double, true},
+	{"tic", Double, false},
+	{"base_peak_mz", Double, false},
+	{"base_peak_intensity", Float, false},
+	{"low_mz", Double, false},
+	{"high_mz", Double, false},
+	{"peaks", Int32, false},
+	{"filter", String, false},
+}
+
+//PeakColumns are the columns of the long-format peaks table, one row per peak
+var PeakColumns = []Column{
+	{"scan", Int32, false},
+	{"mz", Double, false},
+	{"intensity", Float, false},
+}
+
+//Export writes the scans table of the scans passing the filter to scans, and
+//their peaks table to peaks if it is not nil. The file is read once.
+func Export(scans io.Writer, peaks io.Writer, file ms.Reader, filter ms.ScanFilter, opts Options) error {
+	sw, err := NewWriter(scans, ScanColumns, opts)
+	if err != nil {
+		return err
+	}
+	var pw *Writer
+	if peaks != nil {
+		if pw, err = NewWriter(peaks, PeakColumns, opts); err != nil {
+			return err
+		}
+	}
+	for i := 1; i <= file.NScans(); i++ {
+		scan := file.Scan(i)
+		if !filter.Matches(scan) {
+			continue
+		}
+		if scan.Number == 0 {
+			scan.Number = i
+		}
+		if err := sw.Write(scanRow(scan)...); err != nil {
+			return err
+		}
+		if pw == nil {
+			continue
+		}
+		sn := int32(scan.Number)
+		for _, p := range scan.Spectrum {
+			if err := pw.Write(sn, p.Mz, p.I); err != nil {
+				return err
+			}
+		}
+	}
+	if pw != nil {
+		if err := pw.Close(); err != nil {
+			return err
+		}
+	}
+	return sw.Close()
+}
+
+//scanRow returns the row of the scan in the scans table
+func scanRow(scan ms.Scan) []interface{} {
+	var mz, charge, intensity, activation, energy interface{}
+	if n := len(scan.Precursors); n > 0 {
+		p := scan.Precursors[n-1]
+		mz = p.Mz
+		if p.Charge != 0 {
+			charge = int32(p.Charge)
+		}
+		if p.Intensity != 0 {
+			intensity = p.Intensity
+		}
+		if p.Activation != ms.UnknownActivation {
+			activation = p.Activation.String()
+		}
+		if p.Energy != 0 {
+			energy = p.Energy
+		}
+	} else if n := len(scan.PrecursorMzs); n > 0 {
+		mz = scan.PrecursorMzs[n-1]
+	}
+	return []interface{}{
+		int32(scan.Number),
+		scan.Time,
+		int32(scan.MSLevel),
+		scan.Analyzer.String(),
+		scan.Polarity.String(),
+		scan.Centroided,
+		mz, charge, intensity, activation, energy,
+		scan.TotalCurrent,
+		scan.BasePeak.Mz,
+		scan.BasePeak.I,
+		scan.LowMz,
+		scan.HighMz,
+		int32(len(scan.Spectrum)),
+		scan.Filter,
+	}
+}
@@ -0,0 +1,109 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+//Thrift compact protocol type ids
+const (
+	tBoolTrue  = 1
+	tBoolFalse = 2
+	tI32       = 5
+	tI64       = 6
+	tBinary    = 8
+	tList      = 9
+	tStruct    = 12
+)
+
+//thrift encodes structs in the Thrift compact protocol, which Parquet uses
+//for its page headers and file footer. Fields are written in increasing id order.
+type thrift struct {
+	buf []byte
+	//last holds the last field id of the enclosing structs
+	last []int16
+}
+
+func (t *thrift) varint(v uint64) {
+	t.buf = binary.AppendUvarint(t.buf, v)
+}
+
+func (t *thrift) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		t.buf = append(t.buf, byte(d)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(uint64(uint16(id<<1 ^ id>>15)))
+	}
+	*last = id
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, tI32)
+	t.varint(uint64(uint32(v<<1 ^ v>>31)))
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, tI64)
+	t.varint(uint64(v<<1 ^ v>>63))
+}
+
+func (t *thrift) bool(id int16, v bool) {
+	if v {
+		t.field(id, tBoolTrue)
+	} else {
+		t.field(id, tBoolFalse)
+	}
+}
+
+func (t *thrift) binary(id int16, v []byte) {
+	t.field(id, tBinary)
+	t.varint(uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
+
+func (t *thrift) string(id int16, v string) {
+	t.binary(id, []byte(v))
+}
+
+//list writes the header of a list field of n elements of type typ
+func (t *thrift) list(id int16, typ byte, n int) {
+	t.field(id, tList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|typ)
+	} else {
+		t.buf = append(t.buf, 0xf0|typ)
+		t.varint(uint64(n))
+	}
+}
+
+//i32List writes a list of i32 (or enum) values
+func (t *thrift) i32List(id int16, v []int32) {
+	t.list(id, tI32, len(v))
+	for _, x := range v {
+		t.varint(uint64(uint32(x<<1 ^ x>>31)))
+	}
+}
+
+//begin starts a struct, as a field if id is not 0 or as a list element
+func (t *thrift) begin(id int16) {
+	if id != 0 {
+		t.field(id, tStruct)
+	}
+	t.last = append(t.last, 0)
+}
+
+//end ends a struct
+func (t *thrift) end() {
+	t.buf = append(t.buf, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func float32Bytes(b []byte, v float32) []byte {
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+}
+
+func float64Bytes(b []byte, v float64) []byte {
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+}
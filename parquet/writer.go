@@ -0,0 +1,351 @@
+//Package parquet writes flat tables in the Apache Parquet format, and exports
+//the scans and peaks of a run as such tables.
+//
+//The writer covers what tabular MS data needs: required and optional columns
+//of booleans, 32/64-bit integers and floats and UTF-8 strings, in PLAIN
+//encoded data pages, optionally compressed with Snappy or gzip.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+)
+
+//Type is the type of a column
+type Type int
+
+//The column types, String is a UTF-8 BYTE_ARRAY
+const (
+	Boolean Type = iota
+	Int32
+	Int64
+	Float
+	Double
+	String
+)
+
+var typeNames = [...]string{"BOOLEAN", "INT32", "INT64", "FLOAT", "DOUBLE", "STRING"}
+
+func (t Type) String() string {
+	return typeNames[t]
+}
+
+//physical are the Parquet physical types of the column types
+var physical = [...]int32{Boolean: 0, Int32: 1, Int64: 2, Float: 4, Double: 5, String: 6}
+
+//Column describes a column of a table
+type Column struct {
+	Name string
+	Type Type
+	//Optional columns may hold nulls
+	Optional bool
+}
+
+//Compression is the compression codec of the data pages
+type Compression int
+
+//The compression codecs, numbered as in the format
+const (
+	Uncompressed Compression = iota
+	Snappy
+	Gzip
+)
+
+//Options controls the layout of the file
+type Options struct {
+	//RowGroupSize is the number of rows per row group, 1<<20 if it is 0
+	RowGroupSize int
+	Compression  Compression
+}
+
+//Writer writes a table row by row. Rows are buffered per column and written
+//as a row group when RowGroupSize rows are buffered, Close writes the footer.
+type Writer struct {
+	w       io.Writer
+	pos     int64
+	columns []Column
+	opts    Options
+	//values and defined are the buffered PLAIN encoded values and definition levels
+	values  [][]byte
+	defined [][]bool
+	//bools are the buffered values of boolean columns, which are bit-packed
+	bools     [][]bool
+	rows      int
+	totalRows int64
+	groups    []rowGroup
+	err       error
+}
+
+//rowGroup is the footer entry of a written row group
+type rowGroup struct {
+	rows    int
+	size    int64
+	columns []chunk
+}
+
+//chunk is the footer entry of a written column chunk
+type chunk struct {
+	offset           int64
+	values           int
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+var magic = []byte("PAR1")
+
+//NewWriter writes the file magic to w and returns a Writer for a table with the columns
+func NewWriter(w io.Writer, columns []Column, opts Options) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("parquet: no columns")
+	}
+	if opts.RowGroupSize <= 0 {
+		opts.RowGroupSize = 1 << 20
+	}
+	wr := &Writer{
+		w:       w,
+		columns: columns,
+		opts:    opts,
+		values:  make([][]byte, len(columns)),
+		defined: make([][]bool, len(columns)),
+		bools:   make([][]bool, len(columns)),
+	}
+	wr.write(magic)
+	return wr, wr.err
+}
+
+//Write appends a row, with a value per column: bool, int32, int64, float32,
+//float64 or string according to the column Type, or nil for a null in an
+//optional column. A row of the wrong types breaks the Writer: the error is
+//returned by later calls as well.
+func (w *Writer) Write(row ...interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("parquet: %d values for %d columns", len(row), len(w.columns))
+	}
+	for i, v := range row {
+		c := w.columns[i]
+		if v == nil {
+			if !c.Optional {
+				w.err = fmt.Errorf("parquet: null in required column %s", c.Name)
+				return w.err
+			}
+		} else if !w.appendValue(i, v) {
+			w.err = fmt.Errorf("parquet: %T value in %v column %s", v, c.Type, c.Name)
+			return w.err
+		}
+		if c.Optional {
+			w.defined[i] = append(w.defined[i], v != nil)
+		}
+	}
+	w.rows++
+	if w.rows == w.opts.RowGroupSize {
+		w.flush()
+	}
+	return w.err
+}
+
+//appendValue appends the PLAIN encoding of v, it reports false for a value of the wrong type
+func (w *Writer) appendValue(i int, v interface{}) bool {
+	b := w.values[i]
+	switch w.columns[i].Type {
+	case Boolean:
+		x, ok := v.(bool)
+		w.bools[i] = append(w.bools[i], x)
+		return ok
+	case Int32:
+		x, ok := v.(int32)
+		w.values[i] = binary.LittleEndian.AppendUint32(b, uint32(x))
+		return ok
+	case Int64:
+		x, ok := v.(int64)
+		w.values[i] = binary.LittleEndian.AppendUint64(b, uint64(x))
+		return ok
+	case Float:
+		x, ok := v.(float32)
+		w.values[i] = float32Bytes(b, x)
+		return ok
+	case Double:
+		x, ok := v.(float64)
+		w.values[i] = float64Bytes(b, x)
+		return ok
+	case String:
+		x, ok := v.(string)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(x)))
+		w.values[i] = append(b, x...)
+		return ok
+	}
+	return false
+}
+
+func (w *Writer) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	var n int
+	n, w.err = w.w.Write(p)
+	w.pos += int64(n)
+}
+
+//flush writes the buffered rows as a row group
+func (w *Writer) flush() {
+	if w.rows == 0 || w.err != nil {
+		return
+	}
+	g := rowGroup{rows: w.rows}
+	for i, c := range w.columns {
+		var page []byte
+		if c.Optional {
+			page = appendLevels(page, w.defined[i])
+		}
+		if c.Type == Boolean {
+			page = appendBits(page, w.bools[i])
+		} else {
+			page = append(page, w.values[i]...)
+		}
+		body, err := w.compress(page)
+		if err != nil {
+			w.err = err
+			return
+		}
+
+		var h thrift
+		h.begin(0)
+		h.i32(1, 0) //DATA_PAGE
+		h.i32(2, int32(len(page)))
+		h.i32(3, int32(len(body)))
+		h.begin(5)
+		h.i32(1, int32(w.rows))
+		h.i32(2, 0) //PLAIN
+		h.i32(3, 3) //RLE
+		h.i32(4, 3)
+		h.end()
+		h.end()
+
+		ch := chunk{
+			offset:           w.pos,
+			values:           w.rows,
+			uncompressedSize: int64(len(h.buf) + len(page)),
+			compressedSize:   int64(len(h.buf) + len(body)),
+		}
+		w.write(h.buf)
+		w.write(body)
+		g.size += ch.uncompressedSize
+		g.columns = append(g.columns, ch)
+
+		w.values[i] = w.values[i][:0]
+		w.defined[i] = w.defined[i][:0]
+		w.bools[i] = w.bools[i][:0]
+	}
+	w.groups = append(w.groups, g)
+	w.totalRows += int64(w.rows)
+	w.rows = 0
+}
+
+func (w *Writer) compress(page []byte) ([]byte, error) {
+	switch w.opts.Compression {
+	case Snappy:
+		return snappy.Encode(nil, page), nil
+	case Gzip:
+		var b bytes.Buffer
+		z := gzip.NewWriter(&b)
+		z.Write(page)
+		err := z.Close()
+		return b.Bytes(), err
+	}
+	return page, nil
+}
+
+//appendLevels appends definition levels of bit width 1, in a single
+//bit-packed run of the RLE/bit-packing hybrid, preceded by its length
+func appendLevels(b []byte, defined []bool) []byte {
+	run := binary.AppendUvarint(nil, uint64((len(defined)+7)/8)<<1|1)
+	run = appendBits(run, defined)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(run)))
+	return append(b, run...)
+}
+
+//appendBits appends the booleans bit-packed, least significant bit first
+func appendBits(b []byte, v []bool) []byte {
+	for i := 0; i < len(v); i += 8 {
+		var x byte
+		for j := 0; j < 8 && i+j < len(v); j++ {
+			if v[i+j] {
+				x |= 1 << j
+			}
+		}
+		b = append(b, x)
+	}
+	return b
+}
+
+//Close writes the buffered rows and the footer, it does not close the underlying writer
+func (w *Writer) Close() error {
+	w.flush()
+	if w.err != nil {
+		return w.err
+	}
+	var t thrift
+	t.begin(0)
+	t.i32(1, 1)
+	t.list(2, tStruct, len(w.columns)+1)
+	t.begin(0)
+	t.string(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.end()
+	for _, c := range w.columns {
+		t.begin(0)
+		t.i32(1, physical[c.Type])
+		if c.Optional {
+			t.i32(3, 1)
+		} else {
+			t.i32(3, 0)
+		}
+		t.string(4, c.Name)
+		if c.Type == String {
+			t.i32(6, 0) //UTF8
+		}
+		t.end()
+	}
+	t.i64(3, w.totalRows)
+	t.list(4, tStruct, len(w.groups))
+	for _, g := range w.groups {
+		t.begin(0)
+		t.list(1, tStruct, len(g.columns))
+		for i, ch := range g.columns {
+			c := w.columns[i]
+			t.begin(0)
+			t.i64(2, ch.offset)
+			t.begin(3)
+			t.i32(1, physical[c.Type])
+			t.i32List(2, []int32{0, 3})
+			t.list(3, tBinary, 1)
+			t.varint(uint64(len(c.Name)))
+			t.buf = append(t.buf, c.Name...)
+			t.i32(4, int32(w.opts.Compression))
+			t.i64(5, int64(ch.values))
+			t.i64(6, ch.uncompressedSize)
+			t.i64(7, ch.compressedSize)
+			t.i64(9, ch.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, g.size)
+		t.i64(3, int64(g.rows))
+		t.end()
+	}
+	t.string(6, "github.com/danhitchcock/ms/parquet")
+	t.end()
+
+	w.write(t.buf)
+	w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(t.buf))))
+	w.write(magic)
+	return w.err
+}
@@ -0,0 +1,119 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestWriterGolden(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, []Column{{Name: "a", Type: Int32}, {Name: "s", Type: String, Optional: true}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(int32(1), "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(int32(2), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var want []byte
+	want = append(want, "PAR1"...)
+	//column a at 4: page header of 17 bytes and the PLAIN values
+	want = append(want,
+		0x15, 0x00, //type DATA_PAGE
+		0x15, 0x10, //uncompressed size 8
+		0x15, 0x10, //compressed size 8
+		0x2c,       //data page header
+		0x15, 0x04, //2 values
+		0x15, 0x00, //PLAIN
+		0x15, 0x06, //definition levels RLE
+		0x15, 0x06, //repetition levels RLE
+		0x00, 0x00)
+	want = append(want, 1, 0, 0, 0, 2, 0, 0, 0)
+	//column s at 29: the definition levels are one bit-packed run of 1 byte
+	want = append(want,
+		0x15, 0x00,
+		0x15, 0x16, //uncompressed size 11
+		0x15, 0x16, //compressed size 11
+		0x2c,
+		0x15, 0x04,
+		0x15, 0x00,
+		0x15, 0x06,
+		0x15, 0x06,
+		0x00, 0x00)
+	want = append(want, 2, 0, 0, 0, 0x03, 0x01, 1, 0, 0, 0, 'x')
+
+	//the footer
+	var footer []byte
+	footer = append(footer,
+		0x15, 0x02, //version 1
+		0x19, 0x3c, //schema of 3 elements
+		0x48, 6, 's', 'c', 'h', 'e', 'm', 'a', 0x15, 0x04, 0x00, //root with 2 children
+		0x15, 0x02, 0x25, 0x00, 0x18, 1, 'a', 0x00, //a INT32 REQUIRED
+		0x15, 0x0c, 0x25, 0x02, 0x18, 1, 's', 0x25, 0x00, 0x00, //s BYTE_ARRAY OPTIONAL UTF8
+		0x16, 0x04, //2 rows
+		0x19, 0x1c, //1 row group
+		0x19, 0x2c, //of 2 column chunks
+		0x26, 0x08, //file offset 4
+		0x1c,
+		0x15, 0x02, //INT32
+		0x19, 0x25, 0x00, 0x06, //encodings PLAIN, RLE
+		0x19, 0x18, 1, 'a', //path
+		0x15, 0x00, //UNCOMPRESSED
+		0x16, 0x04, //2 values
+		0x16, 0x32, //uncompressed size 25
+		0x16, 0x32, //compressed size 25
+		0x26, 0x08, //data page offset 4
+		0x00, 0x00,
+		0x26, 0x3a, //file offset 29
+		0x1c,
+		0x15, 0x0c, //BYTE_ARRAY
+		0x19, 0x25, 0x00, 0x06,
+		0x19, 0x18, 1, 's',
+		0x15, 0x00,
+		0x16, 0x04,
+		0x16, 0x38, //uncompressed size 28
+		0x16, 0x38, //compressed size 28
+		0x26, 0x3a, //data page offset 29
+		0x00, 0x00,
+		0x16, 0x6a, //total byte size 53
+		0x16, 0x04, //2 rows
+		0x00,
+		0x28, 34)
+	footer = append(footer, "github.com/danhitchcock/ms/parquet"...)
+	footer = append(footer, 0x00)
+	want = append(want, footer...)
+	want = binary.LittleEndian.AppendUint32(want, uint32(len(footer)))
+	want = append(want, "PAR1"...)
+
+	if got := out.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("file is\n% x\nwant\n% x", got, want)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	columns := []Column{{Name: "a", Type: Int32}, {Name: "b", Type: Double, Optional: true}}
+	tests := []struct {
+		name string
+		row  []interface{}
+	}{
+		{"null in a required column", []interface{}{nil, 1.0}},
+		{"wrong type", []interface{}{int32(1), float32(1)}},
+		{"too few values", []interface{}{int32(1)}},
+	}
+	for _, tt := range tests {
+		w, err := NewWriter(&bytes.Buffer{}, columns, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(tt.row...); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//Package peptide computes masses of (modified) peptides and their
+//fragment ions, and annotates MS2 spectra with them.
+package peptide
+
+import "github.com/danhitchcock/ms/chem"
+
+//Residues holds the elemental composition of the amino acid residues
+//(the amino acid minus water), indexed by one letter code
+var Residues = map[byte]chem.Formula{
+	'G': chem.MustParseFormula("C2H3NO"),
+	'A': chem.MustParseFormula("C3H5NO"),
+	'S': chem.MustParseFormula("C3H5NO2"),
+	'P': chem.MustParseFormula("C5H7NO"),
+	'V': chem.MustParseFormula("C5H9NO"),
+	'T': chem.MustParseFormula("C4H7NO2"),
+	'C': chem.MustParseFormula("C3H5NOS"),
+	'L': chem.MustParseFormula("C6H11NO"),
+	'I': chem.MustParseFormula("C6H11NO"),
+	'N': chem.MustParseFormula("C4H6N2O2"),
+	'D': chem.MustParseFormula("C4H5NO3"),
+	'Q': chem.MustParseFormula("C5H8N2O2"),
+	'K': chem.MustParseFormula("C6H12N2O"),
+	'E': chem.MustParseFormula("C5H7NO3"),
+	'M': chem.MustParseFormula("C5H9NOS"),
+	'H': chem.MustParseFormula("C6H7N3O"),
+	'F': chem.MustParseFormula("C9H9NO"),
+	'R': chem.MustParseFormula("C6H12N4O"),
+	'Y': chem.MustParseFormula("C9H9NO2"),
+	'W': chem.MustParseFormula("C11H10N2O"),
+	'U': chem.MustParseFormula("C3H5NOSe"),
+	'O': chem.MustParseFormula("C12H19N3O2"),
+}
+
+//residueMasses caches the monoisotopic residue masses
+var residueMasses = func() map[byte]float64 {
+	m := make(map[byte]float64, len(Residues))
+	for aa, f := range Residues {
+		m[aa] = f.MonoisotopicMass()
+	}
+	return m
+}()
+
+//Water is added to the residues to form a peptide
+var Water = chem.MustParseFormula("H2O")
+
+//Ammonia is a common neutral loss from R, K, N and Q
+var Ammonia = chem.MustParseFormula("NH3")
@@ -0,0 +1,185 @@
+package peptide
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/chem"
+)
+
+//IonType is the kind of backbone fragment: a, b and c contain the N-terminus,
+//x, y and z the C-terminus. The z ions are the z• radicals seen in ETD/ECD.
+type IonType byte
+
+//The fragment ion types
+const (
+	A IonType = 'a'
+	B IonType = 'b'
+	C IonType = 'c'
+	X IonType = 'x'
+	Y IonType = 'y'
+	Z IonType = 'z'
+)
+
+//ionDeltas are the compositions added to the residues of a fragment
+//to get the neutral fragment, its ion carries charge protons
+var ionDeltas = map[IonType]chem.Formula{
+	A: chem.MustParseFormula("C-1O-1"),
+	B: {},
+	C: chem.MustParseFormula("NH3"),
+	X: chem.MustParseFormula("CO2"),
+	Y: chem.MustParseFormula("H2O"),
+	Z: chem.MustParseFormula("ON-1"),
+}
+
+//NTerminal reports whether the fragment contains the N-terminus
+func (t IonType) NTerminal() bool {
+	return t == A || t == B || t == C
+}
+
+//Fragment is a theoretical fragment ion
+type Fragment struct {
+	Type IonType
+	//Number is the number of residues in the fragment
+	Number int
+	Charge int
+	//Loss is the neutral loss, empty if there is none
+	Loss string
+	Mz   float64
+}
+
+//String returns the fragment label, e.g. "y7", "b3-H2O" or "y12-NH3^2+"
+func (f Fragment) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%c%d", f.Type, f.Number)
+	if f.Loss != "" {
+		b.WriteString("-" + f.Loss)
+	}
+	if f.Charge > 1 {
+		fmt.Fprintf(&b, "^%d+", f.Charge)
+	}
+	return b.String()
+}
+
+//FragmentOptions select which fragments are generated
+type FragmentOptions struct {
+	//Types are the ion types, e.g. []IonType{B, Y} for CID/HCD or {C, Z} for ETD
+	Types []IonType
+	//MaxCharge is the highest fragment charge, fragments are never
+	//generated with a higher charge than the precursor's if it is set
+	MaxCharge       int
+	PrecursorCharge int
+	//NeutralLosses adds fragments that lost water (S, T, E, D), ammonia (R, K, N, Q)
+	//or the neutral losses of the modifications they carry
+	NeutralLosses bool
+}
+
+//DefaultFragmentOptions generates singly and doubly charged b and y ions
+var DefaultFragmentOptions = FragmentOptions{Types: []IonType{B, Y}, MaxCharge: 2}
+
+//Fragments returns the fragment ladders of the peptide, sorted by m/z
+func (p Peptide) Fragments(opts FragmentOptions) (frags []Fragment) {
+	maxCharge := opts.MaxCharge
+	if maxCharge < 1 {
+		maxCharge = 1
+	}
+	if opts.PrecursorCharge > 0 && opts.PrecursorCharge < maxCharge {
+		maxCharge = opts.PrecursorCharge
+	}
+
+	n := len(p.Sequence)
+	for _, t := range opts.Types {
+		delta, ok := ionDeltas[t]
+		if !ok {
+			continue
+		}
+		deltaMass := delta.MonoisotopicMass()
+
+		var residues float64
+		var losses map[string]float64
+		for k := 1; k < n; k++ {
+			//index of the residue that is added to the fragment
+			i := k - 1
+			if !t.NTerminal() {
+				i = n - k
+			}
+			//the first residue brings the terminal modification
+			var term *Modification
+			if k == 1 {
+				term = p.CTerm
+				if t.NTerminal() {
+					term = p.NTerm
+				}
+			}
+			residues += residueMasses[p.Sequence[i]]
+			if m := p.mod(i); m != nil {
+				residues += m.Mass
+			}
+			if term != nil {
+				residues += term.Mass
+			}
+			if opts.NeutralLosses {
+				losses = p.addLosses(losses, i, term)
+			}
+
+			neutral := residues + deltaMass
+			for z := 1; z <= maxCharge; z++ {
+				frags = append(frags, Fragment{t, k, z, "", chem.Mz(neutral, z)})
+				for name, loss := range losses {
+					frags = append(frags, Fragment{t, k, z, name, chem.Mz(neutral-loss, z)})
+				}
+			}
+		}
+	}
+
+	sort.Slice(frags, func(i, j int) bool { return frags[i].Mz < frags[j].Mz })
+	return
+}
+
+//addLosses adds the neutral losses that residue i and the terminal
+//modification term, which may be nil, make possible
+func (p Peptide) addLosses(losses map[string]float64, i int, term *Modification) map[string]float64 {
+	if losses == nil {
+		losses = make(map[string]float64)
+	}
+	switch p.Sequence[i] {
+	case 'S', 'T', 'E', 'D':
+		losses["H2O"] = Water.MonoisotopicMass()
+	case 'R', 'K', 'N', 'Q':
+		losses["NH3"] = Ammonia.MonoisotopicMass()
+	}
+	for _, m := range []*Modification{p.mod(i), term} {
+		if m == nil {
+			continue
+		}
+		for _, l := range m.NeutralLosses {
+			losses[l.String()] = l.MonoisotopicMass()
+		}
+	}
+	return losses
+}
+
+//Annotation is a spectrum peak explained by a fragment
+type Annotation struct {
+	ms.Peak
+	Fragment Fragment
+	//Error is the m/z error of the peak in ppm
+	Error float64
+}
+
+//Annotate matches the fragments against the spectrum, which has to be sorted
+//by m/z. For every fragment the most intense peak within the tolerance is taken.
+//Fragments without a peak are left out, a peak can explain several fragments.
+func Annotate(spectrum ms.Spectrum, frags []Fragment, tol ms.Tolerance) (annotations []Annotation) {
+	for _, f := range frags {
+		window := spectrum.Around(f.Mz, tol)
+		if len(window) == 0 {
+			continue
+		}
+		peak := window.MaxPeak()
+		annotations = append(annotations, Annotation{peak, f, ms.PPMError(f.Mz, peak.Mz)})
+	}
+	return
+}
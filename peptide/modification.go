@@ -0,0 +1,71 @@
+package peptide
+
+import (
+	"strings"
+
+	"github.com/danhitchcock/ms/chem"
+)
+
+//Modification is a chemical change of a residue or peptide terminus
+type Modification struct {
+	//Name is the Unimod name, or the mass delta for unnamed modifications
+	Name string
+	//Composition is the elemental change, nil if only the mass is known
+	Composition chem.Formula
+	//Mass is the monoisotopic mass change
+	Mass float64
+	//Sites are the one letter codes of the residues the modification can be on,
+	//'n' stands for the peptide N-terminus and 'c' for the C-terminus
+	Sites string
+	//NeutralLosses are losses from fragments that carry the modification
+	NeutralLosses []chem.Formula
+}
+
+//NewModification returns the modification with the supplied composition
+//on the supplied sites (see Modification.Sites)
+func NewModification(name string, composition string, sites string, losses ...string) Modification {
+	m := Modification{Name: name, Composition: chem.MustParseFormula(composition), Sites: sites}
+	m.Mass = m.Composition.MonoisotopicMass()
+	for _, l := range losses {
+		m.NeutralLosses = append(m.NeutralLosses, chem.MustParseFormula(l))
+	}
+	return m
+}
+
+//On reports whether the modification can be on the site
+func (m Modification) On(site byte) bool {
+	return strings.IndexByte(m.Sites, site) >= 0
+}
+
+//Common modifications, with their Unimod compositions
+var (
+	Carbamidomethyl = NewModification("Carbamidomethyl", "H3C2NO", "C")
+	Oxidation       = NewModification("Oxidation", "O", "M", "CH4OS")
+	Phospho         = NewModification("Phospho", "HPO3", "STY", "H3PO4")
+	Acetyl          = NewModification("Acetyl", "H2C2O", "nK")
+	Amidated        = NewModification("Amidated", "HNO-1", "c")
+	Deamidated      = NewModification("Deamidated", "H-1N-1O", "NQ")
+	Methyl          = NewModification("Methyl", "H2C", "KR")
+	GlyGly          = NewModification("GG", "H6C4N2O2", "K")
+	ITRAQ4plex      = NewModification("iTRAQ4plex", "H12C4[13C]3N[15N]O", "nKY")
+	ITRAQ8plex      = NewModification("iTRAQ8plex", "H24C7[13C]7N3[15N]O3", "nKY")
+	TMT             = NewModification("TMT", "H20C12N2O2", "nK")
+	TMT2plex        = NewModification("TMT2plex", "H20C11[13C]N2O2", "nK")
+	TMT6plex        = NewModification("TMT6plex", "H20C8[13C]4N[15N]O2", "nK")
+	TMTpro          = NewModification("TMTpro", "H25C8[13C]7N[15N]2O3", "nK")
+)
+
+//Modifications indexes the common modifications by name
+var Modifications = map[string]Modification{}
+
+func init() {
+	for _, m := range []Modification{Carbamidomethyl, Oxidation, Phospho, Acetyl,
+		Amidated, Deamidated, Methyl, GlyGly, ITRAQ4plex, ITRAQ8plex, TMT, TMT2plex, TMT6plex, TMTpro} {
+		Modifications[m.Name] = m
+	}
+	//TMT 10 and 11-plex share the 6-plex reagent composition
+	Modifications["TMT10plex"] = TMT6plex
+	Modifications["TMT11plex"] = TMT6plex
+	Modifications["TMTpro16plex"] = TMTpro
+	Modifications["TMTpro18plex"] = TMTpro
+}
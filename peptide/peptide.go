@@ -0,0 +1,264 @@
+package peptide
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/danhitchcock/ms/chem"
+)
+
+//Peptide is an amino acid sequence with its modifications
+type Peptide struct {
+	Sequence string
+	//Mods holds the modification of every residue, nil if it is unmodified
+	Mods []*Modification
+	//NTerm and CTerm are the terminal modifications, nil if unmodified
+	NTerm *Modification
+	CTerm *Modification
+}
+
+//ErrMassOnly is returned when the composition of a peptide is asked
+//while it carries a modification of which only the mass is known
+var ErrMassOnly = errors.New("modification without composition")
+
+//New returns the unmodified peptide with the supplied sequence
+func New(sequence string) (p Peptide, err error) {
+	for i := 0; i < len(sequence); i++ {
+		if _, ok := Residues[sequence[i]]; !ok {
+			err = fmt.Errorf("unknown amino acid %q in %q", sequence[i], sequence)
+			return
+		}
+	}
+	p.Sequence = sequence
+	p.Mods = make([]*Modification, len(sequence))
+	return
+}
+
+//Parse reads a sequence with modifications between square brackets after
+//the residue they are on, e.g. "PEPM[Oxidation]TIDEK". Terminal modifications
+//are separated by a dash: "[TMT6plex]-PEPTIDEK[TMT6plex]-[Amidated]".
+//Modifications are either names from Modifications or signed mass deltas
+//such as "[+15.9949]".
+func Parse(s string) (p Peptide, err error) {
+	var seq strings.Builder
+	var mods []*Modification
+	var nterm, cterm *Modification
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				err = fmt.Errorf("unterminated modification in %q", s)
+				return
+			}
+			var m *Modification
+			if m, err = lookupModification(s[i+1 : i+end]); err != nil {
+				return
+			}
+			i += end + 1
+			switch {
+			case i < len(s) && s[i] == '-' && seq.Len() == 0:
+				nterm = m
+				i++
+			case seq.Len() == 0:
+				err = fmt.Errorf("modification before the first residue in %q", s)
+				return
+			default:
+				mods[len(mods)-1] = m
+			}
+		case c == '-' && i+1 < len(s) && s[i+1] == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 || i+end+1 != len(s) {
+				err = fmt.Errorf("malformed C-terminal modification in %q", s)
+				return
+			}
+			if cterm, err = lookupModification(s[i+2 : i+end]); err != nil {
+				return
+			}
+			i += end + 1
+		default:
+			seq.WriteByte(c)
+			mods = append(mods, nil)
+			i++
+		}
+	}
+
+	if p, err = New(seq.String()); err != nil {
+		return
+	}
+	p.Mods = mods
+	p.NTerm = nterm
+	p.CTerm = cterm
+	return
+}
+
+//lookupModification resolves a name or a mass delta
+func lookupModification(name string) (*Modification, error) {
+	if m, ok := Modifications[name]; ok {
+		return &m, nil
+	}
+	if delta, err := strconv.ParseFloat(name, 64); err == nil {
+		return &Modification{Name: name, Mass: delta}, nil
+	}
+	return nil, fmt.Errorf("unknown modification %q", name)
+}
+
+//String returns the peptide in the notation read by Parse
+func (p Peptide) String() string {
+	var b strings.Builder
+	if p.NTerm != nil {
+		b.WriteString("[" + p.NTerm.Name + "]-")
+	}
+	for i := 0; i < len(p.Sequence); i++ {
+		b.WriteByte(p.Sequence[i])
+		if m := p.mod(i); m != nil {
+			b.WriteString("[" + m.Name + "]")
+		}
+	}
+	if p.CTerm != nil {
+		b.WriteString("-[" + p.CTerm.Name + "]")
+	}
+	return b.String()
+}
+
+//mod returns the modification of residue i, nil if it is unmodified. Mods is
+//shorter than the sequence in peptides that were not made by New or Parse.
+func (p Peptide) mod(i int) *Modification {
+	if i < len(p.Mods) {
+		return p.Mods[i]
+	}
+	return nil
+}
+
+//Copy returns a peptide with an independent slice of modifications
+func (p Peptide) Copy() Peptide {
+	q := p
+	q.Mods = append([]*Modification(nil), p.Mods...)
+	return q
+}
+
+//Mass returns the monoisotopic neutral mass of the peptide
+func (p Peptide) Mass() float64 {
+	m := Water.MonoisotopicMass()
+	for i := 0; i < len(p.Sequence); i++ {
+		m += residueMasses[p.Sequence[i]]
+	}
+	for _, mod := range p.allMods() {
+		m += mod.Mass
+	}
+	return m
+}
+
+//Mz returns the monoisotopic m/z of the peptide protonated to the supplied charge
+func (p Peptide) Mz(charge int) float64 {
+	return chem.Mz(p.Mass(), charge)
+}
+
+//Formula returns the elemental composition of the neutral peptide,
+//for instance to compute its isotope distribution
+func (p Peptide) Formula() (chem.Formula, error) {
+	f := Water.Copy()
+	for i := 0; i < len(p.Sequence); i++ {
+		f = f.Add(Residues[p.Sequence[i]])
+	}
+	for _, mod := range p.allMods() {
+		if mod.Composition == nil {
+			return nil, fmt.Errorf("%w: %s", ErrMassOnly, mod.Name)
+		}
+		f = f.Add(mod.Composition)
+	}
+	return f, nil
+}
+
+//allMods returns all modifications that are present
+func (p Peptide) allMods() (mods []*Modification) {
+	for _, m := range append([]*Modification{p.NTerm, p.CTerm}, p.Mods...) {
+		if m != nil {
+			mods = append(mods, m)
+		}
+	}
+	return
+}
+
+//sites returns the indices of the unmodified sites that m can be on,
+//-1 stands for the N-terminus and len(Sequence) for the C-terminus
+func (p Peptide) sites(m Modification) (s []int) {
+	if p.NTerm == nil && m.On('n') {
+		s = append(s, -1)
+	}
+	for i := 0; i < len(p.Sequence); i++ {
+		if p.mod(i) == nil && m.On(p.Sequence[i]) {
+			s = append(s, i)
+		}
+	}
+	if p.CTerm == nil && m.On('c') {
+		s = append(s, len(p.Sequence))
+	}
+	return
+}
+
+//set puts the modification on the site returned by sites
+func (p *Peptide) set(site int, m *Modification) {
+	switch site {
+	case -1:
+		p.NTerm = m
+	case len(p.Sequence):
+		p.CTerm = m
+	default:
+		if len(p.Mods) < len(p.Sequence) {
+			p.Mods = append(p.Mods, make([]*Modification, len(p.Sequence)-len(p.Mods))...)
+		}
+		p.Mods[site] = m
+	}
+}
+
+//SetFixed puts the fixed modifications on every unmodified site they can be on.
+//Modifications earlier in the list take precedence.
+func (p *Peptide) SetFixed(mods ...Modification) {
+	for i := range mods {
+		for _, site := range p.sites(mods[i]) {
+			p.set(site, &mods[i])
+		}
+	}
+}
+
+//VariableForms returns all forms of the peptide carrying at most maxMods of
+//the variable modifications on its unmodified sites, including the peptide itself
+func (p Peptide) VariableForms(mods []Modification, maxMods int) []Peptide {
+	type candidate struct {
+		site int
+		mod  *Modification
+	}
+	var candidates []candidate
+	for i := range mods {
+		for _, site := range p.sites(mods[i]) {
+			candidates = append(candidates, candidate{site, &mods[i]})
+		}
+	}
+
+	forms := []Peptide{p.Copy()}
+	used := make(map[int]bool)
+	var extend func(form Peptide, start int, n int)
+	extend = func(form Peptide, start int, n int) {
+		if n == maxMods {
+			return
+		}
+		for i := start; i < len(candidates); i++ {
+			c := candidates[i]
+			if used[c.site] {
+				continue
+			}
+			next := form.Copy()
+			next.set(c.site, c.mod)
+			forms = append(forms, next)
+			used[c.site] = true
+			extend(next, i+1, n+1)
+			used[c.site] = false
+		}
+	}
+	extend(p, 0, 0)
+	return forms
+}
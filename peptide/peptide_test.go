@@ -0,0 +1,106 @@
+package peptide
+
+import (
+	"math"
+	"testing"
+)
+
+func TestModifications(t *testing.T) {
+	//the monoisotopic deltas of Unimod
+	unimod := map[string]float64{
+		"Carbamidomethyl": 57.021464,
+		"Oxidation":       15.994915,
+		"Phospho":         79.966331,
+		"Acetyl":          42.010565,
+		"Amidated":        -0.984016,
+		"Deamidated":      0.984016,
+		"Methyl":          14.015650,
+		"GG":              114.042927,
+		"iTRAQ4plex":      144.102063,
+		"iTRAQ8plex":      304.205360,
+		"TMT":             224.152478,
+		"TMT2plex":        225.155833,
+		"TMT6plex":        229.162932,
+		"TMTpro":          304.207146,
+	}
+	for name, mass := range unimod {
+		m, ok := Modifications[name]
+		if !ok {
+			t.Errorf("%s is missing", name)
+			continue
+		}
+		if math.Abs(m.Mass-mass) > 1e-5 {
+			t.Errorf("%s: mass %.6f, want %.6f", name, m.Mass, mass)
+		}
+	}
+}
+
+func TestMasses(t *testing.T) {
+	tests := []struct {
+		peptide string
+		mass    float64
+	}{
+		{"PEPTIDEK", 927.454928},
+		{"PEPTIDEK-[Amidated]", 927.454928 - 0.984016},
+		{"[TMT6plex]-PEPTIDEK[TMT6plex]", 927.454928 + 2*229.162932},
+		{"PEPM[Oxidation]C[Carbamidomethyl]K", 97.052764 + 129.042593 + 97.052764 + 131.040485 + 15.994915 +
+			103.009185 + 57.021464 + 128.094963 + 18.010565},
+		{"PEPTIDEK[+10.5]", 927.454928 + 10.5},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.peptide)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.peptide, err)
+		}
+		if got := p.Mass(); math.Abs(got-tt.mass) > 1e-5 {
+			t.Errorf("%s: mass %.6f, want %.6f", tt.peptide, got, tt.mass)
+		}
+		if got, want := p.Mz(2), (tt.mass+2*1.007276467)/2; math.Abs(got-want) > 1e-5 {
+			t.Errorf("%s: m/z %.6f, want %.6f", tt.peptide, got, want)
+		}
+		if p.String() != tt.peptide {
+			t.Errorf("%s: String() = %s", tt.peptide, p.String())
+		}
+	}
+}
+
+func TestFragments(t *testing.T) {
+	tests := []struct {
+		peptide string
+		label   string
+		mz      float64
+	}{
+		{"PEPTIDEK", "b2", 227.102634},
+		{"PEPTIDEK", "y1", 147.112804},
+		{"PEPTIDEK", "y2^2+", (147.112804 + 129.042593 + 1.007276) / 2},
+		//terminal modifications are on the first fragment of their side
+		{"PEPTIDEK-[Amidated]", "y1", 147.112804 - 0.984016},
+		{"[TMT6plex]-PEPTIDEK", "b1", 97.052764 + 229.162932 + 1.007276},
+		{"[TMT6plex]-PEPTIDEK", "y1", 147.112804},
+		{"PEPTIDEK", "c2", 227.102634 + 17.026549},
+		{"PEPTIDEK", "z1", 147.112804 - 16.018724},
+		{"PEPTIDEK", "b2-H2O", 227.102634 - 18.010565},
+		{"PEPTIDEK", "y1-NH3", 147.112804 - 17.026549},
+		{"PEPM[Oxidation]TIDEK", "b4-CH4OS", 97.052764 + 129.042593 + 97.052764 + 131.040485 + 15.994915 +
+			1.007276 - 63.998285},
+	}
+	opts := FragmentOptions{Types: []IonType{B, C, Y, Z}, MaxCharge: 2, NeutralLosses: true}
+	for _, tt := range tests {
+		p, err := Parse(tt.peptide)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.peptide, err)
+		}
+		found := false
+		for _, f := range p.Fragments(opts) {
+			if f.String() == tt.label {
+				found = true
+				if math.Abs(f.Mz-tt.mz) > 1e-5 {
+					t.Errorf("%s %s: m/z %.6f, want %.6f", tt.peptide, tt.label, f.Mz, tt.mz)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: no fragment %s", tt.peptide, tt.label)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ms
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+//Reader gives random access to the scans of a run, whatever the file format.
+//Scan numbers count from 1 to NScans.
+type Reader interface {
+	NScans() int
+	Scan(sn int) Scan
+	//Spectrum returns only the spectrum of the scan
+	Spectrum(sn int) Spectrum
+	Metadata() Metadata
+	Chromatograms() []Chromatogram
+	Close() error
+}
+
+//ErrFormat is returned by Open for files of which the format is not registered
+var ErrFormat = errors.New("ms: unknown format")
+
+//format is a registered file format
+type format struct {
+	name  string
+	match func(head []byte) bool
+	open  func(path string) (Reader, error)
+}
+
+var formats []format
+
+//RegisterFormat registers a file format for Open. Match reports whether the
+//first bytes of a file (up to 4096) are of the format. Reader packages
+//register themselves when they are imported, e.g.
+//  import _ "github.com/danhitchcock/ms/unthermo"
+func RegisterFormat(name string, match func(head []byte) bool, open func(path string) (Reader, error)) {
+	formats = append(formats, format{name, match, open})
+}
+
+//Open opens the file with the reader of the registered format it matches
+func Open(path string) (Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 4096)
+	n, err := io.ReadFull(f, head)
+	f.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	for _, fm := range formats {
+		if fm.match(head[:n]) {
+			return fm.open(path)
+		}
+	}
+	return nil, ErrFormat
+}
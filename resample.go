@@ -0,0 +1,160 @@
+package ms
+
+import (
+	"math"
+	"sort"
+)
+
+//Grid is an m/z axis of adjacent bins, given by their ascending edges.
+//Bin i spans [Grid[i], Grid[i+1]).
+type Grid []float64
+
+//LinearGrid returns a grid from minMz to (at least) maxMz with bins of a fixed width in Da
+func LinearGrid(minMz float64, maxMz float64, width float64) Grid {
+	n := int(math.Ceil((maxMz - minMz) / width))
+	g := make(Grid, n+1)
+	for i := range g {
+		g[i] = minMz + float64(i)*width
+	}
+	return g
+}
+
+//PPMGrid returns a grid from minMz to (at least) maxMz with bins
+//whose width is proportional to their m/z, each is ppm wide
+func PPMGrid(minMz float64, maxMz float64, ppm float64) Grid {
+	f := 1 + ppm*1e-6
+	n := int(math.Ceil(math.Log(maxMz/minMz) / math.Log(f)))
+	g := make(Grid, n+1)
+	for i := range g {
+		g[i] = minMz * math.Pow(f, float64(i))
+	}
+	return g
+}
+
+//Len returns the number of bins
+func (g Grid) Len() int {
+	if len(g) < 2 {
+		return 0
+	}
+	return len(g) - 1
+}
+
+//Center returns the m/z in the middle of bin i
+func (g Grid) Center(i int) float64 {
+	return (g[i] + g[i+1]) / 2
+}
+
+//Bin returns the index of the bin containing mz, -1 if it is outside the grid
+func (g Grid) Bin(mz float64) int {
+	if g.Len() == 0 || mz < g[0] || mz >= g[len(g)-1] {
+		return -1
+	}
+	return sort.SearchFloat64s(g, math.Nextafter(mz, math.Inf(1))) - 1
+}
+
+//ResampleMode determines how peaks are mapped on grid bins
+type ResampleMode int
+
+const (
+	//SumMode adds the intensity of every peak to the bin it falls in,
+	//which suits centroided spectra
+	SumMode ResampleMode = iota
+	//LinearMode interpolates between adjacent peaks at the bin centers,
+	//which suits profile spectra. Outside the spectrum the signal is 0.
+	LinearMode
+)
+
+//Resample maps a spectrum sorted by m/z onto the grid and returns the intensity of every bin
+func (g Grid) Resample(s Spectrum, mode ResampleMode) []float64 {
+	v := make([]float64, g.Len())
+	g.accumulate(v, s, mode)
+	return v
+}
+
+//accumulate adds the resampled spectrum to v
+func (g Grid) accumulate(v []float64, s Spectrum, mode ResampleMode) {
+	switch mode {
+	case SumMode:
+		for _, p := range s {
+			if i := g.Bin(p.Mz); i >= 0 {
+				v[i] += float64(p.I)
+			}
+		}
+	case LinearMode:
+		if len(s) == 0 {
+			return
+		}
+		//walk the bins that lie within the spectrum and keep the peak
+		//pointer j at the first peak at or beyond the bin center
+		first := sort.SearchFloat64s(g, s[0].Mz)
+		if first > 0 {
+			first--
+		}
+		j := 0
+		for i := first; i < g.Len(); i++ {
+			c := g.Center(i)
+			for j < len(s) && s[j].Mz < c {
+				j++
+			}
+			if j == len(s) {
+				return
+			}
+			if j == 0 {
+				if s[0].Mz == c {
+					v[i] += float64(s[0].I)
+				}
+				continue
+			}
+			lo, hi := s[j-1], s[j]
+			f := (c - lo.Mz) / (hi.Mz - lo.Mz)
+			v[i] += float64(lo.I) + f*float64(hi.I-lo.I)
+		}
+	}
+}
+
+//Spectrum returns the bin centers with the supplied intensities as a spectrum
+func (g Grid) Spectrum(v []float64) Spectrum {
+	s := make(Spectrum, g.Len())
+	for i := range s {
+		s[i] = Peak{Mz: g.Center(i), I: float32(v[i])}
+	}
+	return s
+}
+
+//Accumulator sums spectra on a common grid, e.g. to compute a mean spectrum
+type Accumulator struct {
+	Grid Grid
+	Mode ResampleMode
+	//Total holds the summed intensity of every bin
+	Total []float64
+	//N is the number of spectra that were added
+	N int
+}
+
+//NewAccumulator returns an empty accumulator on the grid
+func NewAccumulator(g Grid, mode ResampleMode) *Accumulator {
+	return &Accumulator{Grid: g, Mode: mode, Total: make([]float64, g.Len())}
+}
+
+//Add resamples the spectrum and adds it to the total
+func (a *Accumulator) Add(s Spectrum) {
+	a.Grid.accumulate(a.Total, s, a.Mode)
+	a.N++
+}
+
+//Sum returns the summed spectrum
+func (a *Accumulator) Sum() Spectrum {
+	return a.Grid.Spectrum(a.Total)
+}
+
+//Mean returns the summed spectrum divided by the number of added spectra
+func (a *Accumulator) Mean() Spectrum {
+	if a.N == 0 {
+		return a.Sum()
+	}
+	v := make([]float64, len(a.Total))
+	for i := range v {
+		v[i] = a.Total[i] / float64(a.N)
+	}
+	return a.Grid.Spectrum(v)
+}
@@ -0,0 +1,202 @@
+package ms
+
+import (
+	"math"
+	"sort"
+)
+
+//IntensityTransform maps a peak to the weight it has in a similarity score
+type IntensityTransform func(Peak) float64
+
+//IdentityTransform uses the raw intensity
+func IdentityTransform(p Peak) float64 { return float64(p.I) }
+
+//SqrtTransform dampens intense peaks by taking the square root of the intensity
+func SqrtTransform(p Peak) float64 { return math.Sqrt(float64(p.I)) }
+
+//LogTransform dampens intense peaks by taking log(1+intensity)
+func LogTransform(p Peak) float64 { return math.Log1p(float64(p.I)) }
+
+//MzWeightedTransform returns the transform mz^mzPower * I^intensityPower,
+//e.g. MzWeightedTransform(3, 0.6) as used for GC-MS library search
+func MzWeightedTransform(mzPower float64, intensityPower float64) IntensityTransform {
+	return func(p Peak) float64 {
+		return math.Pow(p.Mz, mzPower) * math.Pow(float64(p.I), intensityPower)
+	}
+}
+
+//SimilarityOptions configure the comparison of two spectra
+type SimilarityOptions struct {
+	//Tolerance is the m/z window in which peaks of both spectra are paired
+	Tolerance Tolerance
+	//Transform is applied to the intensities first, nil is the identity
+	Transform IntensityTransform
+	//WeightedEntropy applies the entropy based intensity weighting of
+	//Li et al. (2021) in EntropySimilarity
+	WeightedEntropy bool
+}
+
+//PeakPair is a peak of spectrum A matched with a peak of spectrum B.
+//For an unmatched peak the index of the other spectrum is -1 and its peak is zero.
+type PeakPair struct {
+	A, B           Peak
+	IndexA, IndexB int
+}
+
+//Matched reports whether both sides of the pair are present
+func (p PeakPair) Matched() bool {
+	return p.IndexA >= 0 && p.IndexB >= 0
+}
+
+//MatchPeaks pairs the peaks of two spectra sorted by m/z. Every peak is used at
+//most once: of all pairs within the tolerance, the pairs with the highest product
+//of intensities are taken first. The matched pairs come first, sorted by the
+//m/z of A, followed by the unmatched peaks of A and then of B.
+func MatchPeaks(a Spectrum, b Spectrum, tol Tolerance) (pairs []PeakPair) {
+	type candidate struct {
+		i, j  int
+		score float64
+	}
+	var candidates []candidate
+	start := 0
+	for i, p := range a {
+		min, max := tol.Window(p.Mz)
+		for start < len(b) && b[start].Mz < min {
+			start++
+		}
+		for j := start; j < len(b) && b[j].Mz <= max; j++ {
+			candidates = append(candidates, candidate{i, j, float64(p.I) * float64(b[j].I)})
+		}
+	}
+	sort.SliceStable(candidates, func(x, y int) bool { return candidates[x].score > candidates[y].score })
+
+	usedA := make([]bool, len(a))
+	usedB := make([]bool, len(b))
+	for _, c := range candidates {
+		if usedA[c.i] || usedB[c.j] {
+			continue
+		}
+		usedA[c.i] = true
+		usedB[c.j] = true
+		pairs = append(pairs, PeakPair{a[c.i], b[c.j], c.i, c.j})
+	}
+	sort.Slice(pairs, func(x, y int) bool { return pairs[x].IndexA < pairs[y].IndexA })
+
+	for i, p := range a {
+		if !usedA[i] {
+			pairs = append(pairs, PeakPair{A: p, IndexA: i, IndexB: -1})
+		}
+	}
+	for j, p := range b {
+		if !usedB[j] {
+			pairs = append(pairs, PeakPair{B: p, IndexA: -1, IndexB: j})
+		}
+	}
+	return
+}
+
+//weights applies the transform of the options to both sides of the pairs
+func (o SimilarityOptions) weights(pairs []PeakPair) (wa []float64, wb []float64) {
+	t := o.Transform
+	if t == nil {
+		t = IdentityTransform
+	}
+	wa = make([]float64, len(pairs))
+	wb = make([]float64, len(pairs))
+	for k, p := range pairs {
+		if p.IndexA >= 0 {
+			wa[k] = t(p.A)
+		}
+		if p.IndexB >= 0 {
+			wb[k] = t(p.B)
+		}
+	}
+	return
+}
+
+//DotProduct returns the normalized dot product (cosine similarity) between
+//0 and 1 of the transformed intensities. Unmatched peaks do not add to the
+//product but do add to the norms, so they lower the score.
+//It is 0 if either spectrum is empty.
+func DotProduct(a Spectrum, b Spectrum, opts SimilarityOptions) float64 {
+	wa, wb := opts.weights(MatchPeaks(a, b, opts.Tolerance))
+	var dot, na, nb float64
+	for k := range wa {
+		dot += wa[k] * wb[k]
+		na += wa[k] * wa[k]
+		nb += wb[k] * wb[k]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return math.Min(1, dot/math.Sqrt(na*nb))
+}
+
+//SpectralContrastAngle returns the angle (in radians, between 0 and π/2)
+//between the spectra as intensity vectors, i.e. the arccosine of the DotProduct.
+//The normalized form 1-2θ/π is a similarity between 0 and 1.
+func SpectralContrastAngle(a Spectrum, b Spectrum, opts SimilarityOptions) float64 {
+	return math.Acos(DotProduct(a, b, opts))
+}
+
+//EntropySimilarity returns the spectral entropy similarity between 0 and 1
+//of Li et al. (2021): 1 - (2·S(AB) - S(A) - S(B))/ln(4), with S the Shannon
+//entropy of the spectra normalized to a total intensity of 1 and AB the
+//spectrum with the average intensities of the paired peaks. Unmatched peaks
+//appear in AB at half their intensity, they lower the score.
+//It is 0 if either spectrum is empty.
+func EntropySimilarity(a Spectrum, b Spectrum, opts SimilarityOptions) float64 {
+	wa, wb := opts.weights(MatchPeaks(a, b, opts.Tolerance))
+	if !normalizeSum(wa) || !normalizeSum(wb) {
+		return 0
+	}
+	if opts.WeightedEntropy {
+		entropyWeight(wa)
+		entropyWeight(wb)
+	}
+
+	merged := make([]float64, len(wa))
+	for k := range wa {
+		merged[k] = (wa[k] + wb[k]) / 2
+	}
+	s := 1 - (2*entropy(merged)-entropy(wa)-entropy(wb))/math.Log(4)
+	return math.Max(0, math.Min(1, s))
+}
+
+//normalizeSum scales w to a sum of 1, false if the sum is 0
+func normalizeSum(w []float64) bool {
+	var sum float64
+	for _, v := range w {
+		sum += v
+	}
+	if sum <= 0 {
+		return false
+	}
+	for k := range w {
+		w[k] /= sum
+	}
+	return true
+}
+
+//entropy is the Shannon entropy of intensities summing to 1
+func entropy(w []float64) (s float64) {
+	for _, v := range w {
+		if v > 0 {
+			s -= v * math.Log(v)
+		}
+	}
+	return
+}
+
+//entropyWeight raises low entropy spectra to the power 0.25+S/4 and
+//renormalizes them, which gives less weight to their dominant peaks
+func entropyWeight(w []float64) {
+	s := entropy(w)
+	if s >= 3 {
+		return
+	}
+	for k := range w {
+		w[k] = math.Pow(w[k], 0.25+s*0.25)
+	}
+	normalizeSum(w)
+}
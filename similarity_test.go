@@ -0,0 +1,63 @@
+package ms
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	a := Spectrum{{100, 10}, {200, 20}, {300, 5}}
+	tests := []struct {
+		name         string
+		a, b         Spectrum
+		dot, entropy float64
+	}{
+		{"identical", a, a, 1, 1},
+		{"shifted within tolerance", a, Spectrum{{100.004, 10}, {199.996, 20}, {300.001, 5}}, 1, 1},
+		{"disjoint", a, Spectrum{{150, 10}, {250, 20}}, 0, 0},
+		{"unmatched in a", Spectrum{{100, 1}, {200, 1}}, Spectrum{{100, 1}}, 1 / math.Sqrt2, 0.6887218755408672},
+		{"unmatched in b", Spectrum{{100, 1}}, Spectrum{{100, 1}, {200, 1}}, 1 / math.Sqrt2, 0.6887218755408672},
+		{"empty", a, nil, 0, 0},
+	}
+	opts := SimilarityOptions{Tolerance: Da(0.01)}
+	for _, tt := range tests {
+		if got := DotProduct(tt.a, tt.b, opts); math.Abs(got-tt.dot) > 1e-9 {
+			t.Errorf("%s: DotProduct = %v, want %v", tt.name, got, tt.dot)
+		}
+		if got, want := SpectralContrastAngle(tt.a, tt.b, opts), math.Acos(tt.dot); math.Abs(got-want) > 1e-6 {
+			t.Errorf("%s: SpectralContrastAngle = %v, want %v", tt.name, got, want)
+		}
+		if got := EntropySimilarity(tt.a, tt.b, opts); math.Abs(got-tt.entropy) > 1e-9 {
+			t.Errorf("%s: EntropySimilarity = %v, want %v", tt.name, got, tt.entropy)
+		}
+	}
+}
+
+func TestMatchPeaks(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  Spectrum
+		pairs [][2]int
+	}{
+		//the window is closed, 100.5 is exactly representable
+		{"at the edge", Spectrum{{100, 1}}, Spectrum{{100.5, 1}}, [][2]int{{0, 0}}},
+		{"beyond the edge", Spectrum{{100, 1}}, Spectrum{{100.5000001, 1}}, [][2]int{{0, -1}, {-1, 0}}},
+		//both peaks of a are within the tolerance of the peak of b, the
+		//pair with the highest product of intensities wins
+		{"greedy", Spectrum{{100, 1}, {101, 10}}, Spectrum{{100.5, 10}}, [][2]int{{1, 0}, {0, -1}}},
+		//the more intense peak of b is taken even though it is farther away
+		{"intensity before distance", Spectrum{{100, 10}}, Spectrum{{99.9, 1}, {100.5, 5}}, [][2]int{{0, 1}, {-1, 0}}},
+		//every peak is used once, the second best pair is taken after the first
+		{"one to one", Spectrum{{100, 10}, {100.2, 5}}, Spectrum{{100.1, 10}, {100.3, 1}}, [][2]int{{0, 0}, {1, 1}}},
+	}
+	for _, tt := range tests {
+		var got [][2]int
+		for _, p := range MatchPeaks(tt.a, tt.b, Da(0.5)) {
+			got = append(got, [2]int{p.IndexA, p.IndexB})
+		}
+		if !reflect.DeepEqual(got, tt.pairs) {
+			t.Errorf("%s: pairs %v, want %v", tt.name, got, tt.pairs)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package ms
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Tolerance is an m/z matching window around a target, either
+//relative in ppm or absolute in Da
+type Tolerance struct {
+	Value float64
+	//PPM is true for a relative tolerance, otherwise Value is in Da
+	PPM bool
+}
+
+//PPM returns a relative tolerance of v parts per million
+func PPM(v float64) Tolerance {
+	return Tolerance{Value: v, PPM: true}
+}
+
+//Da returns an absolute tolerance of v Da (or Th)
+func Da(v float64) Tolerance {
+	return Tolerance{Value: v}
+}
+
+//ParseTolerance parses a tolerance like "10ppm" or "0.02Da", a bare
+//number is in ppm
+func ParseTolerance(s string) (Tolerance, error) {
+	v := strings.TrimSpace(s)
+	t := Tolerance{PPM: true}
+	switch lower := strings.ToLower(v); {
+	case strings.HasSuffix(lower, "ppm"):
+		v = v[:len(v)-3]
+	case strings.HasSuffix(lower, "da"), strings.HasSuffix(lower, "th"):
+		v, t.PPM = v[:len(v)-2], false
+	}
+	var err error
+	if t.Value, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil || t.Value < 0 {
+		return t, fmt.Errorf("ms: bad tolerance %q", s)
+	}
+	return t, nil
+}
+
+func (t Tolerance) String() string {
+	if t.PPM {
+		return strconv.FormatFloat(t.Value, 'g', -1, 64) + "ppm"
+	}
+	return strconv.FormatFloat(t.Value, 'g', -1, 64) + "Da"
+}
+
+//Delta returns the half width of the window around mz
+func (t Tolerance) Delta(mz float64) float64 {
+	if t.PPM {
+		return mz * t.Value * 1e-6
+	}
+	return t.Value
+}
+
+//Window returns the interval [mz-delta, mz+delta]
+func (t Tolerance) Window(mz float64) (min float64, max float64) {
+	d := t.Delta(mz)
+	return mz - d, mz + d
+}
+
+//Contains reports whether observed lies within the tolerance around target
+func (t Tolerance) Contains(target float64, observed float64) bool {
+	min, max := t.Window(target)
+	return observed >= min && observed <= max
+}
+
+//PPMError returns the error of the observed m/z relative to the target in ppm
+func PPMError(target float64, observed float64) float64 {
+	return (observed - target) / target * 1e6
+}
+
+//Interval returns the part of the spectrum with m/z in [minMz, maxMz].
+//The spectrum has to be sorted by m/z, the result shares its peaks.
+func (a Spectrum) Interval(minMz float64, maxMz float64) Spectrum {
+	lowi := sort.Search(len(a), func(i int) bool { return a[i].Mz >= minMz })
+	highi := sort.Search(len(a)-lowi, func(i int) bool { return a[i+lowi].Mz > maxMz })
+	return a[lowi : highi+lowi]
+}
+
+//Around returns the part of the sorted spectrum within the tolerance around mz
+func (a Spectrum) Around(mz float64, tol Tolerance) Spectrum {
+	return a.Interval(tol.Window(mz))
+}
+
+//MaxPeak returns the most intense peak of the spectrum,
+//the zero Peak if the spectrum is empty
+func (a Spectrum) MaxPeak() (max Peak) {
+	for _, peak := range a {
+		if peak.I >= max.I {
+			max = peak
+		}
+	}
+	return
+}
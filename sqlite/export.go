@@ -0,0 +1,330 @@
+/*Package sqlite exports a run to a self-contained SQLite database for ad-hoc SQL.
+
+  The package works on database/sql and needs a SQLite driver to be
+  registered, e.g. the pure-Go modernc.org/sqlite:
+      import _ "modernc.org/sqlite"
+      db, err := sql.Open("sqlite", "run.sqlite")
+      err = sqlite.Export(db, file, sqlite.Options{Peaks: true})
+
+  Schema version 2 (PRAGMA user_version and the schema_version key in metadata):
+      metadata    key/value pairs of the run metadata (ms.Metadata)
+      scans       one row per scan, retention times in minutes; the precursor
+                  columns hold the last precursor of MSn scans and are NULL
+                  in MS1 scans, charge, intensity and activation energy are
+                  NULL if unknown
+      precursors  all precursors of MSn scans, idx counting from 0
+      scan_index  the ScanIndexEntry of every scan of Thermo RAW files,
+                  with the absolute file offset of the scan data
+      scan_events the ScanEvent of every scan of Thermo RAW files: the raw
+                  preamble and the Hz to m/z conversion coefficients
+      trailer     the trailer extra of every scan of Thermo RAW files, such
+                  as the charge state and ion injection time, as the labels
+                  and values that Xcalibur shows
+      spectra     only with Options.Peaks: the peaks of every scan as two
+                  blobs, mz of little-endian float64 and intensity of
+                  little-endian float32 values
+  The scans table is indexed on rt and precursor_mz, the trailer on scan and key.
+*/
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/danhitchcock/ms"
+	"github.com/danhitchcock/ms/unthermo"
+)
+
+//SchemaVersion is the version of the schema in the package documentation.
+//It changes whenever tables or columns change.
+const SchemaVersion = 2
+
+//schema are the statements creating the tables
+var schema = []string{
+	`CREATE TABLE metadata (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+	`CREATE TABLE scans (
+		scan INTEGER PRIMARY KEY,
+		rt REAL NOT NULL,
+		ms_level INTEGER NOT NULL,
+		analyzer TEXT NOT NULL,
+		polarity TEXT NOT NULL,
+		centroided INTEGER NOT NULL,
+		precursor_mz REAL,
+		precursor_charge INTEGER,
+		precursor_intensity REAL,
+		activation TEXT,
+		activation_energy REAL,
+		tic REAL NOT NULL,
+		base_peak_mz REAL NOT NULL,
+		base_peak_intensity REAL NOT NULL,
+		low_mz REAL NOT NULL,
+		high_mz REAL NOT NULL,
+		peaks INTEGER NOT NULL,
+		filter TEXT NOT NULL
+	)`,
+	`CREATE INDEX scans_rt ON scans (rt)`,
+	`CREATE INDEX scans_precursor_mz ON scans (precursor_mz)`,
+	`CREATE TABLE precursors (
+		scan INTEGER NOT NULL REFERENCES scans (scan),
+		idx INTEGER NOT NULL,
+		mz REAL NOT NULL,
+		charge INTEGER,
+		intensity REAL,
+		activation TEXT,
+		activation_energy REAL,
+		PRIMARY KEY (scan, idx)
+	)`,
+	`CREATE INDEX precursors_mz ON precursors (mz)`,
+	`CREATE TABLE scan_index (
+		scan INTEGER PRIMARY KEY REFERENCES scans (scan),
+		offset INTEGER NOT NULL,
+		idx INTEGER NOT NULL,
+		scan_event INTEGER NOT NULL,
+		scan_segment INTEGER NOT NULL,
+		next INTEGER NOT NULL,
+		data_packet_size INTEGER NOT NULL,
+		time REAL NOT NULL,
+		total_current REAL NOT NULL,
+		base_intensity REAL NOT NULL,
+		base_mz REAL NOT NULL,
+		low_mz REAL NOT NULL,
+		high_mz REAL NOT NULL
+	)`,
+	`CREATE TABLE scan_events (
+		scan INTEGER PRIMARY KEY REFERENCES scans (scan),
+		preamble BLOB NOT NULL,
+		n_precursors INTEGER NOT NULL,
+		low_mz REAL NOT NULL,
+		high_mz REAL NOT NULL,
+		n_param INTEGER NOT NULL,
+		a REAL NOT NULL,
+		b REAL NOT NULL,
+		c REAL NOT NULL
+	)`,
+	`CREATE TABLE trailer (
+		scan INTEGER NOT NULL REFERENCES scans (scan),
+		key TEXT NOT NULL,
+		value TEXT NOT NULL
+	)`,
+	`CREATE INDEX trailer_scan ON trailer (scan, key)`,
+	`CREATE TABLE spectra (
+		scan INTEGER PRIMARY KEY REFERENCES scans (scan),
+		mz BLOB NOT NULL,
+		intensity BLOB NOT NULL
+	)`,
+}
+
+//Options controls what is exported
+type Options struct {
+	//Filter selects the exported scans
+	Filter ms.ScanFilter
+	//Peaks exports the spectra
+	Peaks bool
+}
+
+//Export creates the tables in the (empty) database and fills them with the
+//scans of the file, in one transaction
+func Export(db *sql.DB, file ms.Reader, opts Options) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := export(tx, file, opts); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func export(tx *sql.Tx, file ms.Reader, opts Options) error {
+	for _, s := range schema {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("PRAGMA user_version = " + strconv.Itoa(SchemaVersion)); err != nil {
+		return err
+	}
+	if err := exportMetadata(tx, file.Metadata()); err != nil {
+		return err
+	}
+
+	scanStmt, err := tx.Prepare(`INSERT INTO scans VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	precursorStmt, err := tx.Prepare(`INSERT INTO precursors VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	spectrumStmt, err := tx.Prepare(`INSERT INTO spectra VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	raw, _ := file.(*unthermo.File)
+	indexStmt, err := tx.Prepare(`INSERT INTO scan_index VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	eventStmt, err := tx.Prepare(`INSERT INTO scan_events VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	trailerStmt, err := tx.Prepare(`INSERT INTO trailer VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= file.NScans(); i++ {
+		scan := file.Scan(i)
+		if !opts.Filter.Matches(scan) {
+			continue
+		}
+		if scan.Number == 0 {
+			scan.Number = i
+		}
+		if _, err := scanStmt.Exec(scanRow(scan)...); err != nil {
+			return err
+		}
+		for j, p := range precursors(scan) {
+			charge, intensity, energy := precursorValues(p)
+			if _, err := precursorStmt.Exec(scan.Number, j, p.Mz, charge, intensity,
+				activation(p.Activation), energy); err != nil {
+				return err
+			}
+		}
+		if opts.Peaks {
+			mz, in := encodePeaks(scan.Spectrum)
+			if _, err := spectrumStmt.Exec(scan.Number, mz, in); err != nil {
+				return err
+			}
+		}
+		if raw == nil {
+			continue
+		}
+		e := raw.ScanIndexEntry(i)
+		if _, err := indexStmt.Exec(scan.Number, int64(e.Offset), e.Index, e.Scanevent, e.Scansegment, e.Next,
+			e.DataPacketSize, e.Time, e.Totalcurrent, e.Baseintensity, e.Basemz, e.Lowmz, e.Highmz); err != nil {
+			return err
+		}
+		ev := raw.ScanEvent(i)
+		if _, err := eventStmt.Exec(scan.Number, ev.Preamble[:], ev.Nprecursors, ev.MZrange[0].Lowmz, ev.MZrange[0].Highmz,
+			ev.Nparam, ev.A, ev.B, ev.C); err != nil {
+			return err
+		}
+		for _, t := range raw.Trailer(i) {
+			if _, err := trailerStmt.Exec(scan.Number, t.Label, t.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func exportMetadata(tx *sql.Tx, meta ms.Metadata) error {
+	values := [][2]string{
+		{"schema_version", strconv.Itoa(SchemaVersion)},
+		{"source_file", meta.SourceFile},
+		{"file_format", meta.FileFormat},
+		{"instrument_vendor", meta.InstrumentVendor},
+		{"instrument_model", meta.InstrumentModel},
+		{"instrument_serial", meta.InstrumentSerial},
+		{"software_version", meta.SoftwareVersion},
+		{"sample_id", meta.SampleID},
+		{"sample_comment", meta.SampleComment},
+		{"instrument_method", meta.InstrumentMethod},
+		{"start_time", ftoa(meta.StartTime)},
+		{"end_time", ftoa(meta.EndTime)},
+		{"low_mz", ftoa(meta.LowMz)},
+		{"high_mz", ftoa(meta.HighMz)},
+	}
+	if !meta.AcquisitionDate.IsZero() {
+		values = append(values, [2]string{"acquisition_date", meta.AcquisitionDate.Format(time.RFC3339)})
+	}
+	for _, v := range values {
+		if _, err := tx.Exec(`INSERT INTO metadata VALUES (?, ?)`, v[0], v[1]); err != nil {
+			return fmt.Errorf("metadata %s: %v", v[0], err)
+		}
+	}
+	return nil
+}
+
+//precursors returns the precursors of the scan, also if only PrecursorMzs is set
+func precursors(scan ms.Scan) []ms.Precursor {
+	if len(scan.Precursors) > 0 {
+		return scan.Precursors
+	}
+	p := make([]ms.Precursor, len(scan.PrecursorMzs))
+	for i, mz := range scan.PrecursorMzs {
+		p[i].Mz = mz
+	}
+	return p
+}
+
+//scanRow returns the values of the scan in the scans table
+func scanRow(scan ms.Scan) []interface{} {
+	var mz, charge, intensity, act, energy interface{}
+	if ps := precursors(scan); len(ps) > 0 {
+		p := ps[len(ps)-1]
+		mz, act = p.Mz, activation(p.Activation)
+		charge, intensity, energy = precursorValues(p)
+	}
+	return []interface{}{
+		scan.Number, scan.Time, int(scan.MSLevel), scan.Analyzer.String(), scan.Polarity.String(), scan.Centroided,
+		mz, charge, intensity, act, energy,
+		scan.TotalCurrent, scan.BasePeak.Mz, float64(scan.BasePeak.I), scan.LowMz, scan.HighMz,
+		len(scan.Spectrum), scan.Filter,
+	}
+}
+
+//encodePeaks returns the m/z values as little-endian float64 and the
+//intensities as little-endian float32
+func encodePeaks(s ms.Spectrum) (mz []byte, in []byte) {
+	mz = make([]byte, 8*len(s))
+	in = make([]byte, 4*len(s))
+	for i, p := range s {
+		binary.LittleEndian.PutUint64(mz[8*i:], math.Float64bits(p.Mz))
+		binary.LittleEndian.PutUint32(in[4*i:], math.Float32bits(p.I))
+	}
+	return
+}
+
+//DecodePeaks is the inverse of the blob encoding of the spectra table
+func DecodePeaks(mz []byte, in []byte) (ms.Spectrum, error) {
+	if len(mz)%8 != 0 || len(mz)/8 != len(in)/4 || len(in)%4 != 0 {
+		return nil, fmt.Errorf("sqlite: %d bytes of m/z and %d of intensities", len(mz), len(in))
+	}
+	s := make(ms.Spectrum, len(mz)/8)
+	for i := range s {
+		s[i].Mz = math.Float64frombits(binary.LittleEndian.Uint64(mz[8*i:]))
+		s[i].I = math.Float32frombits(binary.LittleEndian.Uint32(in[4*i:]))
+	}
+	return s, nil
+}
+
+//precursorValues returns the charge, intensity and activation energy of the
+//precursor, NULL if they have the unknown value of ms.Precursor
+func precursorValues(p ms.Precursor) (charge sql.NullInt64, intensity, energy sql.NullFloat64) {
+	charge = sql.NullInt64{Int64: int64(p.Charge), Valid: p.Charge != 0}
+	intensity = sql.NullFloat64{Float64: float64(p.Intensity), Valid: p.Intensity != 0}
+	energy = sql.NullFloat64{Float64: p.Energy, Valid: p.Energy != 0}
+	return
+}
+
+func activation(a ms.Activation) interface{} {
+	if a == ms.UnknownActivation {
+		return nil
+	}
+	return a.String()
+}
+
+func ftoa(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/danhitchcock/ms"
+	_ "modernc.org/sqlite"
+)
+
+//memReader is an ms.Reader of scans in memory
+type memReader struct {
+	meta  ms.Metadata
+	scans []ms.Scan
+}
+
+func (r memReader) NScans() int                      { return len(r.scans) }
+func (r memReader) Scan(sn int) ms.Scan              { return r.scans[sn-1] }
+func (r memReader) Spectrum(sn int) ms.Spectrum      { return r.scans[sn-1].Spectrum }
+func (r memReader) Metadata() ms.Metadata            { return r.meta }
+func (r memReader) Chromatograms() []ms.Chromatogram { return nil }
+func (r memReader) Close() error                     { return nil }
+
+var testRun = memReader{
+	meta: ms.Metadata{FileFormat: "mzML", InstrumentModel: "test", StartTime: 1, EndTime: 1.2},
+	scans: []ms.Scan{
+		{MSLevel: 1, Time: 1, Polarity: ms.Positive, Analyzer: ms.FTMS, Centroided: true, TotalCurrent: 30,
+			BasePeak: ms.Peak{Mz: 500.25, I: 20}, LowMz: 400, HighMz: 1600, Filter: "FTMS + c Full ms",
+			Spectrum: ms.Spectrum{{Mz: 450.125, I: 10}, {Mz: 500.25, I: 20}}},
+		{Number: 2, MSLevel: 2, Time: 1.1, Polarity: ms.Positive, Analyzer: ms.ITMS, Centroided: true,
+			PrecursorMzs: []float64{500.25},
+			Precursors:   []ms.Precursor{{Mz: 500.25, Charge: 2, Intensity: 20, Activation: ms.CID, Energy: 35}},
+			Spectrum:     ms.Spectrum{{Mz: 200.5, I: 1.5}}},
+		{Number: 3, MSLevel: 3, Time: 1.2, Analyzer: ms.FTMS,
+			PrecursorMzs: []float64{500.25, 200.5}},
+	},
+}
+
+//exportTemp exports the test run to an in-memory database
+func exportTemp(t *testing.T, opts Options) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every connection has its own in-memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if err := Export(db, testRun, opts); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestExport(t *testing.T) {
+	db := exportTemp(t, Options{Peaks: true})
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != SchemaVersion {
+		t.Errorf("user_version is %d (%v), want %d", version, err, SchemaVersion)
+	}
+	meta := make(map[string]string)
+	rows, err := db.Query("SELECT key, value FROM metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			t.Fatal(err)
+		}
+		meta[k] = v
+	}
+	rows.Close()
+	for k, v := range map[string]string{"schema_version": "2", "file_format": "mzML", "instrument_model": "test", "end_time": "1.2"} {
+		if meta[k] != v {
+			t.Errorf("metadata %s is %q, want %q", k, meta[k], v)
+		}
+	}
+
+	type scanRow struct {
+		Scan               int
+		RT                 float64
+		MSLevel            int
+		Analyzer, Polarity string
+		Centroided         bool
+		PrecursorMz        sql.NullFloat64
+		PrecursorCharge    sql.NullInt64
+		PrecursorIntensity sql.NullFloat64
+		Activation         sql.NullString
+		ActivationEnergy   sql.NullFloat64
+		TIC, BasePeakMz    float64
+		BasePeakIntensity  float64
+		LowMz, HighMz      float64
+		Peaks              int
+		Filter             string
+	}
+	want := []scanRow{
+		{1, 1, 1, "FTMS", "+", true, sql.NullFloat64{}, sql.NullInt64{}, sql.NullFloat64{}, sql.NullString{}, sql.NullFloat64{},
+			30, 500.25, 20, 400, 1600, 2, "FTMS + c Full ms"},
+		{2, 1.1, 2, "ITMS", "+", true, sql.NullFloat64{Float64: 500.25, Valid: true}, sql.NullInt64{Int64: 2, Valid: true},
+			sql.NullFloat64{Float64: 20, Valid: true}, sql.NullString{String: "CID", Valid: true}, sql.NullFloat64{Float64: 35, Valid: true},
+			0, 0, 0, 0, 0, 1, ""},
+		{3, 1.2, 3, "FTMS", "", false, sql.NullFloat64{Float64: 200.5, Valid: true}, sql.NullInt64{}, sql.NullFloat64{}, sql.NullString{}, sql.NullFloat64{},
+			0, 0, 0, 0, 0, 0, ""},
+	}
+	rows, err = db.Query("SELECT * FROM scans ORDER BY scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []scanRow
+	for rows.Next() {
+		var r scanRow
+		if err := rows.Scan(&r.Scan, &r.RT, &r.MSLevel, &r.Analyzer, &r.Polarity, &r.Centroided,
+			&r.PrecursorMz, &r.PrecursorCharge, &r.PrecursorIntensity, &r.Activation, &r.ActivationEnergy,
+			&r.TIC, &r.BasePeakMz, &r.BasePeakIntensity, &r.LowMz, &r.HighMz, &r.Peaks, &r.Filter); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	rows.Close()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scans are\n%+v\nwant\n%+v", got, want)
+	}
+
+	//the MS3 scan has both precursors
+	var n int
+	var mz float64
+	if err := db.QueryRow("SELECT count(*), max(mz) FROM precursors WHERE scan = 3").Scan(&n, &mz); err != nil || n != 2 || mz != 500.25 {
+		t.Errorf("scan 3 has %d precursors up to %v (%v)", n, mz, err)
+	}
+	var idx int
+	if err := db.QueryRow("SELECT idx FROM precursors WHERE scan = 3 AND mz = 200.5").Scan(&idx); err != nil || idx != 1 {
+		t.Errorf("precursor 200.5 of scan 3 is at %d (%v)", idx, err)
+	}
+
+	for sn, s := range testRun.scans {
+		var mzs, is []byte
+		if err := db.QueryRow("SELECT mz, intensity FROM spectra WHERE scan = ?", sn+1).Scan(&mzs, &is); err != nil {
+			t.Fatal(err)
+		}
+		peaks, err := DecodePeaks(mzs, is)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(peaks) != len(s.Spectrum) || len(peaks) > 0 && !reflect.DeepEqual(peaks, s.Spectrum) {
+			t.Errorf("spectrum of scan %d is %v, want %v", sn+1, peaks, s.Spectrum)
+		}
+	}
+	//the RAW tables are empty for other formats
+	for _, table := range []string{"scan_index", "scan_events", "trailer"} {
+		if err := db.QueryRow("SELECT count(*) FROM " + table).Scan(&n); err != nil || n != 0 {
+			t.Errorf("%s has %d rows (%v)", table, n, err)
+		}
+	}
+}
+
+func TestExportFilter(t *testing.T) {
+	db := exportTemp(t, Options{Filter: ms.ScanFilter{MSLevel: 2}})
+	var n, scan int
+	if err := db.QueryRow("SELECT count(*), max(scan) FROM scans").Scan(&n, &scan); err != nil || n != 1 || scan != 2 {
+		t.Errorf("%d scans up to %d (%v), want scan 2", n, scan, err)
+	}
+	if err := db.QueryRow("SELECT count(*) FROM spectra").Scan(&n); err != nil || n != 0 {
+		t.Errorf("%d spectra without Options.Peaks (%v)", n, err)
+	}
+}
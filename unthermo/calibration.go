@@ -0,0 +1,82 @@
+package unthermo
+
+import (
+	"errors"
+	"fmt"
+)
+
+//ErrUnsupportedCalibration is returned for scans of which the
+//frequency to m/z conversion model is not known
+var ErrUnsupportedCalibration = errors.New("unsupported calibration model")
+
+//Calibration is the conversion of the profile axis of a scan to m/z.
+//Nparam identifies the model:
+//  0     the axis is in m/z already (e.g. ion trap scans)
+//  4     FT-ICR (LTQ FT): m/z = A + B/f + C/f²
+//  5, 7  Orbitrap: m/z = A + B/f² + C/f⁴
+type Calibration struct {
+	Nparam uint32
+	A      float64
+	B      float64
+	C      float64
+	//Correction is the m/z error that Recalibrate found for the scan,
+	//it is removed after conversion
+	Correction Correction
+}
+
+//Check returns ErrUnsupportedCalibration if the model is not known
+func (c Calibration) Check() error {
+	_, err := ConvertMz(1, c.Nparam, c.A, c.B, c.C)
+	return err
+}
+
+//Convert converts a value of the profile axis (a frequency in Hz for FT
+//instruments) to m/z. Check has to pass, other models give NaN.
+func (c Calibration) Convert(v float64) float64 {
+	mz, _ := ConvertMz(v, c.Nparam, c.A, c.B, c.C)
+	return c.Correction.Apply(mz)
+}
+
+//Calibration returns the calibration of the scan at the scan number
+func (rf *File) Calibration(sn int) (c Calibration, err error) {
+	if sn < 1 || sn > rf.NScans() {
+		err = fmt.Errorf("scan number %d is out of bounds [1, %d]", sn, rf.NScans())
+		return
+	}
+	event := rf.scanevents[sn-1]
+	c = Calibration{Nparam: event.Nparam, A: event.A, B: event.B, C: event.C}
+	c.Correction = rf.correction(sn)
+	return
+}
+
+//RawProfile is the profile of a scan as it is stored: bins on an equidistant
+//axis (in Hz for FT instruments) that start at FirstValue and are Step apart.
+//The bin i of a chunk is at FirstValue + (Firstbin+i)*Step + Fudge.
+type RawProfile struct {
+	FirstValue  float64
+	Step        float64
+	Chunks      []ProfileChunk
+	Calibration Calibration
+}
+
+//Value returns the axis value of bin i of a chunk
+func (p RawProfile) Value(chunk ProfileChunk, i uint32) float64 {
+	return p.FirstValue + float64(chunk.Firstbin+i)*p.Step + float64(chunk.Fudge)
+}
+
+//RawProfile returns the unconverted profile of the scan at the scan number.
+//The chunk signals refer to the file in memory and must not be modified.
+func (rf *File) RawProfile(sn int) (p RawProfile, err error) {
+	if p.Calibration, err = rf.Calibration(sn); err != nil {
+		return
+	}
+	scn := rf.packet(sn)
+	if scn.Header.ProfileSize == 0 {
+		err = fmt.Errorf("scan %d has no profile data", sn)
+		return
+	}
+	p.FirstValue = scn.Profile.FirstValue
+	p.Step = scn.Profile.Step
+	p.Chunks = scn.Profile.Chunks
+	return
+}
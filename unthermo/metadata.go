@@ -0,0 +1,119 @@
+package unthermo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/danhitchcock/ms"
+)
+
+//Metadata returns the file, sample and instrument information of the run
+func (rf *File) Metadata() ms.Metadata {
+	p := rf.info.Preamble
+	return ms.Metadata{
+		SourceFile:       rf.filename,
+		FileFormat:       "Thermo RAW",
+		InstrumentVendor: "Thermo Scientific",
+		InstrumentModel:  rf.runheader.Model.String(),
+		InstrumentSerial: rf.runheader.SN.String(),
+		SoftwareVersion:  rf.runheader.SWVer.String(),
+		AcquisitionDate: time.Date(int(p.Year), time.Month(p.Month), int(p.Day),
+			int(p.Hour), int(p.Minute), int(p.Second), int(p.Millisecond)*int(time.Millisecond), time.Local),
+		SampleID:         rf.sequencer.ID.String(),
+		SampleComment:    rf.sequencer.Comment.String(),
+		InstrumentMethod: rf.sequencer.Instmethod.String(),
+		StartTime:        rf.runheader.SampleInfo.Starttime,
+		EndTime:          rf.runheader.SampleInfo.Endtime,
+		LowMz:            rf.runheader.SampleInfo.Lowmz,
+		HighMz:           rf.runheader.SampleInfo.Highmz,
+	}
+}
+
+//Version returns the version of the RAW file format
+func (rf *File) Version() Version {
+	return rf.version
+}
+
+//MethodEmbedded reports whether the instrument method is stored in the RAW file
+func (rf *File) MethodEmbedded() bool {
+	return rf.info.Preamble.Methodfilepresent != 0
+}
+
+//Controller is a device that recorded data during the run, such as the mass
+//spectrometer or a UV detector
+type Controller struct {
+	Device string
+	Model  string
+	Serial string
+	//MS is true for the mass spectrometer, the controller of the scans
+	MS        bool
+	FirstScan int
+	LastScan  int
+}
+
+//Controllers returns the devices of which the run headers are in the file
+func (rf *File) Controllers() []Controller {
+	var cs []Controller
+	for _, addr := range rf.info.Preamble.RunHeaderAddr {
+		rh := new(RunHeader)
+		readAt(rf.f, addr, rf.version, rh)
+		cs = append(cs, Controller{
+			Device:    rh.Device.String(),
+			Model:     rh.Model.String(),
+			Serial:    rh.SN.String(),
+			MS:        rh.ScantrailerAddr != 0,
+			FirstScan: int(rh.SampleInfo.FirstScanNumber),
+			LastScan:  int(rh.SampleInfo.LastScanNumber),
+		})
+	}
+	return cs
+}
+
+//Chromatograms returns the total ion current and base peak chromatograms
+//of all scans, as they are stored in the scan index
+func (rf *File) Chromatograms() []ms.Chromatogram {
+	tic := ms.Chromatogram{ID: "TIC", Type: ms.TIC, Points: make([]ms.ChromatogramPoint, rf.NScans())}
+	bpc := ms.Chromatogram{ID: "BPC", Type: ms.BPC, Points: make([]ms.ChromatogramPoint, rf.NScans())}
+	for i, entry := range rf.scanindex {
+		tic.Points[i] = ms.ChromatogramPoint{Time: entry.Time, I: entry.Totalcurrent, ScanNumber: i + 1}
+		bpc.Points[i] = ms.ChromatogramPoint{Time: entry.Time, I: entry.Baseintensity, Mz: entry.Basemz, ScanNumber: i + 1}
+	}
+	return []ms.Chromatogram{tic, bpc}
+}
+
+//filter returns a description of the scan event in the style of a Thermo filter
+//line, e.g. "FTMS + p d Full ms2 445.1200 [110.00-2000.00]". The activation
+//method is not decoded from the scan event, so the precursors lack it.
+func (data ScanEvent) filter() string {
+	var b strings.Builder
+	b.WriteString(ms.Analyzer(data.Preamble[40]).String())
+	switch data.Preamble[4] {
+	case 0:
+		b.WriteString(" -")
+	case 1:
+		b.WriteString(" +")
+	}
+	if data.Preamble[5] == 0 {
+		b.WriteString(" c")
+	} else {
+		b.WriteString(" p")
+	}
+	if data.Preamble[10] == 1 {
+		b.WriteString(" d")
+	}
+
+	level := data.Preamble[6]
+	if level <= 1 {
+		b.WriteString(" Full ms")
+	} else {
+		fmt.Fprintf(&b, " Full ms%d", level)
+	}
+	for _, r := range data.Reaction {
+		fmt.Fprintf(&b, " %.4f", r.Precursormz)
+	}
+	if r := data.MZrange[0]; r.Highmz > 0 {
+		fmt.Fprintf(&b, " [%.2f-%.2f]", r.Lowmz, r.Highmz)
+	}
+	return b.String()
+}
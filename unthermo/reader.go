@@ -6,12 +6,14 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"math"
 	"os"
 	"reflect"
+	"sort"
 	"unicode/utf16"
 	"unsafe"
 
@@ -28,9 +30,36 @@ type File struct {
 	//scanindexentries is an index containing the scan addresses and additional info
 	//such as retention time and total current
 	scanindex ScanIndex
+	//corrections holds the m/z correction of every scan that
+	//Recalibrate found, nil if the file is not recalibrated
+	corrections []Correction
+	//trailer is the layout of the trailer extra, empty if it was not found
+	trailer trailer
+	//the headers with file, sample and instrument information
+	filename  string
+	version   Version
+	info      RawFileInfo
+	sequencer SequencerRow
+	runheader RunHeader
 	Scans     []ms.Scan
 }
 
+func init() {
+	ms.RegisterFormat("Thermo RAW", isRaw, func(fn string) (ms.Reader, error) {
+		file, err := Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		return &file, nil
+	})
+}
+
+//isRaw reports whether the file starts with the Finnigan file header
+func isRaw(head []byte) bool {
+	signature := []byte("F\x00i\x00n\x00n\x00i\x00g\x00a\x00n\x00")
+	return len(head) >= 18 && head[0] == 0x01 && head[1] == 0xa1 && bytes.Equal(head[2:18], signature)
+}
+
 //Open opens the supplied filename and reads the indices from the RAW file in memory. Multiple files may be read concurrently.
 func Open(fn string) (file File, err error) {
 	f, err := os.Open(fn)
@@ -42,7 +71,7 @@ func Open(fn string) (file File, err error) {
 	b, _ := ioutil.ReadFile(fn)
 	// b := []byte{0}
 	//Read headers for file version and RunHeader addresses.
-	info, ver := readHeaders(f)
+	info, ver, seq := readHeaders(f)
 	rh := new(RunHeader)
 
 	//read runheaders until we have a non-empty Scantrailer Address
@@ -67,11 +96,13 @@ func Open(fn string) (file File, err error) {
 	readBetween(f, b, rh.ScanindexAddr, rh.ScantrailerAddr, ver, scanindex)
 
 	//make the offsets absolute in the file instead of relative to the data address
-	rf := File{f: f, b: b, scanevents: scanevents, scanindex: scanindex}
+	rf := File{f: f, b: b, scanevents: scanevents, scanindex: scanindex,
+		filename: fn, version: ver, info: info, sequencer: seq, runheader: *rh}
 
 	for i := range scanindex {
 		scanindex[i].Offset += rh.DataAddr
 	}
+	rf.trailer, _ = readTrailer(b, rh, rf.NScans())
 	rf.Scans = make([]ms.Scan, rf.NScans())
 	rf.makeScans()
 	return rf, err
@@ -107,17 +138,83 @@ func (rf *File) Scan(sn int) (scan ms.Scan) {
 		log.Print("Scan Number ", sn, " is out of bounds [1, ", rf.NScans(), "]")
 		return
 	}
-	scan.Time = rf.scanindex[sn-1].Time
-	scan.MSLevel = rf.scanevents[sn-1].Preamble[6]
-	scan.Analyzer = ms.Analyzer(rf.scanevents[sn-1].Preamble[40])
-
-	scan.PrecursorMzs = make([]float64, len(rf.scanevents[sn-1].Reaction))
-	for j := range rf.scanevents[sn-1].Reaction {
-		scan.PrecursorMzs[j] = rf.scanevents[sn-1].Reaction[j].Precursormz
+	scan = rf.header(sn)
+	var profile bool
+	var err error
+	if scan.Spectrum, profile, err = rf.readSpectrum(sn, false); err != nil {
+		log.Print("Scan ", sn, ": ", err)
+	}
+	scan.Centroided = !profile
+	if len(scan.Precursors) > 0 {
+		scan.Precursors[0].Intensity = rf.precursorIntensity(sn, scan.Precursors[0].Mz)
 	}
-	scan.Spectrum = rf.spectrum(sn)
 	rf.Scans[sn-1] = scan
-	//scan.Spectrum = func() ms.Spectrum { return rf.spectrum(sn) }
+	return
+}
+
+//PrecursorTolerance is the window around the precursor m/z in which its
+//intensity is looked up in the MS1 scan before an MSx scan
+var PrecursorTolerance = ms.PPM(10)
+
+//precursorIntensity returns the intensity of the most intense peak around
+//the precursor m/z in the last MS1 scan before the scan, 0 if there is none
+func (rf *File) precursorIntensity(sn int, mz float64) float32 {
+	for k := sn - 1; k >= 1; k-- {
+		if rf.scanevents[k-1].Preamble[6] != 1 {
+			continue
+		}
+		//the MS1 scans before were read by Open
+		spectrum := rf.Scans[k-1].Spectrum
+		if rf.Scans[k-1].Number != k {
+			spectrum, _, _ = rf.readSpectrum(k, false)
+		}
+		return spectrum.Around(mz, PrecursorTolerance).MaxPeak().I
+	}
+	return 0
+}
+
+//header returns the scan at the scan number without reading its spectrum.
+//Whether it is centroided is taken from the scan event.
+func (rf *File) header(sn int) (scan ms.Scan) {
+	event := rf.scanevents[sn-1]
+	entry := rf.scanindex[sn-1]
+	scan.Number = sn
+	scan.Time = entry.Time
+	scan.TotalCurrent = entry.Totalcurrent
+	scan.BasePeak = ms.Peak{Mz: entry.Basemz, I: float32(entry.Baseintensity)}
+	scan.LowMz = entry.Lowmz
+	scan.HighMz = entry.Highmz
+	scan.MSLevel = event.Preamble[6]
+	scan.Analyzer = ms.Analyzer(event.Preamble[40])
+	scan.Centroided = event.Preamble[5] == 0
+	switch event.Preamble[4] {
+	case 0:
+		scan.Polarity = ms.Negative
+	case 1:
+		scan.Polarity = ms.Positive
+	}
+
+	scan.PrecursorMzs = make([]float64, len(event.Reaction))
+	scan.Precursors = make([]ms.Precursor, len(event.Reaction))
+	for j, reaction := range event.Reaction {
+		scan.PrecursorMzs[j] = reaction.Precursormz
+		//the activation method is not decoded, it stays unknown
+		scan.Precursors[j] = ms.Precursor{Mz: reaction.Precursormz, Energy: reaction.Energy}
+	}
+	//the trailer extra has the charge and monoisotopic m/z of the ion that
+	//was isolated first, PrecursorMzs keep the isolation targets
+	if len(scan.Precursors) > 0 {
+		if z, ok := rf.trailer.value(rf.b, sn, labelCharge); ok {
+			scan.Precursors[0].Charge = int(z)
+		}
+		if mz, ok := rf.trailer.value(rf.b, sn, labelMonoisotopic); ok && mz > 0 {
+			scan.Precursors[0].Mz = mz
+		}
+	}
+	if t, ok := rf.trailer.value(rf.b, sn, labelInjectionTime); ok {
+		scan.InjectionTime = t
+	}
+	scan.Filter = event.filter()
 	return
 }
 
@@ -126,88 +223,338 @@ func (rf *File) NScans() int {
 	return len(rf.scanindex)
 }
 
-// Computes mean spectrum from profile data
-func (rf *File) ComputeMeanSpectrum() (s ms.Spectrum) {
-	n := rf.NScans()
-	var total []float32
-	scn := new(ScanDataPacket)
-	for i := 0; i < n; i++ {
-		info := rf.scanindex[i]
-		if len(total) == 0 {
-			// assume that the number of bins is the same for all events
-			total = make([]float32, int(scn.Profile.Nbins))
+//ScanIndexEntry returns the index entry of the scan, its Offset is absolute in the file
+func (rf *File) ScanIndexEntry(sn int) ScanIndexEntry {
+	return rf.scanindex[sn-1]
+}
+
+//ScanEvent returns the scan event of the scan
+func (rf *File) ScanEvent(sn int) ScanEvent {
+	return rf.scanevents[sn-1]
+}
+
+//The default grids of ComputeMeanSpectrum and AverageSpectrum span the m/z
+//range of the scans. For profile scans the bins are as wide as the median
+//spacing of the profile points of the first scan. Centroids are binned by
+//CentroidGridPPM for FT and TOF analyzers, which is wider than their mass
+//errors, and by CentroidGridDa for the others.
+const (
+	CentroidGridPPM = 10
+	CentroidGridDa  = 0.1
+)
+
+//ComputeMeanSpectrum computes the mean spectrum of the profile scans, or of
+//the centroided scans if there are none, on the default grid
+func (rf *File) ComputeMeanSpectrum() ms.Spectrum {
+	scans := rf.meanScans()
+	grid, err := rf.defaultGrid(scans)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return rf.MeanSpectrum(grid)
+}
+
+//MeanSpectrum computes the mean spectrum of the profile scans, or of the
+//centroided scans if there are none, on the supplied grid.
+//See AverageSpectrum for selecting scans.
+func (rf *File) MeanSpectrum(grid ms.Grid) ms.Spectrum {
+	acc, err := rf.accumulate(grid, rf.meanScans())
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return acc.Mean()
+}
+
+//meanScans returns the numbers of the profile scans, or of all scans if none has profile data
+func (rf *File) meanScans() []int {
+	var profile []int
+	for sn := 1; sn <= rf.NScans(); sn++ {
+		if !rf.header(sn).Centroided {
+			profile = append(profile, sn)
 		}
-		scn.Read(rf.b[info.Offset:info.Offset+uint64(info.DataPacketSize)], 0)
-		for i := uint32(0); i < scn.Profile.PeakCount; i++ {
-			for j := uint32(0); j < scn.Profile.Chunks[i].Nbins; j++ {
-				bin := scn.Profile.Chunks[i].Firstbin + j
-				if int(bin) < len(total) {
-					total[bin] += scn.Profile.Chunks[i].Signal[j] / float32(n)
-				}
+	}
+	if len(profile) == 0 {
+		return rf.scanNumbers()
+	}
+	return profile
+}
+
+//AverageOptions select the scans that AverageSpectrum combines
+type AverageOptions struct {
+	//Filter selects the scans by MS level, analyzer, polarity and retention time
+	Filter ms.ScanFilter
+	//Scans restricts the selection to these scan numbers if it is not empty
+	Scans []int
+	//Sum adds the spectra instead of averaging them
+	Sum bool
+	//BackgroundStart and BackgroundEnd is a retention time range (in minutes)
+	//of which the mean spectrum, of the scans that pass Filter otherwise,
+	//is subtracted from every selected scan. It is not used if both are 0.
+	BackgroundStart float64
+	BackgroundEnd   float64
+	//Grid is the m/z axis of the result. If it is nil, the default grid
+	//(see CentroidGridPPM) spans the m/z range of the selected scans.
+	Grid ms.Grid
+}
+
+//AverageSpectrum combines the spectra of the selected scans into one spectrum,
+//as is done when inspecting an eluting compound. Profile scans are interpolated
+//and centroided scans are summed on the grid, intensities that become negative
+//by background subtraction are set to 0. The selection, including the
+//background, has to be either all profile or all centroided scans.
+func (rf *File) AverageSpectrum(opts AverageOptions) (ms.Spectrum, error) {
+	candidates := opts.Scans
+	if len(candidates) == 0 {
+		candidates = rf.scanNumbers()
+	}
+
+	var scans []int
+	for _, sn := range candidates {
+		if sn < 1 || sn > rf.NScans() {
+			return nil, fmt.Errorf("scan number %d is out of bounds [1, %d]", sn, rf.NScans())
+		}
+		if opts.Filter.Matches(rf.header(sn)) {
+			scans = append(scans, sn)
+		}
+	}
+	if len(scans) == 0 {
+		return nil, errors.New("no scans match the selection")
+	}
+
+	var background []int
+	if opts.BackgroundStart != 0 || opts.BackgroundEnd != 0 {
+		filter := opts.Filter
+		filter.MinTime, filter.MaxTime = opts.BackgroundStart, opts.BackgroundEnd
+		for sn := 1; sn <= rf.NScans(); sn++ {
+			if filter.Matches(rf.header(sn)) {
+				background = append(background, sn)
 			}
 		}
+		if len(background) == 0 {
+			return nil, errors.New("no scans in the background range")
+		}
 	}
 
-	// albeit axes are slightly different for scan events, here the last one is used for simplicity
-	for i := 0; i < len(total); i++ {
-		s = append(s,
-			ms.Peak{Mz: rf.scanevents[n-1].Convert(scn.Profile.FirstValue + scn.Profile.Step*float64(i)),
-				I: total[i]})
+	grid := opts.Grid
+	if grid == nil {
+		var err error
+		if grid, err = rf.defaultGrid(append(scans, background...)); err != nil {
+			return nil, err
+		}
+	}
+
+	acc, err := rf.accumulate(grid, scans)
+	if err != nil {
+		return nil, err
+	}
+	if len(background) > 0 {
+		//subtract the mean background from every selected scan
+		bg, err := rf.accumulate(grid, background)
+		if err != nil {
+			return nil, err
+		}
+		scale := float64(acc.N) / float64(bg.N)
+		for i := range acc.Total {
+			acc.Total[i] = math.Max(0, acc.Total[i]-scale*bg.Total[i])
+		}
+	}
+	if opts.Sum {
+		return acc.Sum(), nil
+	}
+	return acc.Mean(), nil
+}
+
+//scanNumbers returns all scan numbers in the file
+func (rf *File) scanNumbers() []int {
+	scans := make([]int, rf.NScans())
+	for i := range scans {
+		scans[i] = i + 1
+	}
+	return scans
+}
+
+//mzRange returns the m/z range that the scans span according to the scan index
+func (rf *File) mzRange(scans []int) (lowmz float64, highmz float64) {
+	for i, sn := range scans {
+		entry := rf.scanindex[sn-1]
+		if i == 0 || entry.Lowmz < lowmz {
+			lowmz = entry.Lowmz
+		}
+		if entry.Highmz > highmz {
+			highmz = entry.Highmz
+		}
 	}
 	return
 }
 
-//Spectrum returns an ms.Spectrum belonging to the scan number in argument
-func (rf *File) spectrum(sn int) (s ms.Spectrum) {
-	//read Scan Packet for the scan
+//centroided reports whether the scans are centroided, and gives an error if
+//they mix profile and centroided scans, which cannot be combined on one grid
+func (rf *File) centroided(scans []int) (bool, error) {
+	if len(scans) == 0 {
+		return false, nil
+	}
+	first := rf.header(scans[0]).Centroided
+	for _, sn := range scans[1:] {
+		if rf.header(sn).Centroided != first {
+			return false, fmt.Errorf("scans %d and %d are not both profile or centroided, select one kind", scans[0], sn)
+		}
+	}
+	return first, nil
+}
+
+//defaultGrid returns the grid that spans the m/z range of the scans, with
+//bins of the width described at CentroidGridPPM
+func (rf *File) defaultGrid(scans []int) (ms.Grid, error) {
+	lowmz, highmz := rf.mzRange(scans)
+	if lowmz <= 0 || highmz <= lowmz {
+		return nil, errors.New("the selected scans have no m/z range")
+	}
+	centroided, err := rf.centroided(scans)
+	if err != nil {
+		return nil, err
+	}
+	if centroided {
+		switch rf.header(scans[0]).Analyzer {
+		case ms.FTMS, ms.TOFMS:
+			return ms.PPMGrid(lowmz, highmz, CentroidGridPPM), nil
+		}
+		return ms.LinearGrid(lowmz, highmz, CentroidGridDa), nil
+	}
+
+	//the median relative spacing of the profile points, the gaps between
+	//the chunks are too few to matter
+	s, _, err := rf.readSpectrum(scans[0], false)
+	if err != nil {
+		return nil, fmt.Errorf("scan %d: %w", scans[0], err)
+	}
+	var spacings []float64
+	for i := 1; i < len(s); i++ {
+		if d := s[i].Mz - s[i-1].Mz; d > 0 {
+			spacings = append(spacings, d/s[i-1].Mz*1e6)
+		}
+	}
+	if len(spacings) == 0 {
+		return nil, fmt.Errorf("scan %d has no profile points", scans[0])
+	}
+	sort.Float64s(spacings)
+	return ms.PPMGrid(lowmz, highmz, spacings[len(spacings)/2]), nil
+}
+
+//accumulate adds the spectra of the scans on the grid. Every scan is converted
+//to m/z with its own calibration, profile scans are interpolated at the bin
+//centers and centroided scans are summed per bin.
+func (rf *File) accumulate(grid ms.Grid, scans []int) (*ms.Accumulator, error) {
+	centroided, err := rf.centroided(scans)
+	if err != nil {
+		return nil, err
+	}
+	acc := ms.NewAccumulator(grid, ms.LinearMode)
+	if centroided {
+		acc.Mode = ms.SumMode
+	}
+	for _, sn := range scans {
+		s, isProfile, err := rf.readSpectrum(sn, true)
+		if err != nil {
+			return nil, fmt.Errorf("scan %d: %w", sn, err)
+		}
+		//a profile scan without profile points gives its centroids
+		if isProfile == centroided && len(s) > 0 {
+			return nil, fmt.Errorf("scan %d: the data do not match the profile or centroid mode of its scan event", sn)
+		}
+		acc.Add(s)
+	}
+	return acc, nil
+}
+
+//Spectrum returns the ms.Spectrum belonging to the scan number in argument
+func (rf *File) Spectrum(sn int) ms.Spectrum {
+	s, _, err := rf.readSpectrum(sn, false)
+	if err != nil {
+		log.Print("Scan ", sn, ": ", err)
+	}
+	return s
+}
+
+//packet reads the scan data packet of the scan number
+func (rf *File) packet(sn int) *ScanDataPacket {
 	scn := new(ScanDataPacket)
 	begin := rf.scanindex[sn-1].Offset
 	//end := begin + uint64(rf.scanindex[sn-1].DataPacketSize)
 
 	scn.Read(rf.b[begin:], 0)
+	return scn
+}
+
+//readSpectrum reads the spectrum of the scan and reports whether it is profile data.
+//With zeroEdges every profile chunk gets a zero intensity point on either side,
+//so interpolating the profile does not bridge the gaps between chunks.
+//Profile data with an unsupported calibration model give an error, not frequencies.
+func (rf *File) readSpectrum(sn int, zeroEdges bool) (s ms.Spectrum, profile bool, err error) {
+	//read Scan Packet for the scan
+	scn := rf.packet(sn)
 
 	sTotal := 0
 	for i := uint32(0); i < scn.Profile.PeakCount; i++ {
 		sTotal += int(scn.Profile.Chunks[i].Nbins)
 	}
-	// return
-	s = make([]ms.Peak, sTotal)
 	if scn.Profile.PeakCount > 0 {
 		//convert Hz values into m/z and save the profile peaks
+		raw := RawProfile{FirstValue: scn.Profile.FirstValue, Step: scn.Profile.Step}
+		if raw.Calibration, err = rf.Calibration(sn); err != nil {
+			return
+		}
+		if err = raw.Calibration.Check(); err != nil {
+			return
+		}
 
-		k := 0
-		var val float64
-		var tmpmz float64
+		s = make([]ms.Peak, 0, sTotal)
 		for i := uint32(0); i < scn.Profile.PeakCount; i++ {
-			for j := uint32(0); j < scn.Profile.Chunks[i].Nbins; j++ {
-				val = scn.Profile.FirstValue + float64(scn.Profile.Chunks[i].Firstbin+j)*scn.Profile.Step + float64(scn.Profile.Chunks[i].Fudge)
-				tmpmz = ConvertMz(val, rf.scanevents[sn-1].Nparam, rf.scanevents[sn-1].A, rf.scanevents[sn-1].B, rf.scanevents[sn-1].C)
-				s[k] = ms.Peak{Mz: tmpmz, I: scn.Profile.Chunks[i].Signal[j]}
-				k++
+			chunk := scn.Profile.Chunks[i]
+			if zeroEdges && chunk.Firstbin > 0 {
+				v := raw.Value(chunk, 0) - raw.Step
+				s = append(s, ms.Peak{Mz: raw.Calibration.Convert(v)})
+			}
+			for j := uint32(0); j < chunk.Nbins; j++ {
+				s = append(s, ms.Peak{Mz: raw.Calibration.Convert(raw.Value(chunk, j)), I: chunk.Signal[j]})
+			}
+			if zeroEdges {
+				s = append(s, ms.Peak{Mz: raw.Calibration.Convert(raw.Value(chunk, chunk.Nbins))})
 			}
 		}
-	} else {
-		//Save the Centroided Peaks, they also occur in profile scans but
-		//overlap with profiles, Thermo always does centroiding just for fun
-		for i := uint32(0); i < scn.PeakList.Count; i++ {
-			s = append(s,
-				ms.Peak{Mz: float64(scn.PeakList.Peaks[i].Mz),
-					I: scn.PeakList.Peaks[i].Abundance})
+		//the frequency axis may run opposite to m/z
+		if len(s) > 1 && s[0].Mz > s[len(s)-1].Mz {
+			for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+				s[i], s[j] = s[j], s[i]
+			}
 		}
+		return s, true, nil
 	}
-	return
+
+	//Save the Centroided Peaks, they also occur in profile scans but
+	//overlap with profiles, Thermo always does centroiding just for fun
+	correction := rf.correction(sn)
+	for i := uint32(0); i < scn.PeakList.Count; i++ {
+		s = append(s,
+			ms.Peak{Mz: correction.Apply(float64(scn.PeakList.Peaks[i].Mz)),
+				I: scn.PeakList.Peaks[i].Abundance})
+	}
+	return s, false, nil
 }
 
-// Converts Hz to MZ
-func ConvertMz(v float64, Nparam uint32, A float64, B float64, C float64) float64 {
+//ConvertMz converts Hz to m/z with the supplied model (see Calibration),
+//unknown models give ErrUnsupportedCalibration
+func ConvertMz(v float64, Nparam uint32, A float64, B float64, C float64) (float64, error) {
 	switch Nparam {
+	case 0:
+		return v, nil
 	case 4:
-		return A + B/v + C/v/v
+		return A + B/v + C/v/v, nil
 	case 5, 7:
-		return A + B/v/v + C/v/v/v/v
-	default:
-		return v
+		return A + B/v/v + C/v/v/v/v, nil
 	}
+	return math.NaN(), fmt.Errorf("%w: Nparam %d", ErrUnsupportedCalibration, Nparam)
 }
 
 //interface shared by all data objects in the raw file
@@ -261,20 +608,21 @@ func readBetween(rs io.ReadSeeker, bf []byte, begin uint64, end uint64, v Versio
 }
 
 //Read only the initial header part of the file (for the juicy addresses)
-func readHeaders(rs io.ReadSeeker) (RawFileInfo, Version) {
+func readHeaders(rs io.ReadSeeker) (RawFileInfo, Version, SequencerRow) {
 	hdr := new(FileHeader)
 	info := new(RawFileInfo)
+	seq := new(SequencerRow)
 
 	//save position in file after reading, we need to sequentially
 	//read some things in order to get to actual byte addresses
 	pos := readAt(rs, 0, 0, hdr)
 	ver := hdr.Version
 
-	pos = readAt(rs, pos, ver, new(SequencerRow))
+	pos = readAt(rs, pos, ver, seq)
 	pos = readAt(rs, pos, 0, new(AutoSamplerInfo))
 	readAt(rs, pos, ver, info)
 
-	return *info, ver
+	return *info, ver, *seq
 }
 
 /*
@@ -371,7 +719,6 @@ func (data *ScanDataPacket) Read(b []byte, v Version) {
 	index += 4
 	data.Header.Highmz = math.Float32frombits(binary.LittleEndian.Uint32(b[index : index+4]))
 	index += 4
-	data.PeakList.Peaks = make([]CentroidedPeak, data.PeakList.Count)
 
 	if data.Header.ProfileSize > 0 {
 		data.Profile.FirstValue = math.Float64frombits(binary.LittleEndian.Uint64(b[index : index+8]))
@@ -417,6 +764,7 @@ func (data *ScanDataPacket) Read(b []byte, v Version) {
 	if data.Header.PeaklistSize > 0 {
 		data.PeakList.Count = binary.LittleEndian.Uint32(b[index : index+4])
 		index += 4
+		data.PeakList.Peaks = make([]CentroidedPeak, data.PeakList.Count)
 		for j := range data.PeakList.Peaks {
 			data.PeakList.Peaks[j].Mz = math.Float32frombits(binary.LittleEndian.Uint32(b[index : index+4]))
 			index += 4
@@ -590,16 +938,9 @@ func (data *ScanEvent) Read(r io.Reader, v Version, m PascalString) {
 
 }
 
-//Convert Hz values to m/z
-func (data ScanEvent) Convert(v float64) float64 {
-	switch data.Nparam {
-	case 4:
-		return data.A + data.B/v + data.C/v/v
-	case 5, 7:
-		return data.A + data.B/v/v + data.C/v/v/v/v
-	default:
-		return v
-	}
+//Convert Hz values to m/z, unknown models give ErrUnsupportedCalibration (see Calibration)
+func (data ScanEvent) Convert(v float64) (float64, error) {
+	return ConvertMz(v, data.Nparam, data.A, data.B, data.C)
 }
 
 /*
@@ -1265,7 +1606,7 @@ func binaryread(r io.Reader, data interface{}) {
   Experimental: read out chromatography data from a connected instrument
 */
 func (rf *File) Chromatography(instr int) (cdata CDataPackets) {
-	info, ver := readHeaders(rf.f)
+	info, ver, _ := readHeaders(rf.f)
 
 	if uint32(instr) > info.Preamble.NControllers-1 {
 		log.Print(instr, " is higher than number of extra controllers: ", info.Preamble.NControllers-1)
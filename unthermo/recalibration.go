@@ -0,0 +1,255 @@
+package unthermo
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/danhitchcock/ms"
+)
+
+//Polysiloxane is the m/z of the protonated cyclic polysiloxane (Si6) that
+//is present as a background ion in most nano-ESI runs, a common lock mass
+const Polysiloxane = 445.12003
+
+//RecalibrationOptions configure the lock mass search of Recalibrate
+type RecalibrationOptions struct {
+	//LockMasses are the m/z values of ions that are known to be in the MS1 scans
+	LockMasses []float64
+	//Tolerance is the window around the lock masses that is searched
+	Tolerance ms.Tolerance
+	//MinIntensity is the intensity a lock mass peak needs to be used
+	MinIntensity float32
+	//Window is a retention time window in minutes. If it is not 0, the correction
+	//of an MS1 scan is fitted to the lock masses found in the window around it,
+	//otherwise to those of the scan itself.
+	Window float64
+}
+
+//Correction is the recalibration of a scan. The m/z error is modelled as
+//linear in m/z: the measured m/z is (1+PPM·10⁻⁶)·m/z + Offset.
+//The zero value is no correction.
+type Correction struct {
+	PPM    float64
+	Offset float64
+}
+
+//Apply returns the m/z with the error removed
+func (c Correction) Apply(mz float64) float64 {
+	return (mz - c.Offset) / (1 + c.PPM*1e-6)
+}
+
+//MinLockMassSpread is the m/z range the found lock masses need to span to
+//fit the Offset of a Correction, narrower lock masses only give its PPM
+const MinLockMassSpread = 50
+
+//ScanCorrection reports the recalibration of a single MS1 scan
+type ScanCorrection struct {
+	ScanNumber int
+	Time       float64
+	//Found is the number of lock masses found in the scan
+	Found int
+	//Correction is removed from the scan, Corrected is false if no lock
+	//mass was found in the scan or its window and Correction was interpolated
+	Correction Correction
+	Corrected  bool
+	//Before and After are the mean lock mass errors in ppm before and after
+	//correction, NaN if no lock mass was found
+	Before float64
+	After  float64
+}
+
+//RecalibrationReport summarizes a recalibration
+type RecalibrationReport struct {
+	Scans []ScanCorrection
+	//RMSBefore and RMSAfter are the root mean square lock mass errors in ppm
+	//of all found lock masses, before and after correction
+	RMSBefore float64
+	RMSAfter  float64
+}
+
+//Recalibrate searches the lock masses in every MS1 scan and fits a correction
+//of the Hz to m/z conversion to them by least squares, which removes an error
+//linear in m/z. Scans in between MS1 scans, or MS1 scans without lock masses,
+//are corrected by interpolating the corrections in time. The spectra that the
+//File returns afterwards are recalibrated, ResetCalibration undoes it. If an
+//error is returned the calibration is unchanged.
+func (rf *File) Recalibrate(opts RecalibrationOptions) (report RecalibrationReport, err error) {
+	if len(opts.LockMasses) == 0 {
+		err = errors.New("no lock masses supplied")
+		return
+	}
+	//the lock masses are measured with the instrument calibration
+	previous := rf.corrections
+	rf.corrections = nil
+	defer func() {
+		if err != nil {
+			rf.corrections = previous
+		}
+	}()
+
+	type measurement struct {
+		sn         int
+		time       float64
+		locks, mzs []float64
+	}
+	var measured []measurement
+	for sn := 1; sn <= rf.NScans(); sn++ {
+		scan := rf.header(sn)
+		if scan.MSLevel != 1 {
+			continue
+		}
+		m := measurement{sn: sn, time: scan.Time}
+		spectrum := rf.Spectrum(sn)
+		for _, lock := range opts.LockMasses {
+			if mz, ok := lockMassPeak(spectrum.Around(lock, opts.Tolerance), opts.MinIntensity); ok {
+				m.locks = append(m.locks, lock)
+				m.mzs = append(m.mzs, mz)
+			}
+		}
+		measured = append(measured, m)
+	}
+
+	//the correction of an MS1 scan is fitted to its lock masses, or those in the time window
+	var times []float64
+	var corrections []Correction
+	for _, m := range measured {
+		locks, mzs := m.locks, m.mzs
+		if opts.Window != 0 {
+			locks, mzs = nil, nil
+			for _, n := range measured {
+				if math.Abs(n.time-m.time) <= opts.Window/2 {
+					locks = append(locks, n.locks...)
+					mzs = append(mzs, n.mzs...)
+				}
+			}
+		}
+		c := ScanCorrection{ScanNumber: m.sn, Time: m.time, Found: len(m.locks), Before: math.NaN(), After: math.NaN()}
+		if len(locks) > 0 {
+			c.Correction, c.Corrected = fitCorrection(locks, mzs), true
+			times = append(times, m.time)
+			corrections = append(corrections, c.Correction)
+		}
+		report.Scans = append(report.Scans, c)
+	}
+	if len(corrections) == 0 {
+		err = errors.New("none of the lock masses was found")
+		return
+	}
+
+	//interpolate the corrections of all scans in time
+	rf.corrections = make([]Correction, rf.NScans())
+	ppms := make([]float64, len(corrections))
+	offsets := make([]float64, len(corrections))
+	for i, c := range corrections {
+		ppms[i], offsets[i] = c.PPM, c.Offset
+	}
+	for i := range rf.corrections {
+		t := rf.scanindex[i].Time
+		rf.corrections[i] = Correction{interpolate(times, ppms, t), interpolate(times, offsets, t)}
+	}
+
+	//report the errors that remain
+	var n int
+	var sumBefore, sumAfter float64
+	for i, m := range measured {
+		c := rf.corrections[m.sn-1]
+		report.Scans[i].Correction = c
+		if len(m.locks) == 0 {
+			continue
+		}
+		before := make([]float64, len(m.locks))
+		after := make([]float64, len(m.locks))
+		for j, lock := range m.locks {
+			before[j] = ms.PPMError(lock, m.mzs[j])
+			after[j] = ms.PPMError(lock, c.Apply(m.mzs[j]))
+			sumBefore += before[j] * before[j]
+			sumAfter += after[j] * after[j]
+			n++
+		}
+		report.Scans[i].Before = mean(before)
+		report.Scans[i].After = mean(after)
+	}
+	report.RMSBefore = math.Sqrt(sumBefore / float64(n))
+	report.RMSAfter = math.Sqrt(sumAfter / float64(n))
+
+	//the cached scans have to reflect the new calibration
+	rf.makeScans()
+	return
+}
+
+//ResetCalibration removes the corrections of Recalibrate
+func (rf *File) ResetCalibration() {
+	rf.corrections = nil
+	rf.makeScans()
+}
+
+//correction returns the correction of the scan, zero if the file is not recalibrated
+func (rf *File) correction(sn int) Correction {
+	if rf.corrections == nil {
+		return Correction{}
+	}
+	return rf.corrections[sn-1]
+}
+
+//fitCorrection returns the least squares fit of the correction to the lock
+//masses and their measured m/z
+func fitCorrection(locks []float64, mzs []float64) Correction {
+	//the error d = mz - lock is fitted as s·lock + offset
+	n := float64(len(locks))
+	var sl, sd, sll, sld float64
+	min, max := locks[0], locks[0]
+	for i, l := range locks {
+		d := mzs[i] - l
+		sl += l
+		sd += d
+		sll += l * l
+		sld += l * d
+		min, max = math.Min(min, l), math.Max(max, l)
+	}
+	if max-min < MinLockMassSpread {
+		return Correction{PPM: sld / sll * 1e6}
+	}
+	s := (sld - sl*sd/n) / (sll - sl*sl/n)
+	return Correction{PPM: s * 1e6, Offset: (sd - s*sl) / n}
+}
+
+//lockMassPeak returns the m/z of the lock mass peak within the window: the
+//intensity weighted m/z of the points above half of the most intense one,
+//which is the apex of a profile peak and the peak itself for centroids
+func lockMassPeak(window ms.Spectrum, minIntensity float32) (float64, bool) {
+	max := window.MaxPeak()
+	if len(window) == 0 || max.I <= 0 || max.I < minIntensity {
+		return 0, false
+	}
+	var sum, weighted float64
+	for _, p := range window {
+		if p.I >= max.I/2 {
+			sum += float64(p.I)
+			weighted += float64(p.I) * p.Mz
+		}
+	}
+	return weighted / sum, true
+}
+
+//interpolate returns y at x by linear interpolation of the points (xs, ys)
+//sorted by x, the outer values are extended beyond the range
+func interpolate(xs []float64, ys []float64, x float64) float64 {
+	i := sort.SearchFloat64s(xs, x)
+	switch {
+	case i == 0:
+		return ys[0]
+	case i == len(xs):
+		return ys[len(ys)-1]
+	}
+	f := (x - xs[i-1]) / (xs[i] - xs[i-1])
+	return ys[i-1] + f*(ys[i]-ys[i-1])
+}
+
+func mean(v []float64) (m float64) {
+	for _, x := range v {
+		m += x
+	}
+	return m / float64(len(v))
+}
+
@@ -1,3 +0,0 @@
-//A set of tools using the unthermo library
-//
-package main
@@ -0,0 +1,267 @@
+package unthermo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+/*
+  The trailer extra holds instrument specific parameters of every scan, such
+  as the charge state and the ion injection time. Its records start at the
+  ScanparamsAddr of the RunHeader, their layout is described by a generic
+  data header: a count of fields, each a type, a length and a label. The run
+  header has no address of that generic data header, it sits among the
+  structures before the scan data, so it is searched by one of its labels.
+*/
+
+//The labels of the trailer extra that fill the scans
+const (
+	labelCharge        = "Charge State:"
+	labelMonoisotopic  = "Monoisotopic M/Z:"
+	labelInjectionTime = "Ion Injection Time (ms):"
+)
+
+//The types of the fields of generic data
+const (
+	genericGap = iota
+	genericChar
+	genericBool
+	genericYesNo
+	genericOnOff
+	genericUChar
+	genericShort
+	genericUShort
+	genericLong
+	genericULong
+	genericFloat
+	genericDouble
+	genericASCII
+	genericUTF16
+)
+
+//trailerField is a field of the trailer extra records, at Offset in a record
+type trailerField struct {
+	Label  string
+	Type   uint32
+	Length uint32
+	Offset int
+}
+
+//size returns the number of bytes of the field in a record
+func (f trailerField) size() int {
+	switch f.Type {
+	case genericGap, genericASCII:
+		return int(f.Length)
+	case genericUTF16:
+		return 2 * int(f.Length)
+	case genericShort, genericUShort:
+		return 2
+	case genericLong, genericULong, genericFloat:
+		return 4
+	case genericDouble:
+		return 8
+	}
+	return 1
+}
+
+//TrailerEntry is a parameter of the trailer extra of a scan
+type TrailerEntry struct {
+	Label string
+	Value string
+}
+
+//trailer is the layout of the trailer extra records and where they are
+type trailer struct {
+	fields []trailerField
+	size   int
+	addr   uint64
+}
+
+//readTrailer finds the layout of the trailer extra of nScans scans, it
+//returns false if the file has none or it is not recognized
+func readTrailer(b []byte, rh *RunHeader, nScans int) (t trailer, ok bool) {
+	end := rh.DataAddr
+	if end == 0 || end > uint64(len(b)) {
+		end = uint64(len(b))
+	}
+	//a label is a PascalString, the count of UTF-16 characters precedes them
+	needle := make([]byte, 4, 4+2*len(labelCharge))
+	binary.LittleEndian.PutUint32(needle, uint32(len(labelCharge)))
+	for _, c := range utf16.Encode([]rune(labelCharge)) {
+		needle = binary.LittleEndian.AppendUint16(needle, c)
+	}
+	for from := 0; ; {
+		i := bytes.Index(b[from:end], needle)
+		if i < 0 {
+			return t, false
+		}
+		//the type and length of the field precede its label
+		field := from + i - 8
+		from += i + 1
+		if field < 0 {
+			continue
+		}
+		t, ok = genericHeaderAround(b, field)
+		if !ok {
+			continue
+		}
+		//the records may be preceded by their count
+		t.addr = rh.ScanparamsAddr
+		if t.addr+4 <= uint64(len(b)) && binary.LittleEndian.Uint32(b[t.addr:]) == uint32(nScans) {
+			t.addr += 4
+		}
+		if t.size == 0 || t.addr+uint64(nScans*t.size) > uint64(len(b)) {
+			continue
+		}
+		//the charge of the last scan is a sanity check of the layout
+		if z, found := t.value(b, nScans, labelCharge); found && (z < 0 || z > 100) {
+			continue
+		}
+		return t, true
+	}
+}
+
+//genericHeaderAround returns the generic data header with a field at pos.
+//The count of fields is searched backwards, the earliest header that
+//parses and holds the field is taken.
+func genericHeaderAround(b []byte, pos int) (t trailer, ok bool) {
+	for start := pos - 4; start >= 0 && start >= pos-65536; start-- {
+		if h, holds := parseGenericHeader(b, start, pos); holds {
+			t, ok = h, true
+		}
+	}
+	return
+}
+
+//parseGenericHeader parses a generic data header at start, it reports
+//whether it is valid and has a field at pos
+func parseGenericHeader(b []byte, start int, pos int) (t trailer, holds bool) {
+	if start+4 > len(b) {
+		return
+	}
+	n := int(binary.LittleEndian.Uint32(b[start:]))
+	if n < 1 || n > 2000 {
+		return
+	}
+	p := start + 4
+	for k := 0; k < n; k++ {
+		if p+12 > len(b) || p > pos && !holds {
+			return t, false
+		}
+		holds = holds || p == pos
+		f := trailerField{Type: binary.LittleEndian.Uint32(b[p:]), Length: binary.LittleEndian.Uint32(b[p+4:])}
+		chars := int(binary.LittleEndian.Uint32(b[p+8:]))
+		p += 12
+		if f.Type > genericUTF16 || f.Length > 4096 || chars > 512 || p+2*chars > len(b) {
+			return t, false
+		}
+		label := make([]uint16, chars)
+		for j := range label {
+			label[j] = binary.LittleEndian.Uint16(b[p+2*j:])
+			if label[j] < 0x20 || label[j] == 0xffff {
+				return t, false
+			}
+		}
+		p += 2 * chars
+		f.Label = strings.TrimSpace(string(utf16.Decode(label)))
+		f.Offset = t.size
+		t.size += f.size()
+		t.fields = append(t.fields, f)
+	}
+	return t, holds
+}
+
+//record returns the trailer extra record of the scan
+func (t trailer) record(b []byte, sn int) []byte {
+	begin := t.addr + uint64((sn-1)*t.size)
+	return b[begin : begin+uint64(t.size)]
+}
+
+//value returns the numeric value of the field with the label in the record
+//of the scan, false if there is no such field
+func (t trailer) value(b []byte, sn int, label string) (float64, bool) {
+	if t.size == 0 {
+		return 0, false
+	}
+	rec := t.record(b, sn)
+	for _, f := range t.fields {
+		if f.Label == label {
+			return f.number(rec[f.Offset:])
+		}
+	}
+	return 0, false
+}
+
+//number returns the value of a numeric field, false for other types
+func (f trailerField) number(b []byte) (float64, bool) {
+	switch f.Type {
+	case genericChar:
+		return float64(int8(b[0])), true
+	case genericBool, genericYesNo, genericOnOff, genericUChar:
+		return float64(b[0]), true
+	case genericShort:
+		return float64(int16(binary.LittleEndian.Uint16(b))), true
+	case genericUShort:
+		return float64(binary.LittleEndian.Uint16(b)), true
+	case genericLong:
+		return float64(int32(binary.LittleEndian.Uint32(b))), true
+	case genericULong:
+		return float64(binary.LittleEndian.Uint32(b)), true
+	case genericFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), true
+	case genericDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), true
+	}
+	return 0, false
+}
+
+//text returns the value of the field as Xcalibur shows it
+func (f trailerField) text(b []byte) string {
+	switch f.Type {
+	case genericBool:
+		return strconv.FormatBool(b[0] != 0)
+	case genericYesNo:
+		if b[0] != 0 {
+			return "Yes"
+		}
+		return "No"
+	case genericOnOff:
+		if b[0] != 0 {
+			return "On"
+		}
+		return "Off"
+	case genericFloat:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), 'g', -1, 32)
+	case genericASCII:
+		return strings.TrimRight(string(b[:f.Length]), "\x00 ")
+	case genericUTF16:
+		s := make([]uint16, f.Length)
+		for j := range s {
+			s[j] = binary.LittleEndian.Uint16(b[2*j:])
+		}
+		return strings.TrimRight(string(utf16.Decode(s)), "\x00 ")
+	}
+	v, _ := f.number(b)
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+//Trailer returns the trailer extra of the scan in the order of the
+//instrument, nil if the file has none or its layout is not recognized
+func (rf *File) Trailer(sn int) []TrailerEntry {
+	if rf.trailer.size == 0 || sn < 1 || sn > rf.NScans() {
+		return nil
+	}
+	rec := rf.trailer.record(rf.b, sn)
+	entries := make([]TrailerEntry, 0, len(rf.trailer.fields))
+	for _, f := range rf.trailer.fields {
+		if f.Type == genericGap {
+			continue
+		}
+		entries = append(entries, TrailerEntry{f.Label, f.text(rec[f.Offset:])})
+	}
+	return entries
+}
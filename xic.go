@@ -0,0 +1,172 @@
+package ms
+
+import (
+	"sort"
+	"sync"
+)
+
+//XICTarget is an ion of which ExtractXICs extracts the chromatogram
+type XICTarget struct {
+	ID string
+	Mz float64
+	//Tol is the m/z tolerance, a zero Tol uses the tolerance of the options
+	Tol Tolerance
+	//MinTime and MaxTime are the retention time window in minutes,
+	//a MaxTime of 0 means no upper limit
+	MinTime float64
+	MaxTime float64
+}
+
+//inWindow reports whether the retention time is in the window of the target
+func (t XICTarget) inWindow(rt float64) bool {
+	return rt >= t.MinTime && (t.MaxTime == 0 || rt <= t.MaxTime)
+}
+
+//XICOptions are the options of ExtractXICs
+type XICOptions struct {
+	//Tol is the tolerance of the targets without one
+	Tol Tolerance
+	//Filter selects the scans, usually the MS1 scans
+	Filter ScanFilter
+	//Sum takes the summed intensity of the peaks within the tolerance, with
+	//their intensity weighted m/z, instead of the most intense peak
+	Sum bool
+	//Zeros adds points of intensity 0 for the scans in the window of
+	//a target without its peak, otherwise those scans are left out
+	Zeros bool
+	//Workers is the number of goroutines that search the spectra. Scans are
+	//read by one goroutine, as Readers are not safe for concurrent use.
+	Workers int
+}
+
+//xicMatch is the peak of a target in a scan
+type xicMatch struct {
+	target int
+	peak   Peak
+	//i is the intensity of the peak or the summed intensity
+	i float64
+}
+
+//ExtractXICs returns the extracted ion chromatograms of the targets, in the
+//order of the targets, from a single pass over the scans. The points hold
+//the scan number and the m/z of the matched peak.
+func ExtractXICs(file Reader, targets []XICTarget, opts XICOptions) []Chromatogram {
+	//the targets ordered by the lower end of their window, so that every
+	//spectrum and the targets are merged in one pass
+	windows := make([]xicWindow, len(targets))
+	for i, t := range targets {
+		tol := t.Tol
+		if tol == (Tolerance{}) {
+			tol = opts.Tol
+		}
+		min, max := tol.Window(t.Mz)
+		windows[i] = xicWindow{i, min, max}
+	}
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].min < windows[j].min })
+
+	type job struct {
+		scan    Scan
+		matches *[]xicMatch
+	}
+	n := file.NScans()
+	scans := make([]Scan, 0, n)
+	results := make([][]xicMatch, n)
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				*j.matches = matchXIC(j.scan, targets, windows, opts.Sum)
+			}
+		}()
+	}
+	for i := 1; i <= n; i++ {
+		scan := file.Scan(i)
+		if !opts.Filter.Matches(scan) {
+			continue
+		}
+		jobs <- job{scan, &results[len(scans)]}
+		//only the spectrum is needed to match
+		scan.Spectrum = nil
+		scans = append(scans, scan)
+	}
+	close(jobs)
+	wg.Wait()
+
+	xics := make([]Chromatogram, len(targets))
+	for i, t := range targets {
+		xics[i] = Chromatogram{ID: t.ID, Type: XIC, Points: []ChromatogramPoint{}}
+	}
+	found := make([]bool, len(targets))
+	for k, scan := range scans {
+		for _, m := range results[k] {
+			found[m.target] = true
+			xics[m.target].Points = append(xics[m.target].Points,
+				ChromatogramPoint{Time: scan.Time, I: m.i, Mz: m.peak.Mz, ScanNumber: scan.Number})
+		}
+		if !opts.Zeros {
+			continue
+		}
+		for i, t := range targets {
+			if !found[i] && t.inWindow(scan.Time) {
+				xics[i].Points = append(xics[i].Points, ChromatogramPoint{Time: scan.Time, ScanNumber: scan.Number})
+			}
+			found[i] = false
+		}
+	}
+	return xics
+}
+
+//xicWindow is the m/z window of a target
+type xicWindow struct {
+	target   int
+	min, max float64
+}
+
+//matchXIC returns the peaks of the targets in the scan
+func matchXIC(scan Scan, targets []XICTarget, windows []xicWindow, sum bool) (matches []xicMatch) {
+	s := scan.Spectrum
+	p := 0
+	for _, w := range windows {
+		//the lower ends are ascending, so the first peak of a window is never before that of the previous one
+		for p < len(s) && s[p].Mz < w.min {
+			p++
+		}
+		if p == len(s) {
+			break
+		}
+		if !targets[w.target].inWindow(scan.Time) {
+			continue
+		}
+		m := xicMatch{target: w.target}
+		var weighted float64
+		for _, peak := range s[p:] {
+			if peak.Mz > w.max {
+				break
+			}
+			if peak.I >= m.peak.I {
+				m.peak.I, m.peak.Mz = peak.I, peak.Mz
+			}
+			m.i += float64(peak.I)
+			weighted += peak.Mz * float64(peak.I)
+		}
+		if m.peak.Mz == 0 {
+			continue
+		}
+		if !sum {
+			m.i = float64(m.peak.I)
+		} else if m.i > 0 {
+			m.peak.Mz = weighted / m.i
+		}
+		matches = append(matches, m)
+	}
+	//in the order of the targets
+	sort.Slice(matches, func(i, j int) bool { return matches[i].target < matches[j].target })
+	return
+}
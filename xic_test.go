@@ -0,0 +1,88 @@
+package ms
+
+import (
+	"math"
+	"testing"
+)
+
+//memReader is a Reader of scans in memory
+type memReader []Scan
+
+func (r memReader) NScans() int                   { return len(r) }
+func (r memReader) Scan(sn int) Scan              { return r[sn-1] }
+func (r memReader) Spectrum(sn int) Spectrum      { return r[sn-1].Spectrum }
+func (r memReader) Metadata() Metadata            { return Metadata{} }
+func (r memReader) Chromatograms() []Chromatogram { return nil }
+func (r memReader) Close() error                  { return nil }
+
+func TestExtractXICs(t *testing.T) {
+	run := memReader{
+		{Number: 1, MSLevel: 1, Time: 1, Spectrum: Spectrum{{99.9, 5}, {100, 10}, {100.05, 30}, {100.3, 7}, {200, 50}}},
+		{Number: 2, MSLevel: 2, Time: 1.5, Spectrum: Spectrum{{100, 999}, {200, 999}}},
+		{Number: 3, MSLevel: 1, Time: 2, Spectrum: Spectrum{{100.02, 20}, {200.001, 40}}},
+		{Number: 4, MSLevel: 1, Time: 3, Spectrum: Spectrum{{300, 1}}},
+	}
+	//the windows overlap: wide and before both hold the narrow one
+	targets := []XICTarget{
+		{ID: "wide", Mz: 100, Tol: Da(0.5)},
+		{ID: "narrow", Mz: 100},
+		{ID: "after", Mz: 200, Tol: PPM(10), MinTime: 1.5},
+		{ID: "before", Mz: 100, Tol: Da(0.5), MaxTime: 1.5},
+	}
+	p := func(sn int, rt, i, mz float64) ChromatogramPoint {
+		return ChromatogramPoint{Time: rt, I: i, Mz: mz, ScanNumber: sn}
+	}
+	tests := []struct {
+		name string
+		opts XICOptions
+		want [][]ChromatogramPoint
+	}{
+		{"most intense", XICOptions{}, [][]ChromatogramPoint{
+			{p(1, 1, 30, 100.05), p(3, 2, 20, 100.02)},
+			{p(1, 1, 10, 100)},
+			{p(3, 2, 40, 200.001)},
+			{p(1, 1, 30, 100.05)},
+		}},
+		{"sum", XICOptions{Sum: true}, [][]ChromatogramPoint{
+			{p(1, 1, 52, (99.9*5+100*10+100.05*30+100.3*7)/52), p(3, 2, 20, 100.02)},
+			{p(1, 1, 10, 100)},
+			{p(3, 2, 40, 200.001)},
+			{p(1, 1, 52, (99.9*5+100*10+100.05*30+100.3*7)/52)},
+		}},
+		{"zeros", XICOptions{Zeros: true, Workers: 3}, [][]ChromatogramPoint{
+			{p(1, 1, 30, 100.05), p(3, 2, 20, 100.02), p(4, 3, 0, 0)},
+			{p(1, 1, 10, 100), p(3, 2, 0, 0), p(4, 3, 0, 0)},
+			{p(3, 2, 40, 200.001), p(4, 3, 0, 0)},
+			{p(1, 1, 30, 100.05)},
+		}},
+	}
+	for _, tt := range tests {
+		tt.opts.Tol = Da(0.01)
+		tt.opts.Filter = ScanFilter{MSLevel: 1}
+		xics := ExtractXICs(run, targets, tt.opts)
+		if len(xics) != len(targets) {
+			t.Fatalf("%s: %d chromatograms for %d targets", tt.name, len(xics), len(targets))
+		}
+		for i, x := range xics {
+			if x.ID != targets[i].ID || x.Type != XIC {
+				t.Errorf("%s: chromatogram %d is %s of type %v", tt.name, i, x.ID, x.Type)
+			}
+			if !equalPoints(x.Points, tt.want[i]) {
+				t.Errorf("%s: %s is %v, want %v", tt.name, x.ID, x.Points, tt.want[i])
+			}
+		}
+	}
+}
+
+//equalPoints compares chromatograms up to the rounding of the m/z
+func equalPoints(a, b []ChromatogramPoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Time != b[i].Time || a[i].I != b[i].I || a[i].ScanNumber != b[i].ScanNumber || math.Abs(a[i].Mz-b[i].Mz) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}